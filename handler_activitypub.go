@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/activitypub"
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/handle"
+	"github.com/google/uuid"
+)
+
+// federationStore is the activitypub.Store of the database. Local users are
+// the users with a handle, unless shadow banned, and their notes are their
+// public chirps.
+type federationStore struct {
+	db *database.Queries
+}
+
+func federationError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return activitypub.ErrNotFound
+	}
+	return err
+}
+
+func (s federationStore) localUser(ctx context.Context, username string) (database.User, error) {
+	user, err := s.db.GetUserByHandle(ctx, handle.Normalize(username))
+	if err != nil {
+		return database.User{}, federationError(err)
+	}
+	if !isFederated(user) {
+		return database.User{}, activitypub.ErrNotFound
+	}
+	return user, nil
+}
+
+// isFederated reports whether user is visible to remote servers: users
+// need a handle, and shadow banned users are hidden.
+func isFederated(user database.User) bool {
+	return user.Handle.Valid && user.State != userStateShadowBanned
+}
+
+// isFederatedChirp reports whether chirp is visible to remote servers.
+func isFederatedChirp(chirp database.Chirp) bool {
+	return chirp.Status == chirpStatusPublished && !chirp.DeletedAt.Valid && !chirp.HiddenAt.Valid
+}
+
+// actorKey returns the key pair of a user, generating it the first time.
+func (s federationStore) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := s.db.GetActorKey(ctx, userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, err
+	}
+	private, public, err := activitypub.GenerateKey()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	// Concurrent requests may both generate a key, the first one stored wins
+	if err := s.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:     userID,
+		PublicKey:  public,
+		PrivateKey: private,
+	}); err != nil {
+		return database.ActorKey{}, err
+	}
+	return s.db.GetActorKey(ctx, userID)
+}
+
+func (s federationStore) LocalActor(ctx context.Context, username string) (activitypub.LocalActor, error) {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return activitypub.LocalActor{}, err
+	}
+	key, err := s.actorKey(ctx, user.ID)
+	if err != nil {
+		return activitypub.LocalActor{}, err
+	}
+	return activitypub.LocalActor{
+		Username:      user.Handle.String,
+		Name:          user.DisplayName,
+		Summary:       user.Bio,
+		PublicKeyPEM:  key.PublicKey,
+		PrivateKeyPEM: key.PrivateKey,
+	}, nil
+}
+
+func localNote(chirp database.Chirp) activitypub.LocalNote {
+	return activitypub.LocalNote{ID: chirp.ID.String(), Content: chirp.Body, Published: chirp.CreatedAt}
+}
+
+func (s federationStore) LocalNotes(ctx context.Context, username string, limit int) ([]activitypub.LocalNote, error) {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := s.db.GetFederatedChirpsByUserID(ctx, database.GetFederatedChirpsByUserIDParams{
+		UserID:    user.ID,
+		MaxChirps: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]activitypub.LocalNote, len(chirps))
+	for i, chirp := range chirps {
+		notes[i] = localNote(chirp)
+	}
+	return notes, nil
+}
+
+// publicChirp returns a chirp and its author if it can be federated.
+func (s federationStore) publicChirp(ctx context.Context, id string) (database.Chirp, database.User, error) {
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return database.Chirp{}, database.User{}, activitypub.ErrNotFound
+	}
+	chirp, err := s.db.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, database.User{}, federationError(err)
+	}
+	if !isFederatedChirp(chirp) {
+		return database.Chirp{}, database.User{}, activitypub.ErrNotFound
+	}
+	author, err := s.db.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return database.Chirp{}, database.User{}, federationError(err)
+	}
+	if !isFederated(author) {
+		return database.Chirp{}, database.User{}, activitypub.ErrNotFound
+	}
+	return chirp, author, nil
+}
+
+func (s federationStore) LocalNote(ctx context.Context, id string) (activitypub.LocalNote, string, error) {
+	chirp, author, err := s.publicChirp(ctx, id)
+	if err != nil {
+		return activitypub.LocalNote{}, "", err
+	}
+	return localNote(chirp), author.Handle.String, nil
+}
+
+func remoteActor(a database.RemoteActor) activitypub.RemoteActor {
+	return activitypub.RemoteActor{
+		ID:           a.Uri,
+		Username:     a.Username,
+		Inbox:        a.Inbox,
+		SharedInbox:  a.SharedInbox,
+		PublicKeyPEM: a.PublicKey,
+	}
+}
+
+func (s federationStore) RemoteActor(ctx context.Context, id string) (activitypub.RemoteActor, error) {
+	actor, err := s.db.GetRemoteActorByURI(ctx, id)
+	if err != nil {
+		return activitypub.RemoteActor{}, federationError(err)
+	}
+	return remoteActor(actor), nil
+}
+
+func (s federationStore) SaveRemoteActor(ctx context.Context, actor activitypub.RemoteActor) error {
+	return s.db.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		Uri:         actor.ID,
+		Username:    actor.Username,
+		Inbox:       actor.Inbox,
+		SharedInbox: actor.SharedInbox,
+		PublicKey:   actor.PublicKeyPEM,
+	})
+}
+
+func (s federationStore) Followers(ctx context.Context, username string) ([]activitypub.RemoteActor, error) {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.GetRemoteFollowers(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	followers := make([]activitypub.RemoteActor, len(rows))
+	for i, row := range rows {
+		followers[i] = remoteActor(row)
+	}
+	return followers, nil
+}
+
+func (s federationStore) AddFollower(ctx context.Context, username, followerID, followID string) error {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	return s.db.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{
+		UserID:    user.ID,
+		FollowUri: followID,
+		ActorUri:  followerID,
+	})
+}
+
+func (s federationStore) RemoveFollower(ctx context.Context, username, followerID string) error {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	return s.db.RemoveRemoteFollower(ctx, database.RemoveRemoteFollowerParams{
+		UserID:   user.ID,
+		ActorUri: followerID,
+	})
+}
+
+func (s federationStore) AddFollowing(ctx context.Context, username, actorID, followID string) error {
+	user, err := s.localUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	return s.db.AddRemoteFollow(ctx, database.AddRemoteFollowParams{
+		UserID:    user.ID,
+		FollowUri: followID,
+		ActorUri:  actorID,
+	})
+}
+
+func (s federationStore) AcceptFollowing(ctx context.Context, followID, actorID string) error {
+	n, err := s.db.AcceptRemoteFollow(ctx, database.AcceptRemoteFollowParams{
+		FollowUri: followID,
+		ActorUri:  actorID,
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return activitypub.ErrNotFound
+	}
+	return nil
+}
+
+func (s federationStore) StoreNote(ctx context.Context, note activitypub.RemoteNote) error {
+	followed, err := s.db.IsRemoteActorFollowed(ctx, note.AuthorID)
+	if err != nil {
+		return err
+	}
+	if !followed {
+		return activitypub.ErrForbidden
+	}
+	return s.db.CreateRemoteChirp(ctx, database.CreateRemoteChirpParams{
+		Uri:         note.ID,
+		Body:        note.Content,
+		Url:         note.URL,
+		PublishedAt: note.Published,
+		ActorUri:    note.AuthorID,
+	})
+}
+
+func (s federationStore) DeleteNote(ctx context.Context, id, authorID string) error {
+	return s.db.DeleteRemoteChirp(ctx, database.DeleteRemoteChirpParams{Uri: id, ActorUri: authorID})
+}
+
+func (s federationStore) AddLike(ctx context.Context, noteID, actorID, likeID string) error {
+	chirp, _, err := s.publicChirp(ctx, noteID)
+	if err != nil {
+		return err
+	}
+	return s.db.AddRemoteLike(ctx, database.AddRemoteLikeParams{
+		ChirpID:  chirp.ID,
+		LikeUri:  likeID,
+		ActorUri: actorID,
+	})
+}
+
+func (s federationStore) RemoveLike(ctx context.Context, noteID, actorID string) error {
+	chirpID, err := uuid.Parse(noteID)
+	if err != nil {
+		return activitypub.ErrNotFound
+	}
+	return s.db.RemoveRemoteLike(ctx, database.RemoveRemoteLikeParams{ChirpID: chirpID, ActorUri: actorID})
+}
+
+// federateChirp is the event subscriber delivering the new, deleted,
+// restored and hidden chirps of local users to their remote followers.
+// Deliveries are retried to every follower when one fails, and remote
+// servers ignore the activities they already received.
+func (cfg *apiConfig) federateChirp(ctx context.Context, r events.Record) error {
+	e, err := r.Decode()
+	if err != nil {
+		return err
+	}
+	switch e := e.(type) {
+	case *events.ChirpCreated:
+		return cfg.federateNote(ctx, e.ChirpID)
+	case *events.ChirpRestored:
+		// Restored chirps are sent again as new notes
+		return cfg.federateNote(ctx, e.ChirpID)
+	case *events.ChirpDeleted:
+		return cfg.federateDeletion(ctx, e.AuthorID, e.ChirpID)
+	case *events.ChirpHidden:
+		return cfg.federateDeletion(ctx, e.AuthorID, e.ChirpID)
+	}
+	return nil
+}
+
+// federateNote sends a chirp to the remote followers of its author, if it is
+// public.
+func (cfg *apiConfig) federateNote(ctx context.Context, chirpID uuid.UUID) error {
+	chirp, author, err := federationStore{db: cfg.db}.publicChirp(ctx, chirpID.String())
+	if errors.Is(err, activitypub.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cfg.federation.PublishNote(ctx, author.Handle.String, localNote(chirp))
+}
+
+// federateDeletion deletes a chirp from the remote servers following its
+// author.
+func (cfg *apiConfig) federateDeletion(ctx context.Context, authorID, chirpID uuid.UUID) error {
+	author, err := cfg.db.GetUserByID(ctx, authorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !author.Handle.Valid {
+		return nil
+	}
+	return cfg.federation.DeleteNote(ctx, author.Handle.String, chirpID.String())
+}
+
+type remoteChirpResponse struct {
+	ID          uuid.UUID `json:"id"`
+	URI         string    `json:"uri"`
+	URL         string    `json:"url,omitempty"`
+	Body        string    `json:"body"`
+	PublishedAt time.Time `json:"published_at"`
+	Author      struct {
+		URI      string `json:"uri"`
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// federatedUser authenticates the request and returns the user, who needs a
+// handle to federate, or writes an error response and returns false.
+func (cfg *apiConfig) federatedUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return database.User{}, false
+	}
+	user, ok := cfg.activeUser(w, r, userID)
+	if !ok {
+		return database.User{}, false
+	}
+	if !isFederated(user) {
+		respondWithError(w, http.StatusForbidden, "set a handle to interact with remote accounts")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// handlerFollowRemote asks a remote account, given as user@domain, to
+// accept the user as follower. Their notes are received once it accepts.
+func (cfg *apiConfig) handlerFollowRemote(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Account string `json:"account"`
+	}
+	type response struct {
+		URI      string `json:"uri"`
+		Username string `json:"username"`
+		Status   string `json:"status"`
+	}
+	user, ok := cfg.federatedUser(w, r)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil || params.Account == "" {
+		respondWithError(w, http.StatusBadRequest, "account is required")
+		return
+	}
+	actor, err := cfg.federation.Follow(r.Context(), user.Handle.String, params.Account)
+	if err != nil {
+		log.Printf("unable to follow '%s': %v", params.Account, err)
+		switch {
+		case errors.Is(err, activitypub.ErrNotFound):
+			respondWithError(w, http.StatusNotFound, "account not found")
+		case errors.Is(err, activitypub.ErrForbidden):
+			respondWithError(w, http.StatusBadRequest, "can't follow local accounts remotely")
+		default:
+			respondWithError(w, http.StatusBadGateway, "unable to reach the remote server")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, response{URI: actor.ID, Username: actor.Username, Status: "pending"})
+}
+
+// handlerGetRemoteChirps returns the chirps of the remote accounts the user
+// follows, newest first.
+func (cfg *apiConfig) handlerGetRemoteChirps(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.federatedUser(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := cfg.db.GetRemoteTimeline(r.Context(), database.GetRemoteTimelineParams{
+		UserID: user.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to get remote chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve remote chirps")
+		return
+	}
+	resp := make([]remoteChirpResponse, len(rows))
+	for i, row := range rows {
+		resp[i] = remoteChirpResponse{
+			ID:          row.ID,
+			URI:         row.Uri,
+			URL:         row.Url,
+			Body:        row.Body,
+			PublishedAt: row.PublishedAt,
+		}
+		resp[i].Author.URI = row.ActorUri
+		resp[i].Author.Username = row.ActorUsername
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerLikeRemoteChirp sends a Like of a remote chirp to its author.
+func (cfg *apiConfig) handlerLikeRemoteChirp(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.federatedUser(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	chirp, err := cfg.db.GetRemoteChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	err = cfg.federation.Like(r.Context(), user.Handle.String, activitypub.RemoteNote{
+		ID:       chirp.Uri,
+		AuthorID: chirp.ActorUri,
+	})
+	if err != nil {
+		log.Printf("unable to like remote chirp '%s': %v", chirp.Uri, err)
+		respondWithError(w, http.StatusBadGateway, "unable to reach the remote server")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+)
+
+func TestIsFederated(t *testing.T) {
+	handle := sql.NullString{String: "alice", Valid: true}
+	cases := []struct {
+		name string
+		user database.User
+		want bool
+	}{
+		{name: "active with handle", user: database.User{Handle: handle, State: userStateActive}, want: true},
+		{name: "suspended", user: database.User{Handle: handle, State: userStateSuspended}, want: true},
+		{name: "shadow banned", user: database.User{Handle: handle, State: userStateShadowBanned}, want: false},
+		{name: "without handle", user: database.User{State: userStateActive}, want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isFederated(c.user); got != c.want {
+				t.Errorf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestIsFederatedChirp(t *testing.T) {
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	cases := []struct {
+		name  string
+		chirp database.Chirp
+		want  bool
+	}{
+		{name: "published", chirp: database.Chirp{Status: chirpStatusPublished}, want: true},
+		{name: "scheduled", chirp: database.Chirp{Status: chirpStatusScheduled}, want: false},
+		{name: "deleted", chirp: database.Chirp{Status: chirpStatusPublished, DeletedAt: now}, want: false},
+		{name: "hidden", chirp: database.Chirp{Status: chirpStatusPublished, HiddenAt: now}, want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isFederatedChirp(c.chirp); got != c.want {
+				t.Errorf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/fonspa/go-http-server/internal/database"
+)
+
+// handlerBlockUser blocks the user in the path. Blocking removes the follows
+// between both users, and neither sees the chirps of the other anymore.
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "block")
+	if !ok {
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to block user")
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to block user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if _, err := qtx.BlockUser(r.Context(), database.BlockUserParams{BlockerID: userID, BlockedID: targetID}); err != nil {
+		log.Printf("unable to block user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to block user")
+		return
+	}
+	for _, f := range []database.UnfollowUserParams{
+		{FollowerID: userID, FolloweeID: targetID},
+		{FollowerID: targetID, FolloweeID: userID},
+	} {
+		n, err := qtx.UnfollowUser(r.Context(), f)
+		if err == nil && n > 0 {
+			err = cfg.timeline.Unfollowed(r.Context(), qtx, f.FollowerID, f.FolloweeID)
+		}
+		if err != nil {
+			log.Printf("unable to remove follow of '%s' by '%s': %v", f.FolloweeID, f.FollowerID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to block user")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit block: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to block user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "unblock")
+	if !ok {
+		return
+	}
+	if err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{BlockerID: userID, BlockedID: targetID}); err != nil {
+		log.Printf("unable to unblock user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unblock user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerMuteUser hides the chirps of the user in the path from the user,
+// without the muted user knowing or being otherwise restricted.
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "mute")
+	if !ok {
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to mute user")
+		return
+	}
+	if err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{MuterID: userID, MutedID: targetID}); err != nil {
+		log.Printf("unable to mute user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to mute user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "unmute")
+	if !ok {
+		return
+	}
+	if err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{MuterID: userID, MutedID: targetID}); err != nil {
+		log.Printf("unable to unmute user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unmute user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
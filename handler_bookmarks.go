@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpAction authenticates the request and returns the user and the chirp
+// in the path if the user can see it, or writes an error response and
+// returns false.
+func (cfg *apiConfig) chirpAction(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Chirp, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, userID)
+	if err != nil {
+		log.Printf("unable to check visibility of chirp '%s': %v", chirp.ID, err)
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	return userID, chirp, true
+}
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := cfg.chirpAction(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.BookmarkChirp(r.Context(), database.BookmarkChirpParams{UserID: userID, ChirpID: chirp.ID}); err != nil {
+		log.Printf("unable to bookmark chirp '%s': %v", chirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to bookmark chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	if err := cfg.db.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{UserID: userID, ChirpID: chirpID}); err != nil {
+		log.Printf("unable to remove bookmark of chirp '%s': %v", chirpID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to remove bookmark")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetBookmarks returns the chirps bookmarked by the user, the most
+// recently bookmarked first. Bookmarks are private.
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirps, err := cfg.db.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+		UserID:     userID,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve bookmarks: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve bookmarks")
+		return
+	}
+	cfg.respondWithChirps(w, r, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerPinChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := cfg.chirpAction(w, r)
+	if !ok {
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "you can only pin your own chirps")
+		return
+	}
+	if chirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusBadRequest, "only published chirps can be pinned")
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to pin chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	// Pins of the same user are serialized, so that concurrent ones can't
+	// both pass the limit check.
+	user, err := qtx.LockUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user '%s': %v", userID, err)
+		respondWithError(w, http.StatusUnauthorized, "unknown user")
+		return
+	}
+	pinned, err := qtx.IsChirpPinned(r.Context(), chirp.ID)
+	if err != nil {
+		log.Printf("unable to check pin of chirp '%s': %v", chirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to pin chirp")
+		return
+	}
+	if pinned {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	n, err := qtx.PinChirp(r.Context(), database.PinChirpParams{
+		ChirpID: chirp.ID,
+		UserID:  userID,
+		MaxPins: int32(cfg.limitsOf(user).MaxPins),
+	})
+	if err != nil {
+		log.Printf("unable to pin chirp '%s': %v", chirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to pin chirp")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("you can pin at most %d chirps", cfg.limitsOf(user).MaxPins))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit pin of chirp '%s': %v", chirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to pin chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnpinChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	if err := cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{ChirpID: chirpID, UserID: userID}); err != nil {
+		log.Printf("unable to unpin chirp '%s': %v", chirpID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unpin chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,41 +1,179 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/chirptext"
 	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/entitlements"
+	"github.com/fonspa/go-http-server/internal/events"
 	"github.com/google/uuid"
 )
 
-const (
-	chirpMaxLen = 140
-)
+// chirpURLWeight is the length a URL counts for, whatever its size.
+const chirpURLWeight = 23
 
 type chirpPayload struct {
-	Body   string `json:"body"`
-	UserID string `json:"user_id"`
+	Body      string     `json:"body"`
+	UserID    string     `json:"user_id"`
+	MediaIDs  []string   `json:"media_ids"`
+	PublishAt *time.Time `json:"publish_at"`
+	Draft     bool       `json:"draft"`
 }
 
 type chirpResponse struct {
+	ID        uuid.UUID          `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Body      string             `json:"body"`
+	UserID    uuid.UUID          `json:"user_id"`
+	Author    authorResponse     `json:"author"`
+	Entities  []chirptext.Entity `json:"entities"`
+	Media     []mediaResponse    `json:"media"`
+	Status    string             `json:"status"`
+	PublishAt *time.Time         `json:"publish_at,omitempty"`
+}
+
+// chirpTombstone is returned in place of a deleted chirp, so that clients can
+// tell it apart from a chirp that never existed.
+type chirpTombstone struct {
 	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Error     string    `json:"error"`
+}
+
+// chirpsResponse builds the API representation of chirps, fetching their
+// attached media and their authors in a query each.
+func (cfg *apiConfig) chirpsResponse(ctx context.Context, chirps []database.Chirp) ([]chirpResponse, error) {
+	if len(chirps) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	for i, c := range chirps {
+		ids[i] = c.ID
+	}
+	files, err := cfg.db.GetMediaFilesForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mediaByChirp := map[uuid.UUID][]mediaResponse{}
+	for _, f := range files {
+		mediaByChirp[f.ChirpID.UUID] = append(mediaByChirp[f.ChirpID.UUID], cfg.newMediaResponse(f))
+	}
+	var authorIDs []uuid.UUID
+	for _, c := range chirps {
+		if !slices.Contains(authorIDs, c.UserID) {
+			authorIDs = append(authorIDs, c.UserID)
+		}
+	}
+	authors, err := cfg.authorsByID(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	var resp []chirpResponse
+	for _, c := range chirps {
+		entities := chirptext.Extract(c.Body)
+		if entities == nil {
+			entities = []chirptext.Entity{}
+		}
+		media := mediaByChirp[c.ID]
+		if media == nil {
+			media = []mediaResponse{}
+		}
+		cr := chirpResponse{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+			UserID:    c.UserID,
+			Author:    authors[c.UserID],
+			Entities:  entities,
+			Media:     media,
+			Status:    c.Status,
+		}
+		if c.PublishAt.Valid {
+			cr.PublishAt = &c.PublishAt.Time
+		}
+		resp = append(resp, cr)
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) chirpResponse(ctx context.Context, chirp database.Chirp) (chirpResponse, error) {
+	resp, err := cfg.chirpsResponse(ctx, []database.Chirp{chirp})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return resp[0], nil
+}
+
+func (cfg *apiConfig) respondWithChirps(w http.ResponseWriter, r *http.Request, code int, chirps []database.Chirp) {
+	resp, err := cfg.chirpsResponse(r.Context(), chirps)
+	if err != nil {
+		log.Printf("unable to build chirps response: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirps")
+		return
+	}
+	respondWithJSON(w, code, resp)
+}
+
+func (cfg *apiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, code int, chirp database.Chirp) {
+	resp, err := cfg.chirpResponse(r.Context(), chirp)
+	if err != nil {
+		log.Printf("unable to build chirp response: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirp")
+		return
+	}
+	respondWithJSON(w, code, resp)
+}
+
+// storeChirpMetadata records what was extracted from a chirp body when it was
+// validated: its entities and the filter terms it was flagged for.
+func storeChirpMetadata(ctx context.Context, q *database.Queries, chirp database.Chirp, flagged []string) error {
+	if err := storeChirpEntities(ctx, q, chirp); err != nil {
+		return err
+	}
+	for _, term := range flagged {
+		if err := q.AddChirpFilterFlag(ctx, database.AddChirpFilterFlagParams{
+			ChirpID: chirp.ID,
+			Term:    term,
+		}); err != nil {
+			return fmt.Errorf("unable to flag chirp for '%s': %w", term, err)
+		}
+	}
+	return nil
+}
+
+func parseMediaIDs(ids []string, maxMedia int) ([]uuid.UUID, error) {
+	if len(ids) > maxMedia {
+		return nil, fmt.Errorf("a chirp can have at most %d media attachments", maxMedia)
+	}
+	var out []uuid.UUID
+	for _, id := range ids {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid media ID '%s'", id)
+		}
+		if slices.Contains(out, parsed) {
+			return nil, fmt.Errorf("duplicate media ID '%s'", id)
+		}
+		out = append(out, parsed)
+	}
+	return out, nil
 }
 
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
 	var chirp chirpPayload
 	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(&chirp); err != nil {
+	if err := decodeJSON(w, r, &chirp); err != nil {
 		log.Printf("Unable to decode request: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to decode request")
 		return
@@ -52,34 +190,85 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, "unable to validate user's JWT")
 		return
 	}
-	cleanedMsg, err := validateChirp(chirp.Body)
+	user, ok := cfg.activeUser(w, r, userID)
+	if !ok {
+		return
+	}
+	limits := cfg.limitsOf(user)
+	if !cfg.checkChirpRate(w, r, user, limits) {
+		return
+	}
+	cleanedMsg, flagged, err := cfg.validateChirp(chirp.Body, limits)
 	if err != nil {
 		log.Printf("chirp invalid: %v", err)
 		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mediaIDs, err := parseMediaIDs(chirp.MediaIDs, limits.MaxMediaPerChirp)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status, publishAt, err := chirpStatus(chirp.Draft, chirp.PublishAt, time.Now().UTC())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
 	}
-	userChirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleanedMsg,
-		UserID: userID,
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	userChirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:      cleanedMsg,
+		UserID:    userID,
+		Status:    status,
+		PublishAt: publishAt,
 	})
 	if err != nil {
 		log.Printf("Unable to create chirp: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
 	}
-	respondWithJSON(w, http.StatusCreated, chirpResponse{
-		ID:        userChirp.ID,
-		CreatedAt: userChirp.CreatedAt,
-		UpdatedAt: userChirp.UpdatedAt,
-		Body:      userChirp.Body,
-		UserID:    userChirp.UserID,
-	})
+	if err := storeChirpMetadata(r.Context(), qtx, userChirp, flagged); err != nil {
+		log.Printf("unable to store chirp metadata: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
+	}
+	if err := attachMedia(r.Context(), qtx, userChirp, mediaIDs); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "unknown or already attached media")
+			return
+		}
+		log.Printf("unable to attach media to chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
+	}
+	if err := cfg.chirpPublished(r.Context(), qtx, userChirp); err != nil {
+		log.Printf("unable to add chirp to timelines: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit chirp creation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusCreated, userChirp)
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	var chirps []database.Chirp
+	var pinned []uuid.UUID
+	var pinnedFirst bool
 	var err error
+	viewerID := cfg.optionalUserID(r)
 	author_id := r.URL.Query().Get("author_id")
 	if author_id == "" {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.db.GetAllChirps(r.Context(), viewerID)
 		if err != nil {
 			log.Printf("unable to retrieve chirps from db: %v", err)
 			respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirps")
@@ -93,7 +282,10 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		// Return only the chirps for the user ID author_id
-		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), userID)
+		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), database.GetChirpsByUserIDParams{
+			UserID:   userID,
+			ViewerID: viewerID,
+		})
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				log.Printf("user not found: %v", err)
@@ -103,6 +295,15 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirps for that user")
 			return
 		}
+		pinnedFirst = r.URL.Query().Get("pinned_first") == "true"
+		if pinnedFirst {
+			pinned, err = cfg.db.GetPinnedChirpIDs(r.Context(), userID)
+			if err != nil {
+				log.Printf("unable to retrieve pinned chirps: %v", err)
+				respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirps for that user")
+				return
+			}
+		}
 	}
 	sortOrder := r.URL.Query().Get("sort")
 	if sortOrder == "desc" {
@@ -115,17 +316,23 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 			return 0
 		})
 	}
-	var resp []chirpResponse
-	for _, c := range chirps {
-		resp = append(resp, chirpResponse{
-			ID:        c.ID,
-			CreatedAt: c.CreatedAt,
-			UpdatedAt: c.UpdatedAt,
-			Body:      c.Body,
-			UserID:    c.UserID,
+	// Pinned chirps come first, most recently pinned first, and the others
+	// keep their order.
+	if pinnedFirst {
+		slices.SortStableFunc(chirps, func(a, b database.Chirp) int {
+			i, j := slices.Index(pinned, a.ID), slices.Index(pinned, b.ID)
+			switch {
+			case i >= 0 && j >= 0:
+				return i - j
+			case i >= 0:
+				return -1
+			case j >= 0:
+				return 1
+			}
+			return 0
 		})
 	}
-	respondWithJSON(w, http.StatusOK, resp)
+	cfg.respondWithChirps(w, r, http.StatusOK, chirps)
 }
 
 func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
@@ -147,34 +354,76 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, "unable to retrieve chirp")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, chirpResponse{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
-	})
+	if dbChirp.DeletedAt.Valid {
+		respondWithJSON(w, http.StatusGone, chirpTombstone{
+			ID:        dbChirp.ID,
+			DeletedAt: dbChirp.DeletedAt.Time,
+			Error:     "chirp has been deleted",
+		})
+		return
+	}
+	visible, err := cfg.chirpVisibleTo(r.Context(), dbChirp, cfg.optionalUserID(r))
+	if err != nil {
+		log.Printf("unable to check visibility of chirp '%s': %v", dbChirp.ID, err)
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "unable to retrieve chirp")
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusOK, dbChirp)
 }
 
-func validateChirp(msg string) (string, error) {
-	if len(msg) > chirpMaxLen {
-		return "", errors.New("chirp is too long, max size is 140 characters")
+// chirpVisibleTo reports whether a chirp that isn't deleted can be seen by
+// viewerID. Drafts, scheduled chirps, chirps hidden by moderators and chirps
+// of shadow-banned users only exist for their author, and users who blocked
+// each other don't see each other's chirps.
+func (cfg *apiConfig) chirpVisibleTo(ctx context.Context, chirp database.Chirp, viewerID uuid.UUID) (bool, error) {
+	if chirp.UserID == viewerID {
+		return true, nil
+	}
+	if chirp.Status != chirpStatusPublished || chirp.HiddenAt.Valid {
+		return false, nil
+	}
+	author, err := cfg.db.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return false, err
+	}
+	if author.State == userStateShadowBanned {
+		return false, nil
+	}
+	blocked, err := cfg.db.IsBlocked(ctx, database.IsBlockedParams{UserA: viewerID, UserB: author.ID})
+	if err != nil {
+		return false, err
 	}
-	return removeProfaneWords(msg), nil
+	return !blocked, nil
 }
 
-func removeProfaneWords(msg string) string {
-	profaneWords := []string{"kerfuffle", "sharbert", "fornax"}
-	ret := []string{}
-	fields := strings.Fields(msg)
-	for _, w := range fields {
-		if slices.Contains(profaneWords, strings.ToLower(w)) {
-			ret = append(ret, "****")
-		} else {
-			ret = append(ret, w)
-		}
+// validateChirp returns the normalized and cleaned version of msg, along with
+// the filter terms it was flagged for, or an error if msg can't be posted.
+// Length is counted in user-perceived characters, see handlerGetLimits, and
+// limited by the tier of the author.
+func (cfg *apiConfig) validateChirp(msg string, limits entitlements.Limits) (string, []string, error) {
+	return cfg.validateText(msg, "chirp", limits.ChirpMaxLength)
+}
+
+// validateText is validateChirp for any user text of at most maxLen
+// characters, kind naming it in error messages.
+func (cfg *apiConfig) validateText(msg, kind string, maxLen int) (string, []string, error) {
+	if len(msg) > maxLen*chirptext.MaxBytesPerChar {
+		return "", nil, fmt.Errorf("%s is too long, max length is %d characters", kind, maxLen)
+	}
+	msg = chirptext.Normalize(msg)
+	if err := chirptext.CheckCharacters(msg); err != nil {
+		return "", nil, err
+	}
+	if chirptext.Length(msg, chirpURLWeight) > maxLen {
+		return "", nil, fmt.Errorf("%s is too long, max length is %d characters", kind, maxLen)
 	}
-	return strings.Join(ret, " ")
+	res := cfg.filter.Load().Apply(msg)
+	if res.Rejected {
+		return "", nil, fmt.Errorf("%s contains a forbidden term", kind)
+	}
+	return res.Text, res.Flagged(), nil
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -201,7 +450,7 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpUUID)
-	if err != nil {
+	if err != nil || dbChirp.DeletedAt.Valid {
 		respondWithError(w, http.StatusNotFound, "chirp not found")
 		return
 	}
@@ -210,10 +459,147 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusForbidden, "unauthorized request")
 		return
 	}
-	if err = cfg.db.DeleteChirp(r.Context(), dbChirp.ID); err != nil {
+	// Drafts and scheduled chirps were never seen by anyone, so cancelling
+	// them deletes them for good.
+	if dbChirp.Status != chirpStatusPublished {
+		files, err := cfg.db.DeleteUnpublishedChirp(r.Context(), database.DeleteUnpublishedChirpParams{
+			ID:     dbChirp.ID,
+			UserID: userID,
+		})
+		if err != nil {
+			log.Printf("unable to cancel scheduled chirp: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to cancel chirp")
+			return
+		}
+		for _, f := range files {
+			cfg.deleteBlobs(r.Context(), f.StorageKey, f.ThumbnailKey.String)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// The chirp is only marked as deleted: its author can restore it during
+	// the undo window, and it is purged once the retention period is over.
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp from DB")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	if err = qtx.SoftDeleteChirp(r.Context(), dbChirp.ID); err != nil {
 		log.Printf("unable to delete chirp by id: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp from DB")
 		return
 	}
+	if err := cfg.chirpDeleted(r.Context(), qtx, dbChirp); err != nil {
+		log.Printf("unable to record deletion of chirp '%s': %v", dbChirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp from DB")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit chirp deletion: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp from DB")
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// chirpDeleted streams the deletion of a published chirp and publishes its
+// ChirpDeleted event.
+func (cfg *apiConfig) chirpDeleted(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := cfg.emitChirpEvent(ctx, q, chirpEventDeleted, chirp); err != nil {
+		return err
+	}
+	return events.Publish(ctx, q, events.ChirpDeleted{ChirpID: chirp.ID, AuthorID: chirp.UserID})
+}
+
+// chirpRestored streams a restored chirp again and publishes its
+// ChirpRestored event.
+func (cfg *apiConfig) chirpRestored(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := cfg.emitChirpEvent(ctx, q, chirpEventCreated, chirp); err != nil {
+		return err
+	}
+	return events.Publish(ctx, q, events.ChirpRestored{ChirpID: chirp.ID, AuthorID: chirp.UserID})
+}
+
+// restoreError returns the status and the message of the error preventing
+// userID from restoring a chirp, or 0 if they can. Authors can only restore
+// the chirps they deleted themselves, within their undo window.
+func restoreError(chirp database.Chirp, userID uuid.UUID, undoWindow time.Duration, now time.Time) (int, string) {
+	switch {
+	case chirp.UserID != userID:
+		return http.StatusForbidden, "unauthorized request"
+	case !chirp.DeletedAt.Valid:
+		return http.StatusConflict, "chirp is not deleted"
+	case chirp.DeletedByModerator:
+		return http.StatusForbidden, "chirp was deleted by a moderator"
+	case now.Sub(chirp.DeletedAt.Time) > undoWindow:
+		return http.StatusGone, "the undo window for this chirp has expired"
+	}
+	return 0, ""
+}
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("unable to get bearer token: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	// Suspended users can't bring their chirps back either
+	user, ok := cfg.activeUser(w, r, userID)
+	if !ok {
+		return
+	}
+	if code, msg := restoreError(dbChirp, userID, time.Duration(cfg.limitsOf(user).UndoWindow), time.Now()); code != 0 {
+		respondWithError(w, code, msg)
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to restore chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	restored, err := qtx.RestoreChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Restored or deleted by a moderator since it was read
+		respondWithError(w, http.StatusConflict, "chirp can't be restored")
+		return
+	}
+	if err != nil {
+		log.Printf("unable to restore chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to restore chirp")
+		return
+	}
+	if err := cfg.chirpRestored(r.Context(), qtx, restored); err != nil {
+		log.Printf("unable to record restoration of chirp '%s': %v", restored.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to restore chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit chirp restoration: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to restore chirp")
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusOK, restored)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestRestoreError(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	authorID := uuid.New()
+	deleted := func(ago time.Duration, byModerator bool) database.Chirp {
+		return database.Chirp{
+			ID:                 uuid.New(),
+			UserID:             authorID,
+			DeletedAt:          sql.NullTime{Time: now.Add(-ago), Valid: true},
+			DeletedByModerator: byModerator,
+		}
+	}
+
+	cases := []struct {
+		name   string
+		chirp  database.Chirp
+		userID uuid.UUID
+		want   int
+	}{
+		{name: "deleted by author", chirp: deleted(time.Minute, false), userID: authorID, want: 0},
+		{name: "deleted by moderator", chirp: deleted(time.Minute, true), userID: authorID, want: http.StatusForbidden},
+		{name: "other user", chirp: deleted(time.Minute, false), userID: uuid.New(), want: http.StatusForbidden},
+		{name: "not deleted", chirp: database.Chirp{UserID: authorID}, userID: authorID, want: http.StatusConflict},
+		{name: "undo window expired", chirp: deleted(6*time.Minute, false), userID: authorID, want: http.StatusGone},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, _ := restoreError(c.chirp, c.userID, 5*time.Minute, now)
+			if got != c.want {
+				t.Errorf("want %d, got %d", c.want, got)
+			}
+		})
+	}
+}
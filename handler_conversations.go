@@ -0,0 +1,408 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	messageMaxLen = 1000
+	// conversationMaxParticipants is the size limit of group conversations,
+	// creator included.
+	conversationMaxParticipants = 10
+)
+
+type participantResponse struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type conversationResponse struct {
+	ID           uuid.UUID             `json:"id"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	Participants []participantResponse `json:"participants"`
+	UnreadCount  int64                 `json:"unread_count"`
+}
+
+type messageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+	// ReadBy lists the other participants who have read the message.
+	ReadBy []uuid.UUID `json:"read_by"`
+}
+
+// newMessageResponse builds the API representation of a message, with its
+// read receipts taken from participants.
+func newMessageResponse(m database.Message, participants []database.ConversationParticipant) messageResponse {
+	resp := messageResponse{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+		CreatedAt:      m.CreatedAt,
+		ReadBy:         []uuid.UUID{},
+	}
+	for _, p := range participants {
+		if p.UserID != m.SenderID && p.LastReadAt.Valid && !p.LastReadAt.Time.Before(m.CreatedAt) {
+			resp.ReadBy = append(resp.ReadBy, p.UserID)
+		}
+	}
+	return resp
+}
+
+func newParticipantResponses(participants []database.ConversationParticipant) []participantResponse {
+	resp := make([]participantResponse, 0, len(participants))
+	for _, p := range participants {
+		pr := participantResponse{UserID: p.UserID, JoinedAt: p.JoinedAt}
+		if p.LastReadAt.Valid {
+			pr.LastReadAt = &p.LastReadAt.Time
+		}
+		resp = append(resp, pr)
+	}
+	return resp
+}
+
+// conversationParticipant authenticates the request and checks that the user
+// takes part in the conversation in the path, whose ID is returned along with
+// the user's, or writes an error response and returns false.
+func (cfg *apiConfig) conversationParticipant(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return uuid.Nil, uuid.Nil, false
+	}
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid conversation ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	ok, err := cfg.db.IsConversationParticipant(r.Context(), database.IsConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		log.Printf("unable to check participants of conversation '%s': %v", conversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve conversation")
+		return uuid.Nil, uuid.Nil, false
+	}
+	// Conversations of others don't exist as far as the user knows
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "conversation not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, conversationID, true
+}
+
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	defer r.Body.Close()
+	var payload struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	members := []uuid.UUID{userID}
+	for _, id := range payload.ParticipantIDs {
+		if !slices.Contains(members, id) {
+			members = append(members, id)
+		}
+	}
+	if len(members) < 2 {
+		respondWithError(w, http.StatusBadRequest, "a conversation needs at least one other participant")
+		return
+	}
+	if len(members) > conversationMaxParticipants {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("a conversation can have at most %d participants", conversationMaxParticipants))
+		return
+	}
+	for _, id := range members[1:] {
+		if _, err := cfg.db.GetUserByID(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown user '%s'", id))
+				return
+			}
+			log.Printf("unable to get user '%s': %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+			return
+		}
+		blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{UserA: userID, UserB: id})
+		if err != nil {
+			log.Printf("unable to check blocks between '%s' and '%s': %v", userID, id, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("you can't message user '%s'", id))
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	conversation, err := qtx.CreateConversation(r.Context())
+	if err != nil {
+		log.Printf("unable to create conversation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+		return
+	}
+	for _, id := range members {
+		if err := qtx.AddConversationParticipant(r.Context(), database.AddConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         id,
+		}); err != nil {
+			log.Printf("unable to add participant '%s': %v", id, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+			return
+		}
+	}
+	participants, err := qtx.GetConversationParticipants(r.Context(), conversation.ID)
+	if err != nil {
+		log.Printf("unable to retrieve participants: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit conversation creation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create conversation")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, conversationResponse{
+		ID:           conversation.ID,
+		CreatedAt:    conversation.CreatedAt,
+		UpdatedAt:    conversation.UpdatedAt,
+		Participants: newParticipantResponses(participants),
+	})
+}
+
+// handlerGetConversations returns the conversations of the user, the most
+// recently active first.
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := cfg.db.GetConversationsByUserID(r.Context(), database.GetConversationsByUserIDParams{
+		UserID:     userID,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve conversations: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve conversations")
+		return
+	}
+	resp := make([]conversationResponse, 0, len(rows))
+	for _, row := range rows {
+		participants, err := cfg.db.GetConversationParticipants(r.Context(), row.ID)
+		if err != nil {
+			log.Printf("unable to retrieve participants of conversation '%s': %v", row.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to retrieve conversations")
+			return
+		}
+		resp = append(resp, conversationResponse{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Participants: newParticipantResponses(participants),
+			UnreadCount:  row.UnreadCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerGetMessages returns the messages of a conversation, newest first.
+// Older messages are requested by passing the ID of the last message of a
+// page as before.
+func (cfg *apiConfig) handlerGetMessages(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok {
+		return
+	}
+	limit, _, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.GetMessagesParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+		PageSize:       limit,
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid message ID")
+			return
+		}
+		params.BeforeID = uuid.NullUUID{UUID: before, Valid: true}
+	}
+	messages, err := cfg.db.GetMessages(r.Context(), params)
+	if err != nil {
+		log.Printf("unable to retrieve messages of conversation '%s': %v", conversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve messages")
+		return
+	}
+	participants, err := cfg.db.GetConversationParticipants(r.Context(), conversationID)
+	if err != nil {
+		log.Printf("unable to retrieve participants of conversation '%s': %v", conversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve messages")
+		return
+	}
+	resp := make([]messageResponse, 0, len(messages))
+	for _, m := range messages {
+		resp = append(resp, newMessageResponse(m, participants))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerCreateMessage(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok {
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	defer r.Body.Close()
+	var payload struct {
+		Body string `json:"body"`
+	}
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	if payload.Body == "" {
+		respondWithError(w, http.StatusBadRequest, "message is empty")
+		return
+	}
+	body, _, err := cfg.validateText(payload.Body, "message", messageMaxLen)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	row, err := cfg.db.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           body,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusForbidden, "you can't message this conversation")
+			return
+		}
+		log.Printf("unable to create message: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to send message")
+		return
+	}
+	// Sending a message implies having read the conversation
+	if err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		log.Printf("unable to mark conversation '%s' as read: %v", conversationID, err)
+	}
+	respondWithJSON(w, http.StatusCreated, newMessageResponse(database.Message(row), nil))
+}
+
+// handlerMarkConversationRead records that the user has read every message
+// of the conversation so far, which the other participants see as read
+// receipts.
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		log.Printf("unable to mark conversation '%s' as read: %v", conversationID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to mark conversation as read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDeleteMessage deletes a message for the user only: the other
+// participants still see it.
+func (cfg *apiConfig) handlerDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok {
+		return
+	}
+	messageID, err := uuid.Parse(r.PathValue("messageID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+	n, err := cfg.db.DeleteMessageForUser(r.Context(), database.DeleteMessageForUserParams{
+		UserID:         userID,
+		MessageID:      messageID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		log.Printf("unable to delete message '%s': %v", messageID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete message")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+// storeChirpEntities records the hashtags and the mentioned users of a chirp.
+// Mentions resolve by handle, and the ones that don't match a known user are
+// left as plain text.
+func storeChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	for _, tag := range chirptext.Hashtags(chirp.Body) {
+		if err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID: chirp.ID,
+			Tag:     tag,
+		}); err != nil {
+			return fmt.Errorf("unable to add hashtag '%s': %w", tag, err)
+		}
+	}
+	for _, mention := range chirptext.Mentions(chirp.Body) {
+		user, err := q.GetUserByHandle(ctx, mention)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return fmt.Errorf("unable to resolve mention '%s': %w", mention, err)
+		}
+		if err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID: chirp.ID,
+			UserID:  user.ID,
+		}); err != nil {
+			return fmt.Errorf("unable to add mention of '%s': %w", mention, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetChirpsByTag(w http.ResponseWriter, r *http.Request) {
+	tag := chirptext.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid tag")
+		return
+	}
+	chirps, err := cfg.db.GetChirpsByHashtag(r.Context(), database.GetChirpsByHashtagParams{
+		Tag:      tag,
+		ViewerID: cfg.optionalUserID(r),
+	})
+	if err != nil {
+		log.Printf("unable to retrieve chirps for tag '%s': %v", tag, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve chirps")
+		return
+	}
+	cfg.respondWithChirps(w, r, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerGetUserMentions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Invalid user ID: %v", err)
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	chirps, err := cfg.db.GetChirpsMentioningUser(r.Context(), database.GetChirpsMentioningUserParams{
+		UserID:   userID,
+		ViewerID: cfg.optionalUserID(r),
+	})
+	if err != nil {
+		log.Printf("unable to retrieve mentions of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve mentions")
+		return
+	}
+	cfg.respondWithChirps(w, r, http.StatusOK, chirps)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	eventsDispatchInterval = 2 * time.Second
+	outboxPruneInterval    = time.Hour
+	// outboxRetention is how long handled events are kept in the outbox.
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// subscribeEvents registers the in-process subscribers of the domain events.
+func (cfg *apiConfig) subscribeEvents() {
+	cfg.events.Subscribe("mention notifications", cfg.notifyChirpMentions, events.TypeChirpCreated)
+	cfg.events.Subscribe("like notifications", cfg.notifyChirpLike, events.TypeChirpLiked)
+	cfg.events.Subscribe("follow notifications", cfg.notifyFollow, events.TypeUserFollowed)
+	cfg.events.Subscribe("webhooks", cfg.queueWebhookDeliveries, events.Types...)
+	if cfg.federation != nil {
+		cfg.events.Subscribe("federation", cfg.federateChirp, events.TypeChirpCreated, events.TypeChirpDeleted, events.TypeChirpRestored, events.TypeChirpHidden)
+	}
+}
+
+// dispatchEvents hands the new domain events to their subscribers.
+func (cfg *apiConfig) dispatchEvents(ctx context.Context) error {
+	return cfg.events.Dispatch(ctx, cfg.db)
+}
+
+// pruneOutbox deletes the domain events every subscriber handled more than
+// the retention period ago.
+func (cfg *apiConfig) pruneOutbox(ctx context.Context) error {
+	n, err := cfg.db.PruneOutboxEvents(ctx, time.Now().UTC().Add(-outboxRetention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("pruned %d events from the outbox", n)
+	}
+	return nil
+}
+
+// notifyChirpMentions notifies the users mentioned in a new chirp, unless it
+// was deleted in the meantime. Mentions are marked as notified in the
+// transaction of their notifications, so handling the event again is
+// harmless.
+func (cfg *apiConfig) notifyChirpMentions(ctx context.Context, r events.Record) error {
+	e, err := r.Decode()
+	if err != nil {
+		return err
+	}
+	chirp, err := cfg.db.GetChirpByID(ctx, e.(*events.ChirpCreated).ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if chirp.DeletedAt.Valid || chirp.HiddenAt.Valid {
+		return nil
+	}
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := notifyMentions(ctx, cfg.db.WithTx(tx), chirp); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// notifyChirpLike notifies the author of a liked chirp, unless it was deleted
+// in the meantime. A liker is added once to the notification of a chirp, so
+// handling the event again is harmless until it is read.
+func (cfg *apiConfig) notifyChirpLike(ctx context.Context, r events.Record) error {
+	e, err := r.Decode()
+	if err != nil {
+		return err
+	}
+	like := e.(*events.ChirpLiked)
+	chirp, err := cfg.db.GetChirpByID(ctx, like.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if chirp.DeletedAt.Valid {
+		return nil
+	}
+	return cfg.notifyInTx(ctx, like.AuthorID, like.UserID, notificationLike, like.ChirpID)
+}
+
+// notifyFollow notifies a user of their new follower.
+func (cfg *apiConfig) notifyFollow(ctx context.Context, r events.Record) error {
+	e, err := r.Decode()
+	if err != nil {
+		return err
+	}
+	follow := e.(*events.UserFollowed)
+	return cfg.notifyInTx(ctx, follow.FolloweeID, follow.FollowerID, notificationFollow, uuid.Nil)
+}
+
+// notifyInTx is notify in a transaction of its own, so that live connections
+// are only told about recorded notifications.
+func (cfg *apiConfig) notifyInTx(ctx context.Context, recipient, actor uuid.UUID, notifType string, chirpID uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := notify(ctx, cfg.db.WithTx(tx), recipient, actor, notifType, chirpID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
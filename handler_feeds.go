@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/feed"
+	"github.com/google/uuid"
+)
+
+// feedSize is the number of chirps in a feed, the latest ones.
+const feedSize = 50
+
+// baseURL returns the URL the server is reached at, used to build the
+// absolute URLs of feeds.
+func (cfg *apiConfig) baseURL(r *http.Request) string {
+	if cfg.publicURL != "" {
+		return cfg.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handlerGetUserFeed serves the latest public chirps of a user as a feed, in
+// the format of the extension of the path.
+func (cfg *apiConfig) handlerGetUserFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	updatedAt, err := cfg.db.GetUserFeedUpdatedAt(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get the feed of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+		return
+	}
+	if notModifiedSince(w, r, updatedAt) {
+		return
+	}
+	chirps, err := cfg.db.GetUserFeedChirps(r.Context(), database.GetUserFeedChirpsParams{
+		UserID:    userID,
+		MaxChirps: feedSize,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve chirps of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+		return
+	}
+	authors, err := cfg.authorsByID(r.Context(), []uuid.UUID{userID})
+	if err != nil {
+		log.Printf("unable to get profile of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+		return
+	}
+	base := cfg.baseURL(r)
+	author := authorName(authors[userID])
+	title := "Chirps"
+	if author != "" {
+		title = "Chirps of " + author
+	}
+	// Users without a handle have no profile page.
+	link, ok := profileURL(base, authors[userID].Handle)
+	if !ok {
+		link = base + "/api/chirps?author_id=" + userID.String()
+	}
+	f := &feed.Feed{
+		ID:      base + "/users/" + userID.String() + "/feed",
+		Title:   title,
+		Link:    link,
+		FeedURL: base + r.URL.Path,
+		Author:  author,
+		Updated: updatedAt,
+	}
+	if err := cfg.respondWithFeed(w, r, f, chirps); err != nil {
+		log.Printf("unable to render the feed of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+	}
+}
+
+// handlerGetTagFeed serves the latest public chirps with a hashtag as a feed,
+// in the format of the extension of the path.
+func (cfg *apiConfig) handlerGetTagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := chirptext.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid tag")
+		return
+	}
+	updatedAt, err := cfg.db.GetTagFeedUpdatedAt(r.Context(), tag)
+	if err != nil {
+		log.Printf("unable to get the feed of tag '%s': %v", tag, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+		return
+	}
+	if notModifiedSince(w, r, updatedAt) {
+		return
+	}
+	chirps, err := cfg.db.GetTagFeedChirps(r.Context(), database.GetTagFeedChirpsParams{
+		Tag:       tag,
+		MaxChirps: feedSize,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve chirps for tag '%s': %v", tag, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+		return
+	}
+	base := cfg.baseURL(r)
+	f := &feed.Feed{
+		ID:      base + "/tags/" + url.PathEscape(tag) + "/feed",
+		Title:   "Chirps tagged #" + tag,
+		Link:    base + "/api/tags/" + url.PathEscape(tag) + "/chirps",
+		FeedURL: base + r.URL.Path,
+		Updated: updatedAt,
+	}
+	if err := cfg.respondWithFeed(w, r, f, chirps); err != nil {
+		log.Printf("unable to render the feed of tag '%s': %v", tag, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve feed")
+	}
+}
+
+// notModifiedSince writes 304 Not Modified and returns true if the client
+// has the feed last changed at updated, given If-Modified-Since, so that its
+// chirps aren't loaded.
+func notModifiedSince(w http.ResponseWriter, r *http.Request, updated time.Time) bool {
+	if !feed.NotModifiedSince(r, updated) {
+		return false
+	}
+	w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// respondWithFeed adds chirps, newest first, to a feed and writes it, or 304
+// Not Modified if the client already has it.
+func (cfg *apiConfig) respondWithFeed(w http.ResponseWriter, r *http.Request, f *feed.Feed, chirps []database.Chirp) error {
+	items, err := cfg.feedItems(r.Context(), cfg.baseURL(r), chirps)
+	if err != nil {
+		return err
+	}
+	f.Items = items
+	body, contentType, err := f.Render(feed.Format(strings.TrimPrefix(path.Ext(r.URL.Path), ".")))
+	if err != nil {
+		return err
+	}
+	etag := feed.ETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", f.Updated.UTC().Format(http.TimeFormat))
+	if feed.NotModified(r, etag, f.Updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return nil
+}
+
+// feedItems returns the feed items of chirps, newest first.
+func (cfg *apiConfig) feedItems(ctx context.Context, base string, chirps []database.Chirp) ([]feed.Item, error) {
+	var authorIDs []uuid.UUID
+	for _, c := range chirps {
+		if !slices.Contains(authorIDs, c.UserID) {
+			authorIDs = append(authorIDs, c.UserID)
+		}
+	}
+	authors, err := cfg.authorsByID(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]feed.Item, 0, len(chirps))
+	for _, c := range chirps {
+		link := base + "/chirps/" + c.ID.String()
+		items = append(items, feed.Item{
+			ID:        link,
+			URL:       link,
+			Text:      c.Body,
+			Author:    authorName(authors[c.UserID]),
+			Published: c.CreatedAt,
+			Updated:   c.UpdatedAt,
+		})
+	}
+	return items, nil
+}
+
+// authorName is the name of an author in feeds: their display name, or their
+// handle if they have none.
+func authorName(a authorResponse) string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	if a.Handle != "" {
+		return "@" + a.Handle
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/filter"
+	"github.com/google/uuid"
+)
+
+const filterReloadInterval = 30 * time.Second
+
+type filterTermPayload struct {
+	Term   string `json:"term"`
+	Action string `json:"action"`
+}
+
+type filterTermResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+}
+
+// loadFilter builds a new filter engine from the terms in the DB and swaps it
+// in. Chirps being validated keep using the engine they started with.
+func (cfg *apiConfig) loadFilter(ctx context.Context) error {
+	dbTerms, err := cfg.db.GetFilterTerms(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get filter terms: %w", err)
+	}
+	terms := make([]filter.Term, len(dbTerms))
+	for i, t := range dbTerms {
+		terms[i] = filter.Term{Term: t.Term, Action: filter.Action(t.Action)}
+	}
+	engine, err := filter.New(terms)
+	if err != nil {
+		return err
+	}
+	cfg.filter.Store(engine)
+	return nil
+}
+
+func (cfg *apiConfig) handlerGetFilterTerms(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	terms, err := cfg.db.GetFilterTerms(r.Context())
+	if err != nil {
+		log.Printf("unable to get filter terms: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get filter terms")
+		return
+	}
+	resp := []filterTermResponse{}
+	for _, t := range terms {
+		resp = append(resp, newFilterTermResponse(t))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerUpsertFilterTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	defer r.Body.Close()
+	var payload filterTermPayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	action := filter.Action(payload.Action)
+	if action == "" {
+		action = filter.ActionMask
+	}
+	if !action.Valid() {
+		respondWithError(w, http.StatusBadRequest, "action must be one of mask, reject or flag")
+		return
+	}
+	term := filter.NormalizeTerm(payload.Term)
+	if term == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid term")
+		return
+	}
+	dbTerm, err := cfg.db.UpsertFilterTerm(r.Context(), database.UpsertFilterTermParams{
+		Term:   term,
+		Action: string(action),
+	})
+	if err != nil {
+		log.Printf("unable to save filter term: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to save filter term")
+		return
+	}
+	if err := cfg.loadFilter(r.Context()); err != nil {
+		log.Printf("unable to reload filter terms: %v", err)
+	}
+	respondWithJSON(w, http.StatusOK, newFilterTermResponse(dbTerm))
+}
+
+func (cfg *apiConfig) handlerDeleteFilterTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("termID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid term ID")
+		return
+	}
+	n, err := cfg.db.DeleteFilterTerm(r.Context(), id)
+	if err != nil {
+		log.Printf("unable to delete filter term: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete filter term")
+		return
+	}
+	if n == 0 {
+		respondWithError(w, http.StatusNotFound, "filter term not found")
+		return
+	}
+	if err := cfg.loadFilter(r.Context()); err != nil {
+		log.Printf("unable to reload filter terms: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newFilterTermResponse(t database.FilterTerm) filterTermResponse {
+	return filterTermResponse{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		Term:      t.Term,
+		Action:    t.Action,
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/timeline"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followListResponse struct {
+	Followers int64            `json:"followers"`
+	Following int64            `json:"following"`
+	Users     []followResponse `json:"users"`
+}
+
+type timelineResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "follow")
+	if !ok {
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+		return
+	}
+	blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{UserA: userID, UserB: targetID})
+	if err != nil {
+		log.Printf("unable to check blocks between '%s' and '%s': %v", userID, targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "you can't follow this user")
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	n, err := qtx.FollowUser(r.Context(), database.FollowUserParams{FollowerID: userID, FolloweeID: targetID})
+	if err != nil {
+		log.Printf("unable to follow user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+		return
+	}
+	// Following someone twice is a no-op
+	if n > 0 {
+		if err := cfg.timeline.Followed(r.Context(), qtx, userID, targetID); err != nil {
+			log.Printf("unable to update timeline of user '%s': %v", userID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+			return
+		}
+		if err := events.Publish(r.Context(), qtx, events.UserFollowed{FollowerID: userID, FolloweeID: targetID}); err != nil {
+			log.Printf("unable to publish follow of user '%s': %v", targetID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit follow: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to follow user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.targetUser(w, r, "unfollow")
+	if !ok {
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unfollow user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	n, err := qtx.UnfollowUser(r.Context(), database.UnfollowUserParams{FollowerID: userID, FolloweeID: targetID})
+	if err != nil {
+		log.Printf("unable to unfollow user '%s': %v", targetID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unfollow user")
+		return
+	}
+	// Unfollowing someone not followed is a no-op
+	if n > 0 {
+		if err := cfg.timeline.Unfollowed(r.Context(), qtx, userID, targetID); err != nil {
+			log.Printf("unable to update timeline of user '%s': %v", userID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to unfollow user")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit unfollow: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unfollow user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollowList(w, r, func(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]followResponse, error) {
+		rows, err := cfg.db.GetFollowers(ctx, database.GetFollowersParams{FolloweeID: userID, Limit: limit, Offset: offset})
+		users := make([]followResponse, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithFollowList(w, r, func(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]followResponse, error) {
+		rows, err := cfg.db.GetFollowing(ctx, database.GetFollowingParams{FollowerID: userID, Limit: limit, Offset: offset})
+		users := make([]followResponse, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, followResponse{UserID: row.UserID, FollowedAt: row.CreatedAt})
+		}
+		return users, err
+	})
+}
+
+// respondWithFollowList writes a page of the followers or followees of the
+// user in the path, as returned by list, along with the follow counts of
+// that user.
+func (cfg *apiConfig) respondWithFollowList(w http.ResponseWriter, r *http.Request, list func(context.Context, uuid.UUID, int32, int32) ([]followResponse, error)) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	counts, err := cfg.db.GetFollowCounts(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to count follows of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve follows")
+		return
+	}
+	users, err := list(r.Context(), userID, limit, offset)
+	if err != nil {
+		log.Printf("unable to list follows of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve follows")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, followListResponse{
+		Followers: counts.Followers,
+		Following: counts.Following,
+		Users:     users,
+	})
+}
+
+// handlerGetTimeline returns the home timeline of the user, newest first. The
+// next page is requested by passing the next_cursor of a page as before.
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	limit, _, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var before *timeline.Cursor
+	if v := r.URL.Query().Get("before"); v != "" {
+		c, err := timeline.DecodeCursor(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		before = &c
+	}
+	chirps, err := cfg.timeline.Page(r.Context(), cfg.db, userID, before, limit)
+	if err != nil {
+		log.Printf("unable to retrieve timeline of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve timeline")
+		return
+	}
+	resp, err := cfg.chirpsResponse(r.Context(), chirps)
+	if err != nil {
+		log.Printf("unable to build timeline response: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve timeline")
+		return
+	}
+	page := timelineResponse{Chirps: resp}
+	if next := timeline.Next(chirps, limit); next != nil {
+		page.NextCursor = next.Encode()
+	}
+	respondWithJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, chirp, ok := cfg.chirpAction(w, r)
+	if !ok {
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to like chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	n, err := qtx.LikeChirp(r.Context(), database.LikeChirpParams{ChirpID: chirp.ID, UserID: userID})
+	if err != nil {
+		log.Printf("unable to like chirp '%s': %v", chirp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to like chirp")
+		return
+	}
+	if n > 0 {
+		if err := events.Publish(r.Context(), qtx, events.ChirpLiked{ChirpID: chirp.ID, AuthorID: chirp.UserID, UserID: userID}); err != nil {
+			log.Printf("unable to publish like of chirp '%s': %v", chirp.ID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to like chirp")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit like: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to like chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	if err := cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{ChirpID: chirpID, UserID: userID}); err != nil {
+		log.Printf("unable to unlike chirp '%s': %v", chirpID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to unlike chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
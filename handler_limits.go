@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// limitsOf returns the limits of the tier of user.
+func (cfg *apiConfig) limitsOf(user database.User) entitlements.Limits {
+	return cfg.entitlements.For(entitlements.TierOf(user.IsChirpyRed.Bool))
+}
+
+// checkChirpRate responds with 429 and returns false if user created as many
+// chirps in the last hour as their tier allows.
+func (cfg *apiConfig) checkChirpRate(w http.ResponseWriter, r *http.Request, user database.User, limits entitlements.Limits) bool {
+	if limits.ChirpsPerHour == 0 {
+		return true
+	}
+	n, err := cfg.db.CountChirpsByUserIDSince(r.Context(), database.CountChirpsByUserIDSinceParams{
+		UserID: user.ID,
+		Since:  time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		log.Printf("unable to count recent chirps of user '%s': %v", user.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create chirp")
+		return false
+	}
+	if n >= int64(limits.ChirpsPerHour) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Hour.Seconds())))
+		respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("you can post at most %d chirps per hour", limits.ChirpsPerHour))
+		return false
+	}
+	return true
+}
+
+// handlerGetLimits returns the limits of the tier of the authenticated user,
+// or of the free tier for anonymous requests.
+func (cfg *apiConfig) handlerGetLimits(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Tier             entitlements.Tier     `json:"tier"`
+		ChirpMaxLength   int                   `json:"chirp_max_length"`
+		URLWeight        int                   `json:"url_weight"`
+		Normalization    string                `json:"normalization"`
+		LengthUnit       string                `json:"length_unit"`
+		MaxMediaPerChirp int                   `json:"max_media_per_chirp"`
+		MaxUploadBytes   int                   `json:"max_upload_bytes"`
+		MessageMaxLength int                   `json:"message_max_length"`
+		UndoWindow       entitlements.Duration `json:"undo_window"`
+		MaxPins          int                   `json:"max_pins"`
+		ChirpsPerHour    int                   `json:"chirps_per_hour"`
+		MaxSessions      int                   `json:"max_sessions"`
+		MaxWebhooks      int                   `json:"max_webhooks"`
+	}
+	tier := entitlements.TierFree
+	if userID := cfg.optionalUserID(r); userID != uuid.Nil {
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			log.Printf("unable to get user '%s': %v", userID, err)
+			respondWithError(w, http.StatusUnauthorized, "unknown user")
+			return
+		}
+		tier = entitlements.TierOf(user.IsChirpyRed.Bool)
+	}
+	limits := cfg.entitlements.For(tier)
+	respondWithJSON(w, http.StatusOK, response{
+		Tier:             tier,
+		ChirpMaxLength:   limits.ChirpMaxLength,
+		URLWeight:        chirpURLWeight,
+		Normalization:    "NFC",
+		LengthUnit:       "grapheme_cluster",
+		MaxMediaPerChirp: limits.MaxMediaPerChirp,
+		MaxUploadBytes:   mediaMaxUploadSize,
+		MessageMaxLength: messageMaxLen,
+		UndoWindow:       limits.UndoWindow,
+		MaxPins:          limits.MaxPins,
+		ChirpsPerHour:    limits.ChirpsPerHour,
+		MaxSessions:      limits.MaxSessions,
+		MaxWebhooks:      limits.MaxWebhooks,
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/media"
+	"github.com/google/uuid"
+)
+
+const mediaMaxUploadSize = 10 << 20
+
+type mediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	Width        int32     `json:"width,omitempty"`
+	Height       int32     `json:"height,omitempty"`
+}
+
+func (cfg *apiConfig) newMediaResponse(m database.MediaFile) mediaResponse {
+	resp := mediaResponse{
+		ID:          m.ID,
+		ContentType: m.ContentType,
+		URL:         cfg.blobStore.URL(m.StorageKey),
+		Width:       m.Width.Int32,
+		Height:      m.Height.Int32,
+	}
+	if m.ThumbnailKey.Valid {
+		resp.ThumbnailURL = cfg.blobStore.URL(m.ThumbnailKey.String)
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		log.Printf("unable to get bearer token: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	// Leave some room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, mediaMaxUploadSize+(1<<20))
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+		log.Printf("unable to read uploaded file: %v", err)
+		respondWithError(w, http.StatusBadRequest, "missing 'file' form field")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, mediaMaxUploadSize+1))
+	if err != nil {
+		log.Printf("unable to read uploaded file: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to read file")
+		return
+	}
+	if len(data) > mediaMaxUploadSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "file is too large")
+		return
+	}
+	processed, err := media.Process(data)
+	if err != nil {
+		log.Printf("unable to process upload: %v", err)
+		if errors.Is(err, media.ErrUnsupportedType) {
+			respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if errors.Is(err, media.ErrTooManyPixels) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "invalid media file")
+		return
+	}
+
+	id := uuid.New()
+	params := database.CreateMediaFileParams{
+		ID:          id,
+		UserID:      userID,
+		ContentType: processed.ContentType,
+		SizeBytes:   int64(len(processed.Data)),
+		StorageKey:  id.String() + processed.Ext,
+	}
+	if err := cfg.putBlob(r.Context(), params.StorageKey, processed.ContentType, processed.Data); err != nil {
+		log.Printf("unable to store upload: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to store file")
+		return
+	}
+	if processed.Thumbnail != nil {
+		params.ThumbnailKey.String, params.ThumbnailKey.Valid = id.String()+"_thumb.jpg", true
+		params.Width.Int32, params.Width.Valid = int32(processed.Width), true
+		params.Height.Int32, params.Height.Valid = int32(processed.Height), true
+		if err := cfg.putBlob(r.Context(), params.ThumbnailKey.String, "image/jpeg", processed.Thumbnail); err != nil {
+			log.Printf("unable to store thumbnail: %v", err)
+			cfg.deleteBlobs(r.Context(), params.StorageKey)
+			respondWithError(w, http.StatusInternalServerError, "unable to store file")
+			return
+		}
+	}
+	mediaFile, err := cfg.db.CreateMediaFile(r.Context(), params)
+	if err != nil {
+		log.Printf("unable to create media file record: %v", err)
+		cfg.deleteBlobs(r.Context(), params.StorageKey, params.ThumbnailKey.String)
+		respondWithError(w, http.StatusInternalServerError, "unable to store file")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, cfg.newMediaResponse(mediaFile))
+}
+
+// deleteBlobs deletes the blobs no longer referenced, like those of an upload
+// that couldn't be recorded, so that they aren't orphaned. It runs even if
+// the request was canceled, and empty keys are skipped.
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := cfg.blobStore.Delete(ctx, key); err != nil {
+			log.Printf("unable to delete orphaned blob '%s': %v", key, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) putBlob(ctx context.Context, key, contentType string, data []byte) error {
+	return cfg.blobStore.Put(ctx, key, contentType, bytes.NewReader(data), int64(len(data)))
+}
+
+// attachMedia links the uploads listed in mediaIDs to a chirp without media,
+// in order. Each upload must belong to the chirp's author and not be attached
+// already.
+func attachMedia(ctx context.Context, q *database.Queries, chirp database.Chirp, mediaIDs []uuid.UUID) error {
+	for i, id := range mediaIDs {
+		_, err := q.AttachMediaFileToChirp(ctx, database.AttachMediaFileToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       id,
+			UserID:   chirp.UserID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	moderationActionHide     = "hide"
+	moderationActionDelete   = "delete"
+	moderationActionDismiss  = "dismiss"
+	moderationActionSuspend  = "suspend"
+	moderationActionAutoHide = "auto_hide"
+
+	defaultSuspension = 7 * 24 * time.Hour
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+type moderationQueueItem struct {
+	ChirpID      uuid.UUID  `json:"chirp_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Body         string     `json:"body"`
+	UserID       uuid.UUID  `json:"user_id"`
+	HiddenAt     *time.Time `json:"hidden_at,omitempty"`
+	ReportCount  int64      `json:"report_count"`
+	Reasons      []string   `json:"reasons"`
+	FlaggedTerms []string   `json:"flagged_terms"`
+}
+
+type moderationActionResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"`
+	ChirpID      *uuid.UUID `json:"chirp_id,omitempty"`
+	TargetUserID *uuid.UUID `json:"target_user_id,omitempty"`
+	Action       string     `json:"action"`
+	Note         string     `json:"note"`
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "invalid report reason")
+		return
+	}
+	dbChirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid || dbChirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	if dbChirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "you can't report your own chirp")
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	n, err := qtx.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if err != nil {
+		log.Printf("unable to create chirp report: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+		return
+	}
+	if n == 0 {
+		// Reporting the same chirp again doesn't count twice.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// Concurrent reports are counted one after the other, so that only one
+	// of them crosses the threshold.
+	dbChirp, err = qtx.LockChirp(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("unable to lock reported chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+		return
+	}
+	reports, err := qtx.CountOpenChirpReports(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("unable to count chirp reports: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+		return
+	}
+	if reports >= int64(cfg.reportHideThreshold) && !dbChirp.HiddenAt.Valid && !dbChirp.DeletedAt.Valid {
+		if _, err := qtx.HideChirp(r.Context(), chirpID); err != nil {
+			log.Printf("unable to hide reported chirp: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+			return
+		}
+		if _, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ChirpID:      uuid.NullUUID{UUID: chirpID, Valid: true},
+			TargetUserID: uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+			Action:       moderationActionAutoHide,
+			Note:         "report threshold reached",
+		}); err != nil {
+			log.Printf("unable to record moderation action: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+			return
+		}
+		if err := cfg.chirpHidden(r.Context(), qtx, dbChirp); err != nil {
+			log.Printf("unable to record hiding of chirp '%s': %v", chirpID, err)
+			respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit chirp report: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to report chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// chirpHidden streams the hiding of a chirp as its deletion, and publishes
+// its ChirpHidden event.
+func (cfg *apiConfig) chirpHidden(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := cfg.emitChirpEvent(ctx, q, chirpEventDeleted, chirp); err != nil {
+		return err
+	}
+	return events.Publish(ctx, q, events.ChirpHidden{ChirpID: chirp.ID, AuthorID: chirp.UserID})
+}
+
+func (cfg *apiConfig) handlerGetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := cfg.db.GetModerationQueue(r.Context(), database.GetModerationQueueParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to get moderation queue: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get moderation queue")
+		return
+	}
+	resp := []moderationQueueItem{}
+	for _, row := range rows {
+		item := moderationQueueItem{
+			ChirpID:      row.ID,
+			CreatedAt:    row.CreatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			ReportCount:  row.ReportCount,
+			Reasons:      row.Reasons,
+			FlaggedTerms: row.FlaggedTerms,
+		}
+		if row.HiddenAt.Valid {
+			item.HiddenAt = &row.HiddenAt.Time
+		}
+		resp = append(resp, item)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerModerateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+		// Until ends the suspension of the author, for the suspend action.
+		Until *time.Time `json:"until"`
+	}
+	moderator, ok := cfg.authorizeAdmin(w, r)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	// The chirp is locked so that it is hidden or deleted once, whatever the
+	// concurrent actions on it.
+	dbChirp, err := qtx.LockChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	if err != nil {
+		log.Printf("unable to lock chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	// changed is the number of chirps whose visibility the action changed.
+	var changed int64
+	switch params.Action {
+	case moderationActionHide:
+		changed, err = qtx.HideChirp(r.Context(), chirpID)
+	case moderationActionDelete:
+		changed, err = qtx.ModeratorDeleteChirp(r.Context(), chirpID)
+	case moderationActionDismiss:
+		err = qtx.UnhideChirp(r.Context(), chirpID)
+	case moderationActionSuspend:
+		until := time.Now().UTC().Add(defaultSuspension)
+		if params.Until != nil {
+			until = params.Until.UTC()
+		}
+		if !until.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspension must end in the future")
+			return
+		}
+		var author database.User
+		if changed, err = qtx.HideChirp(r.Context(), chirpID); err == nil {
+			author, err = qtx.GetUserByID(r.Context(), dbChirp.UserID)
+		}
+		if err == nil {
+			reason := params.Note
+			if reason == "" {
+				reason = "suspended from the moderation queue"
+			}
+			_, err = changeUserState(r.Context(), qtx, author, userStateSuspended,
+				sql.NullTime{Time: until, Valid: true}, reason, uuid.NullUUID{UUID: moderator.ID, Valid: true})
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be one of hide, delete, dismiss or suspend")
+		return
+	}
+	if err != nil {
+		log.Printf("unable to %s chirp '%s': %v", params.Action, chirpID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	if err := qtx.ResolveChirpReports(r.Context(), chirpID); err != nil {
+		log.Printf("unable to resolve chirp reports: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	// Hidden and deleted chirps disappear from the streams, and deletions
+	// are published like those of authors. Chirps that were already gone
+	// aren't published again.
+	if changed > 0 && !dbChirp.DeletedAt.Valid {
+		switch params.Action {
+		case moderationActionDelete:
+			err = cfg.chirpDeleted(r.Context(), qtx, dbChirp)
+		case moderationActionHide, moderationActionSuspend:
+			err = cfg.chirpHidden(r.Context(), qtx, dbChirp)
+		}
+	}
+	if err != nil {
+		log.Printf("unable to record moderation of chirp '%s': %v", chirpID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	action, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ChirpID:      uuid.NullUUID{UUID: chirpID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+		Action:       params.Action,
+		Note:         params.Note,
+	})
+	if err != nil {
+		log.Printf("unable to record moderation action: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit moderation action: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to moderate chirp")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newModerationActionResponse(action))
+}
+
+func (cfg *apiConfig) handlerGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	actions, err := cfg.db.GetModerationActions(r.Context(), database.GetModerationActionsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to get moderation actions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get moderation actions")
+		return
+	}
+	resp := []moderationActionResponse{}
+	for _, a := range actions {
+		resp = append(resp, newModerationActionResponse(a))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func newModerationActionResponse(a database.ModerationAction) moderationActionResponse {
+	return moderationActionResponse{
+		ID:           a.ID,
+		CreatedAt:    a.CreatedAt,
+		ModeratorID:  nullUUIDPtr(a.ModeratorID),
+		ChirpID:      nullUUIDPtr(a.ChirpID),
+		TargetUserID: nullUUIDPtr(a.TargetUserID),
+		Action:       a.Action,
+		Note:         a.Note,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationMention = "mention"
+	notificationReply   = "reply"
+	notificationLike    = "like"
+	notificationFollow  = "follow"
+)
+
+var notificationTypes = []string{notificationMention, notificationReply, notificationLike, notificationFollow}
+
+type notificationResponse struct {
+	ID      uuid.UUID  `json:"id"`
+	Type    string     `json:"type"`
+	ChirpID *uuid.UUID `json:"chirp_id,omitempty"`
+	// ActorCount is the number of users who triggered the notification, the
+	// latest of which are listed in ActorIDs.
+	ActorCount int64       `json:"actor_count"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Read       bool        `json:"read"`
+}
+
+type notificationsResponse struct {
+	UnreadCount   int64                  `json:"unread_count"`
+	Notifications []notificationResponse `json:"notifications"`
+}
+
+// notify records that actor did something of type notifType that concerns
+// recipient, about chirpID if it isn't uuid.Nil. Events on the same subject
+// are grouped until the recipient reads the notification. The live
+// connections of the recipient are told when an actor is added.
+func notify(ctx context.Context, q *database.Queries, recipient, actor uuid.UUID, notifType string, chirpID uuid.UUID) error {
+	groupKey := notifType
+	if chirpID != uuid.Nil {
+		groupKey = fmt.Sprintf("%s:%s", notifType, chirpID)
+	}
+	n, err := q.Notify(ctx, database.NotifyParams{
+		RecipientID: recipient,
+		ActorID:     actor,
+		Type:        notifType,
+		ChirpID:     uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+		GroupKey:    groupKey,
+	})
+	if err != nil || n == 0 {
+		return err
+	}
+	return q.NotifyNotificationEvent(ctx, recipient)
+}
+
+// notifyMentions notifies the users mentioned in a published chirp who
+// weren't notified of it yet.
+func notifyMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	userIDs, err := q.ClaimChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if err := notify(ctx, q, userID, chirp.UserID, notificationMention, chirp.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlerGetNotifications returns the notifications of the user, most recently
+// updated first. Pass unread=true to only get unread ones.
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := cfg.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve notifications: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve notifications")
+		return
+	}
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to count unread notifications: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve notifications")
+		return
+	}
+	resp := notificationsResponse{UnreadCount: unread, Notifications: []notificationResponse{}}
+	for _, row := range rows {
+		n := notificationResponse{
+			ID:         row.ID,
+			Type:       row.Type,
+			ActorCount: row.ActorCount,
+			ActorIDs:   row.RecentActorIds,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			Read:       row.ReadAt.Valid,
+		}
+		if row.ChirpID.Valid {
+			n.ChirpID = &row.ChirpID.UUID
+		}
+		resp.Notifications = append(resp.Notifications, n)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerMarkNotificationsRead marks the given notifications as read, or all
+// of them if no ID is given.
+func (cfg *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	defer r.Body.Close()
+	var payload struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	// An empty body marks everything as read
+	if err := decodeJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	if payload.IDs == nil {
+		payload.IDs = []uuid.UUID{}
+	}
+	if _, err := cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: userID,
+		Ids:    payload.IDs,
+	}); err != nil {
+		log.Printf("unable to mark notifications as read: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to mark notifications as read")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	cfg.respondWithNotificationPreferences(w, r, userID)
+}
+
+// handlerUpdateNotificationPreferences enables or disables notification
+// types, given as a map of type to boolean. Types left out are unchanged.
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	defer r.Body.Close()
+	var payload map[string]bool
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	for notifType := range payload {
+		if !slices.Contains(notificationTypes, notifType) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown notification type '%s'", notifType))
+			return
+		}
+	}
+	for notifType, enabled := range payload {
+		if err := cfg.db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notifType,
+			Enabled: enabled,
+		}); err != nil {
+			log.Printf("unable to set notification preference: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to update notification preferences")
+			return
+		}
+	}
+	cfg.respondWithNotificationPreferences(w, r, userID)
+}
+
+// respondWithNotificationPreferences writes whether each notification type is
+// enabled for the user.
+func (cfg *apiConfig) respondWithNotificationPreferences(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	rows, err := cfg.db.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to retrieve notification preferences: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve notification preferences")
+		return
+	}
+	prefs := map[string]bool{}
+	for _, t := range notificationTypes {
+		prefs[t] = true
+	}
+	for _, row := range rows {
+		prefs[row.Type] = row.Enabled
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
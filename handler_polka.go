@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/subscription"
+	"github.com/fonspa/go-http-server/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	polkaTimestampHeader = "Polka-Timestamp"
+	polkaSignatureHeader = "Polka-Signature"
+	// polkaTolerance is how far the timestamp of a Polka request can be from
+	// the server time. Older requests are rejected as replays.
+	polkaTolerance   = 5 * time.Minute
+	polkaMaxBodySize = 64 << 10
+	// Polka sends a few events a minute at most; the limit protects the
+	// payload log from clients flooding the endpoint.
+	polkaRatePerMinute = 60
+	polkaRateBurst     = 20
+
+	polkaOutcomeInvalidSignature  = "invalid_signature"
+	polkaOutcomeExpired           = "expired"
+	polkaOutcomeInvalidPayload    = "invalid_payload"
+	polkaOutcomeDuplicate         = "duplicate"
+	polkaOutcomeIgnored           = "ignored"
+	polkaOutcomeUserNotFound      = "user_not_found"
+	polkaOutcomeInvalidTransition = "invalid_transition"
+	polkaOutcomeInvalidPeriod     = "invalid_period"
+	polkaOutcomeProcessed         = "processed"
+	polkaOutcomeError             = "error"
+)
+
+// recordPolkaPayload keeps a received Polka payload for auditing, with the
+// outcome of its processing.
+func (cfg *apiConfig) recordPolkaPayload(r *http.Request, eventID string, payload []byte, outcome string) {
+	if outcome == polkaOutcomeInvalidSignature || outcome == polkaOutcomeExpired {
+		// Anyone can send unverified payloads, only their hash is kept
+		sum := sha256.Sum256(payload)
+		payload = []byte("sha256:" + hex.EncodeToString(sum[:]))
+	}
+	if err := cfg.db.CreatePolkaPayload(r.Context(), database.CreatePolkaPayloadParams{
+		EventID: sql.NullString{String: eventID, Valid: eventID != ""},
+		Payload: payload,
+		Outcome: outcome,
+	}); err != nil {
+		log.Printf("unable to record Polka payload: %v", err)
+	}
+}
+
+// handlerPolkaWebhook handles the events sent by Polka, our payment
+// provider. Requests are signed with the Polka key: Polka-Signature holds
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>", where
+// timestamp is the Unix time in Polka-Timestamp. Each event is processed
+// once: redeliveries of an event ID are acknowledged without effect.
+//
+// Events drive the subscription of the user, and is_chirpy_red follows it.
+// Events that don't apply to the current state of the subscription, such as
+// a second upgrade of an active subscription, are acknowledged without
+// effect, and periods ending before they start are answered with 422
+// Unprocessable Entity. Both are recorded as processed.
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			UserID string `json:"user_id"`
+			// PeriodEnd is the end of the period paid for by upgrades and
+			// renewals.
+			PeriodEnd *time.Time `json:"period_end"`
+		} `json:"data"`
+	}
+	defer r.Body.Close()
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !cfg.polkaLimiter.Allow(client, time.Now()) {
+		respondWithError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, polkaMaxBodySize))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	err = webhooks.VerifySignature(cfg.polkaKey, r.Header.Get(polkaTimestampHeader), r.Header.Get(polkaSignatureHeader),
+		body, polkaTolerance, time.Now())
+	if errors.Is(err, webhooks.ErrExpiredTimestamp) {
+		log.Printf("Polka request outside of the timestamp tolerance")
+		cfg.recordPolkaPayload(r, "", body, polkaOutcomeExpired)
+		respondWithError(w, http.StatusUnauthorized, "request timestamp too old")
+		return
+	}
+	if err != nil {
+		log.Printf("Invalid Polka signature")
+		cfg.recordPolkaPayload(r, "", body, polkaOutcomeInvalidSignature)
+		respondWithError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	var params parameters
+	if err := json.Unmarshal(body, &params); err != nil || params.ID == "" {
+		log.Printf("Unable to decode Polka webhook request: %v", err)
+		cfg.recordPolkaPayload(r, "", body, polkaOutcomeInvalidPayload)
+		respondWithError(w, http.StatusBadRequest, "unable to parse request")
+		return
+	}
+	if !slices.Contains(subscription.Events, subscription.Event(params.Event)) {
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeIgnored)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	userID, err := uuid.Parse(params.Data.UserID)
+	if err != nil {
+		log.Printf("Unable to parse user ID: %v", err)
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeInvalidPayload)
+		respondWithError(w, http.StatusBadRequest, "unable to parse user ID")
+		return
+	}
+
+	fail := func(code int, msg string) {
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeError)
+		respondWithError(w, code, msg)
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	// The event is marked as processed in the transaction of its effects, so
+	// that it is only skipped once they are committed.
+	n, err := qtx.MarkPolkaEventProcessed(r.Context(), params.ID)
+	if err != nil {
+		log.Printf("unable to mark Polka event as processed: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	if n == 0 {
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeDuplicate)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	user, err := qtx.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("could not find user with this ID: %v", err)
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeUserNotFound)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		log.Printf("unable to get user: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	state, err := getSubscriptionState(r.Context(), qtx, userID)
+	if err != nil {
+		log.Printf("unable to get subscription: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	var periodEnd time.Time
+	if params.Data.PeriodEnd != nil {
+		periodEnd = params.Data.PeriodEnd.UTC()
+	}
+	state, err = subscription.Apply(state, subscription.Event(params.Event), time.Now().UTC(), periodEnd)
+	if err != nil {
+		// The event is still marked as processed, as Polka would send it
+		// again forever otherwise
+		log.Printf("Unable to apply Polka event '%s': %v", params.ID, err)
+		if err := tx.Commit(); err != nil {
+			log.Printf("unable to commit Polka event: %v", err)
+			fail(http.StatusInternalServerError, "could not process event")
+			return
+		}
+		if errors.Is(err, subscription.ErrInvalidPeriod) {
+			cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeInvalidPeriod)
+			respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeInvalidTransition)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := saveSubscription(r.Context(), qtx, user, state); err != nil {
+		log.Printf("unable to update subscription: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit Polka event: %v", err)
+		fail(http.StatusInternalServerError, "could not process event")
+		return
+	}
+	cfg.recordPolkaPayload(r, params.ID, body, polkaOutcomeProcessed)
+	w.WriteHeader(http.StatusNoContent)
+}
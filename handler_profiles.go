@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/handle"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	displayNameMaxLen = 50
+	bioMaxLen         = 160
+)
+
+// authorResponse is the minimal public profile embedded in chirps.
+type authorResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+}
+
+type profileResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Followers   int64     `json:"followers"`
+	Following   int64     `json:"following"`
+	Chirps      int64     `json:"chirps"`
+}
+
+type profilePayload struct {
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	// AvatarMediaID is the ID of an uploaded image, or "" to remove the
+	// avatar.
+	AvatarMediaID *string `json:"avatar_media_id"`
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// avatarURL returns the URL of an avatar from the keys of its media file,
+// preferring the thumbnail.
+func (cfg *apiConfig) avatarURL(key, thumbnailKey sql.NullString) string {
+	if thumbnailKey.Valid {
+		return cfg.blobStore.URL(thumbnailKey.String)
+	}
+	if key.Valid {
+		return cfg.blobStore.URL(key.String)
+	}
+	return ""
+}
+
+// authorsByID returns the public profiles of the users with the given IDs.
+func (cfg *apiConfig) authorsByID(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]authorResponse, error) {
+	rows, err := cfg.db.GetUserProfiles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	authors := make(map[uuid.UUID]authorResponse, len(rows))
+	for _, row := range rows {
+		authors[row.ID] = authorResponse{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			AvatarURL:   cfg.avatarURL(row.AvatarKey, row.AvatarThumbnailKey),
+		}
+	}
+	return authors, nil
+}
+
+// handlerGetProfile returns the public profile of the user with the handle in
+// the path, which never includes their email.
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.db.GetUserByHandle(r.Context(), handle.Normalize(r.PathValue("handle")))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get user by handle: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve profile")
+		return
+	}
+	if viewerID := cfg.optionalUserID(r); viewerID != uuid.Nil && viewerID != user.ID {
+		blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{UserA: viewerID, UserB: user.ID})
+		if err != nil || blocked {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+	}
+	counts, err := cfg.db.GetFollowCounts(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("unable to count follows of user '%s': %v", user.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve profile")
+		return
+	}
+	chirps, err := cfg.db.GetUserChirpCount(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("unable to count chirps of user '%s': %v", user.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve profile")
+		return
+	}
+	resp := profileResponse{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+		Followers:   counts.Followers,
+		Following:   counts.Following,
+		Chirps:      chirps,
+	}
+	if user.AvatarMediaID.Valid {
+		avatar, err := cfg.db.GetMediaFileByID(r.Context(), user.AvatarMediaID.UUID)
+		if err != nil {
+			log.Printf("unable to get avatar of user '%s': %v", user.ID, err)
+		} else {
+			resp.AvatarURL = cfg.avatarURL(sql.NullString{String: avatar.StorageKey, Valid: true}, avatar.ThumbnailKey)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerUpdateProfile updates the fields of the profile of the user present
+// in the payload, leaving the others unchanged.
+func (cfg *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	if cfg.rejectSuspended(w, r, userID) {
+		return
+	}
+	defer r.Body.Close()
+	var payload profilePayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user '%s': %v", userID, err)
+		respondWithError(w, http.StatusUnauthorized, "unknown user")
+		return
+	}
+	params := database.UpdateUserProfileParams{
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarMediaID: user.AvatarMediaID,
+		ID:            user.ID,
+	}
+	if payload.Handle != nil {
+		h := strings.TrimPrefix(*payload.Handle, "@")
+		if err := handle.Validate(h); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params.Handle = sql.NullString{String: h, Valid: true}
+	}
+	if payload.DisplayName != nil {
+		params.DisplayName, _, err = cfg.validateText(strings.TrimSpace(*payload.DisplayName), "display name", displayNameMaxLen)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if payload.Bio != nil {
+		params.Bio, _, err = cfg.validateText(strings.TrimSpace(*payload.Bio), "bio", bioMaxLen)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if payload.AvatarMediaID != nil {
+		params.AvatarMediaID = uuid.NullUUID{}
+		if *payload.AvatarMediaID != "" {
+			avatar, ok := cfg.avatarMedia(w, r, userID, *payload.AvatarMediaID)
+			if !ok {
+				return
+			}
+			params.AvatarMediaID = uuid.NullUUID{UUID: avatar.ID, Valid: true}
+		}
+	}
+	user, err = cfg.db.UpdateUserProfile(r.Context(), params)
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "this handle is already taken")
+			return
+		}
+		log.Printf("unable to update profile of user '%s': %v", userID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update profile")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// avatarMedia returns the media file with the given ID if the user can use it
+// as an avatar: an image they uploaded that isn't attached to a chirp.
+// Otherwise it writes an error response and returns false.
+func (cfg *apiConfig) avatarMedia(w http.ResponseWriter, r *http.Request, userID uuid.UUID, id string) (database.MediaFile, bool) {
+	mediaID, err := uuid.Parse(id)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid avatar media ID")
+		return database.MediaFile{}, false
+	}
+	avatar, err := cfg.db.GetMediaFileByID(r.Context(), mediaID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusBadRequest, "unknown avatar media")
+			return database.MediaFile{}, false
+		}
+		log.Printf("unable to get media file '%s': %v", mediaID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update profile")
+		return database.MediaFile{}, false
+	}
+	if avatar.UserID != userID || avatar.ChirpID.Valid || !strings.HasPrefix(avatar.ContentType, "image/") {
+		respondWithError(w, http.StatusBadRequest, "avatar must be an unattached image you uploaded")
+		return database.MediaFile{}, false
+	}
+	return avatar, true
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	chirpStatusDraft     = "draft"
+	chirpStatusScheduled = "scheduled"
+	chirpStatusPublished = "published"
+
+	// chirpMaxScheduleAhead is how far in the future a chirp can be scheduled.
+	chirpMaxScheduleAhead = 365 * 24 * time.Hour
+)
+
+// chirpStatus returns the status and publication time a chirp gets from the
+// draft flag and the optional publish_at of a payload. A publication time in
+// the past publishes the chirp right away.
+func chirpStatus(draft bool, publishAt *time.Time, now time.Time) (string, sql.NullTime, error) {
+	if draft {
+		return chirpStatusDraft, sql.NullTime{}, nil
+	}
+	if publishAt == nil || !publishAt.After(now) {
+		return chirpStatusPublished, sql.NullTime{}, nil
+	}
+	if publishAt.Sub(now) > chirpMaxScheduleAhead {
+		return "", sql.NullTime{}, errors.New("chirps can't be scheduled more than a year ahead")
+	}
+	return chirpStatusScheduled, sql.NullTime{Time: publishAt.UTC(), Valid: true}, nil
+}
+
+// chirpPublished adds chirp to the home timelines, streams it and publishes
+// its ChirpCreated event, if it is published. It is a no-op for drafts and
+// scheduled chirps, which are handled by the scheduler once published.
+func (cfg *apiConfig) chirpPublished(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.Status != chirpStatusPublished {
+		return nil
+	}
+	if err := cfg.timeline.Published(ctx, q, chirp); err != nil {
+		return err
+	}
+	if err := cfg.emitChirpEvent(ctx, q, chirpEventCreated, chirp); err != nil {
+		return err
+	}
+	return events.Publish(ctx, q, events.ChirpCreated{ChirpID: chirp.ID, AuthorID: chirp.UserID})
+}
+
+func (cfg *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	chirps, err := cfg.db.GetUnpublishedChirpsByUserID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to retrieve scheduled chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve scheduled chirps")
+		return
+	}
+	cfg.respondWithChirps(w, r, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	user, ok := cfg.activeUser(w, r, userID)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp ID")
+		return
+	}
+	defer r.Body.Close()
+	var payload chirpPayload
+	if err := decodeJSON(w, r, &payload); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	limits := cfg.limitsOf(user)
+	cleanedMsg, flagged, err := cfg.validateChirp(payload.Body, limits)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mediaIDs, err := parseMediaIDs(payload.MediaIDs, limits.MaxMediaPerChirp)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status, publishAt, err := chirpStatus(payload.Draft, payload.PublishAt, time.Now().UTC())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	dbChirp, err := qtx.UpdateUnpublishedChirp(r.Context(), database.UpdateUnpublishedChirpParams{
+		Body:      cleanedMsg,
+		Status:    status,
+		PublishAt: publishAt,
+		ID:        chirpID,
+		UserID:    userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "no draft or scheduled chirp with this ID")
+			return
+		}
+		log.Printf("unable to update scheduled chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	if err := qtx.DeleteChirpEntities(r.Context(), dbChirp.ID); err != nil {
+		log.Printf("unable to clear chirp entities: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	if err := storeChirpMetadata(r.Context(), qtx, dbChirp, flagged); err != nil {
+		log.Printf("unable to store chirp metadata: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	// Attachments are kept unless media_ids is given, which replaces them
+	if payload.MediaIDs != nil {
+		if err := qtx.DetachMediaFilesFromChirp(r.Context(), uuid.NullUUID{UUID: dbChirp.ID, Valid: true}); err != nil {
+			log.Printf("unable to detach media from chirp: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+			return
+		}
+		if err := attachMedia(r.Context(), qtx, dbChirp, mediaIDs); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusBadRequest, "unknown or already attached media")
+				return
+			}
+			log.Printf("unable to attach media to chirp: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+			return
+		}
+	}
+	if err := cfg.chirpPublished(r.Context(), qtx, dbChirp); err != nil {
+		log.Printf("unable to add chirp to timelines: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit chirp update: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update chirp")
+		return
+	}
+	cfg.respondWithChirp(w, r, http.StatusOK, dbChirp)
+}
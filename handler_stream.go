@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/stream"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpEventChannel = "chirp_events"
+	chirpEventCreated = "chirp_created"
+	chirpEventDeleted = "chirp_deleted"
+
+	// streamReplaySize is how many events are kept for clients resuming a
+	// stream with Last-Event-ID.
+	streamReplaySize = 500
+	// streamBacklog is how many events a client can lag behind before it is
+	// disconnected.
+	streamBacklog     = 64
+	streamHeartbeat   = 15 * time.Second
+	streamRetryMillis = 3000
+)
+
+// chirpEvent is the payload of the notifications sent on chirpEventChannel.
+type chirpEvent struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+// emitChirpEvent notifies every server instance that chirp was created or
+// deleted, once the transaction of q commits. Chirps that only their author
+// can see are never streamed.
+func (cfg *apiConfig) emitChirpEvent(ctx context.Context, q *database.Queries, eventType string, chirp database.Chirp) error {
+	if eventType == chirpEventCreated {
+		visible, err := cfg.chirpVisibleTo(ctx, chirp, uuid.Nil)
+		if err != nil || !visible {
+			return err
+		}
+	}
+	return q.NotifyChirpEvent(ctx, database.NotifyChirpEventParams{
+		Type:     eventType,
+		ChirpID:  chirp.ID,
+		AuthorID: chirp.UserID,
+	})
+}
+
+// listenEvents dispatches the chirp and notification events of every server
+// instance to the local stream and realtime hubs, until ctx is canceled.
+func (cfg *apiConfig) listenEvents(ctx context.Context, dbURL string) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("events listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	for _, channel := range []string{chirpEventChannel, notificationEventChannel} {
+		if err := listener.Listen(channel); err != nil {
+			log.Printf("unable to listen to %s: %v", channel, err)
+			return
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established,
+			// and events sent in the meantime are lost.
+			if n == nil {
+				log.Print("events listener reconnected")
+				continue
+			}
+			var err error
+			switch n.Channel {
+			case chirpEventChannel:
+				err = cfg.publishChirpEvent(ctx, n.Extra)
+			case notificationEventChannel:
+				err = cfg.publishNotificationEvent(ctx, n.Extra)
+			}
+			if err != nil {
+				log.Printf("unable to publish %s event: %v", n.Channel, err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) publishChirpEvent(ctx context.Context, payload string) error {
+	var ev chirpEvent
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		return err
+	}
+	chirp, err := cfg.db.GetChirpByID(ctx, ev.ChirpID)
+	if err != nil {
+		return fmt.Errorf("unable to get chirp '%s': %w", ev.ChirpID, err)
+	}
+	var data any = struct {
+		ID uuid.UUID `json:"id"`
+	}{ev.ChirpID}
+	if ev.Type == chirpEventCreated {
+		if data, err = cfg.chirpResponse(ctx, chirp); err != nil {
+			return err
+		}
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	cfg.chirpStream.Publish(stream.Event{
+		ID:       strconv.FormatInt(ev.ID, 10),
+		Type:     ev.Type,
+		AuthorID: ev.AuthorID,
+		Data:     encoded,
+	})
+	return cfg.publishRealtimeChirp(ctx, ev.Type, chirp, encoded)
+}
+
+// handlerStreamChirps streams chirp creations and deletions as Server-Sent
+// Events. Like GET /api/chirps it accepts an author_id filter. Clients
+// resuming with Last-Event-ID, or the last_event_id query parameter for
+// those that can't set headers, first get the events they missed, as long as
+// they are still in the replay buffer.
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, r *http.Request) {
+	var authorID uuid.UUID
+	if v := r.URL.Query().Get("author_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid user ID")
+			return
+		}
+		authorID = id
+	}
+	// The authors the viewer blocked or muted are read once, when the stream
+	// starts.
+	hidden := map[uuid.UUID]bool{}
+	if viewerID := cfg.optionalUserID(r); viewerID != uuid.Nil {
+		ids, err := cfg.db.GetHiddenAuthorIDs(r.Context(), viewerID)
+		if err != nil {
+			log.Printf("unable to get hidden authors: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to stream chirps")
+			return
+		}
+		for _, id := range ids {
+			hidden[id] = true
+		}
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	replay, sub := cfg.chirpStream.Subscribe(lastID)
+	defer sub.Unsubscribe()
+	send := func(e stream.Event) error {
+		if (authorID != uuid.Nil && e.AuthorID != authorID) || hidden[e.AuthorID] {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		return err
+	}
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("unable to flush chirp stream: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			// The hub closed the subscription: the server is shutting down
+			// or the client is too slow, and will reconnect.
+			if !ok {
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
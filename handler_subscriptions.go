@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+const (
+	// subscriptionExpiryInterval is short, so that a lapsed subscription loses
+	// Chirpy Red within minutes of its period end, even right after a restart.
+	subscriptionExpiryInterval = 5 * time.Minute
+	subscriptionExpiryBatch    = 500
+)
+
+func subscriptionState(s database.Subscription) subscription.State {
+	return subscription.State{
+		Status:      subscription.Status(s.Status),
+		PeriodStart: s.CurrentPeriodStart,
+		PeriodEnd:   s.CurrentPeriodEnd,
+		CanceledAt:  s.CanceledAt.Time,
+	}
+}
+
+// getSubscriptionState returns the subscription state of a user, locking it
+// for the rest of the transaction of q.
+func getSubscriptionState(ctx context.Context, q *database.Queries, userID uuid.UUID) (subscription.State, error) {
+	s, err := q.GetSubscriptionByUserID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription.State{}, nil
+	}
+	if err != nil {
+		return subscription.State{}, err
+	}
+	return subscriptionState(s), nil
+}
+
+// saveSubscription stores the subscription state of user and derives
+// is_chirpy_red from it, publishing UserUpgraded or UserDowngraded when it
+// changes.
+func saveSubscription(ctx context.Context, q *database.Queries, user database.User, s subscription.State) error {
+	_, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             user.ID,
+		Plan:               subscription.PlanRed,
+		Status:             string(s.Status),
+		CurrentPeriodStart: s.PeriodStart,
+		CurrentPeriodEnd:   s.PeriodEnd,
+		CanceledAt:         sql.NullTime{Time: s.CanceledAt, Valid: !s.CanceledAt.IsZero()},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to save subscription: %w", err)
+	}
+	synced, err := q.SyncUserChirpyRed(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("unable to sync Chirpy Red: %w", err)
+	}
+	switch {
+	case synced.IsChirpyRed.Bool && !user.IsChirpyRed.Bool:
+		return events.Publish(ctx, q, events.UserUpgraded{UserID: user.ID})
+	case !synced.IsChirpyRed.Bool && user.IsChirpyRed.Bool:
+		return events.Publish(ctx, q, events.UserDowngraded{UserID: user.ID})
+	}
+	return nil
+}
+
+// expireSubscriptions expires the subscriptions whose period is over, which
+// were neither renewed nor refunded, and takes Chirpy Red from their users.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	for {
+		n, err := cfg.expireSubscriptionBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < subscriptionExpiryBatch {
+			return nil
+		}
+	}
+}
+
+func (cfg *apiConfig) expireSubscriptionBatch(ctx context.Context) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	now := time.Now().UTC()
+	subs, err := qtx.GetLapsedSubscriptions(ctx, database.GetLapsedSubscriptionsParams{
+		Now:       now,
+		BatchSize: subscriptionExpiryBatch,
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, sub := range subs {
+		state, changed := subscription.Expire(subscriptionState(sub), now)
+		if !changed {
+			continue
+		}
+		user, err := qtx.GetUserByID(ctx, sub.UserID)
+		if err != nil {
+			return 0, fmt.Errorf("unable to get user '%s': %w", sub.UserID, err)
+		}
+		if err := saveSubscription(ctx, qtx, user, state); err != nil {
+			return 0, fmt.Errorf("unable to expire subscription of user '%s': %w", sub.UserID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if len(subs) > 0 {
+		log.Printf("expired %d subscriptions", len(subs))
+	}
+	return len(subs), nil
+}
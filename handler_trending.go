@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/trending"
+	"github.com/google/uuid"
+)
+
+const (
+	trendingInterval = time.Minute
+	// trendingSize is the number of hashtags and of chirps in each snapshot.
+	trendingSize = 20
+	// trendingRetention is how long snapshots are kept once superseded.
+	trendingRetention = 24 * time.Hour
+	// trendingOverlap is how late, after their creation, likes and chirps
+	// can be committed and still count.
+	trendingOverlap       = 5 * time.Minute
+	defaultTrendingWindow = "24h"
+)
+
+// trendingState is the engagement tracked by the trending job between runs.
+// Only the job uses the cursors, so they need no locking.
+type trendingState struct {
+	clock    trending.Clock
+	chirps   *trending.Tracker
+	hashtags *trending.Tracker
+	likes    *trending.Cursor
+	tags     *trending.Cursor
+}
+
+func newTrendingState(clock trending.Clock) *trendingState {
+	var span time.Duration
+	for _, w := range trending.DefaultWindows {
+		span = max(span, w.Length)
+	}
+	since := clock.Now().Add(-span)
+	return &trendingState{
+		clock:    clock,
+		chirps:   trending.NewTracker(clock, trending.DefaultWindows),
+		hashtags: trending.NewTracker(clock, trending.DefaultWindows),
+		likes:    trending.NewCursor(since, trendingOverlap),
+		tags:     trending.NewCursor(since, trendingOverlap),
+	}
+}
+
+type trendingHashtag struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+type trendingResponse struct {
+	Window     string            `json:"window"`
+	ComputedAt time.Time         `json:"computed_at"`
+	Hashtags   []trendingHashtag `json:"hashtags"`
+	Chirps     []chirpResponse   `json:"chirps"`
+}
+
+// computeTrending loads the engagement since its last run, then stores a
+// snapshot of the rankings of every window. Likes rank chirps, and chirps
+// using a hashtag rank the hashtag.
+func (cfg *apiConfig) computeTrending(ctx context.Context) error {
+	st := cfg.trending
+	likes, err := cfg.db.GetLikeEventsSince(ctx, st.likes.Since())
+	if err != nil {
+		return fmt.Errorf("unable to load likes: %w", err)
+	}
+	for _, l := range likes {
+		// A chirp liked again after an unlike counts again
+		id := fmt.Sprintf("%s/%s/%d", l.ChirpID, l.UserID, l.CreatedAt.UnixNano())
+		if st.likes.Next(id, l.CreatedAt) {
+			st.chirps.Add(trending.Event{Key: l.ChirpID.String(), ChirpID: l.ChirpID, At: l.CreatedAt, Weight: 1})
+		}
+	}
+	tags, err := cfg.db.GetHashtagEventsSince(ctx, st.tags.Since())
+	if err != nil {
+		return fmt.Errorf("unable to load hashtags: %w", err)
+	}
+	for _, t := range tags {
+		if st.tags.Next(t.ChirpID.String()+"/"+t.Tag, t.CreatedAt) {
+			st.hashtags.Add(trending.Event{Key: t.Tag, ChirpID: t.ChirpID, At: t.CreatedAt, Weight: 1})
+		}
+	}
+	st.chirps.Evict()
+	st.hashtags.Evict()
+
+	// Chirps deleted, hidden or by shadow-banned users since their events
+	// were loaded stop counting for good.
+	ids := append(st.chirps.ChirpIDs(), st.hashtags.ChirpIDs()...)
+	trendable, err := cfg.db.GetTrendableChirpIDs(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("unable to filter chirps: %w", err)
+	}
+	keep := make(map[uuid.UUID]bool, len(trendable))
+	for _, id := range trendable {
+		keep[id] = true
+	}
+	st.chirps.Retain(func(id uuid.UUID) bool { return keep[id] })
+	st.hashtags.Retain(func(id uuid.UUID) bool { return keep[id] })
+
+	now := st.clock.Now()
+	for _, w := range trending.DefaultWindows {
+		if err := cfg.storeTrendingSnapshot(ctx, w, now); err != nil {
+			return fmt.Errorf("unable to store %s snapshot: %w", w.Name, err)
+		}
+	}
+	return cfg.db.PruneTrendingSnapshots(ctx, now.Add(-trendingRetention))
+}
+
+func (cfg *apiConfig) storeTrendingSnapshot(ctx context.Context, w trending.Window, now time.Time) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	snapshot, err := qtx.CreateTrendingSnapshot(ctx, database.CreateTrendingSnapshotParams{
+		TimeWindow: w.Name,
+		ComputedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	for i, r := range cfg.trending.hashtags.Top(w, trendingSize) {
+		if err := qtx.AddTrendingItem(ctx, database.AddTrendingItemParams{
+			SnapshotID: snapshot.ID,
+			Kind:       "hashtag",
+			Rank:       int32(i + 1),
+			Tag:        sql.NullString{String: r.Key, Valid: true},
+			Score:      r.Score,
+		}); err != nil {
+			return err
+		}
+	}
+	for i, r := range cfg.trending.chirps.Top(w, trendingSize) {
+		if err := qtx.AddTrendingItem(ctx, database.AddTrendingItemParams{
+			SnapshotID: snapshot.ID,
+			Kind:       "chirp",
+			Rank:       int32(i + 1),
+			ChirpID:    uuid.NullUUID{UUID: uuid.MustParse(r.Key), Valid: true},
+			Score:      r.Score,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// handlerGetTrending returns the latest trending hashtags and chirps of the
+// window given by the "window" query parameter: 1h, 24h (the default) or 7d.
+func (cfg *apiConfig) handlerGetTrending(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = defaultTrendingWindow
+	}
+	known := false
+	for _, tw := range trending.DefaultWindows {
+		known = known || tw.Name == window
+	}
+	if !known {
+		respondWithError(w, http.StatusBadRequest, "window must be 1h, 24h or 7d")
+		return
+	}
+	resp := trendingResponse{Window: window, Hashtags: []trendingHashtag{}, Chirps: []chirpResponse{}}
+	snapshot, err := cfg.db.GetLatestTrendingSnapshot(r.Context(), window)
+	if err != nil {
+		// Nothing has been computed yet
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithJSON(w, http.StatusOK, resp)
+			return
+		}
+		log.Printf("unable to get trending snapshot: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve trends")
+		return
+	}
+	resp.ComputedAt = snapshot.ComputedAt
+	tags, err := cfg.db.GetTrendingHashtags(r.Context(), snapshot.ID)
+	if err != nil {
+		log.Printf("unable to get trending hashtags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve trends")
+		return
+	}
+	for _, t := range tags {
+		resp.Hashtags = append(resp.Hashtags, trendingHashtag{Tag: t.Tag, Score: t.Score})
+	}
+	chirps, err := cfg.db.GetTrendingChirps(r.Context(), database.GetTrendingChirpsParams{
+		SnapshotID: snapshot.ID,
+		ViewerID:   cfg.optionalUserID(r),
+	})
+	if err != nil {
+		log.Printf("unable to get trending chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retrieve trends")
+		return
+	}
+	if len(chirps) > 0 {
+		resp.Chirps, err = cfg.chirpsResponse(r.Context(), chirps)
+		if err != nil {
+			log.Printf("unable to build trending chirps response: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "unable to retrieve trends")
+			return
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	userStateActive       = "active"
+	userStateSuspended    = "suspended"
+	userStateShadowBanned = "shadow_banned"
+
+	userStateJobInterval = time.Minute
+)
+
+type userStateChangeResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChangedBy  *uuid.UUID `json:"changed_by"`
+	OldState   string     `json:"old_state"`
+	NewState   string     `json:"new_state"`
+	StateUntil *time.Time `json:"state_until,omitempty"`
+	Reason     string     `json:"reason"`
+}
+
+// isSuspended reports whether user is currently suspended. A suspension whose
+// end date has passed no longer counts, even before the job lifting expired
+// states has run.
+func isSuspended(user database.User) bool {
+	return user.State == userStateSuspended && (!user.StateUntil.Valid || time.Now().UTC().Before(user.StateUntil.Time))
+}
+
+func suspensionMessage(user database.User) string {
+	if user.StateUntil.Valid {
+		return fmt.Sprintf("account suspended until %s", user.StateUntil.Time.Format(time.RFC3339))
+	}
+	return "account suspended"
+}
+
+// rejectSuspended responds with an error and returns true if userID belongs to
+// a suspended user, who can't post or interact until the suspension ends.
+func (cfg *apiConfig) rejectSuspended(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	_, ok := cfg.activeUser(w, r, userID)
+	return !ok
+}
+
+// activeUser is rejectSuspended returning the user when they can act.
+func (cfg *apiConfig) activeUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.User, bool) {
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user '%s': %v", userID, err)
+		respondWithError(w, http.StatusUnauthorized, "unknown user")
+		return database.User{}, false
+	}
+	if isSuspended(user) {
+		respondWithError(w, http.StatusForbidden, suspensionMessage(user))
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// changeUserState sets the state of user and records the change in the audit
+// log. changedBy is the admin making the change, if any.
+func changeUserState(ctx context.Context, q *database.Queries, user database.User, state string, until sql.NullTime, reason string, changedBy uuid.NullUUID) (database.User, error) {
+	updated, err := q.SetUserState(ctx, database.SetUserStateParams{
+		State:      state,
+		StateUntil: until,
+		ID:         user.ID,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if _, err := q.CreateUserStateChange(ctx, database.CreateUserStateChangeParams{
+		UserID:     user.ID,
+		ChangedBy:  changedBy,
+		OldState:   user.State,
+		NewState:   state,
+		StateUntil: until,
+		Reason:     reason,
+	}); err != nil {
+		return database.User{}, err
+	}
+	return updated, nil
+}
+
+// liftExpiredUserStates puts back users whose suspension or shadow-ban has
+// ended in the active state.
+func (cfg *apiConfig) liftExpiredUserStates(ctx context.Context) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	users, err := qtx.GetUsersWithExpiredState(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if _, err := changeUserState(ctx, qtx, user, userStateActive, sql.NullTime{}, "expired", uuid.NullUUID{}); err != nil {
+			return fmt.Errorf("unable to lift state of user '%s': %w", user.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) handlerSetUserState(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		State  string     `json:"state"`
+		Until  *time.Time `json:"until"`
+		Reason string     `json:"reason"`
+	}
+	admin, ok := cfg.authorizeAdmin(w, r)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	if params.State != userStateSuspended && params.State != userStateShadowBanned {
+		respondWithError(w, http.StatusBadRequest, "state must be suspended or shadow_banned")
+		return
+	}
+	var until sql.NullTime
+	if params.Until != nil {
+		if !params.Until.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "until must be in the future")
+			return
+		}
+		until = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	}
+	cfg.updateUserState(w, r, admin, params.State, until, params.Reason)
+}
+
+func (cfg *apiConfig) handlerClearUserState(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+	admin, ok := cfg.authorizeAdmin(w, r)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	cfg.updateUserState(w, r, admin, userStateActive, sql.NullTime{}, params.Reason)
+}
+
+func (cfg *apiConfig) updateUserState(w http.ResponseWriter, r *http.Request, admin database.User, state string, until sql.NullTime, reason string) {
+	if reason == "" {
+		respondWithError(w, http.StatusBadRequest, "a reason is required")
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update user state")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	user, err := qtx.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		log.Printf("unable to get user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update user state")
+		return
+	}
+	user, err = changeUserState(r.Context(), qtx, user, state, until, reason, uuid.NullUUID{UUID: admin.ID, Valid: true})
+	if err != nil {
+		log.Printf("unable to change user state: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update user state")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit user state change: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to update user state")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (cfg *apiConfig) handlerGetUserStateChanges(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.authorizeAdmin(w, r); !ok {
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	changes, err := cfg.db.GetUserStateChanges(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user state changes: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get user state changes")
+		return
+	}
+	resp := []userStateChangeResponse{}
+	for _, c := range changes {
+		change := userStateChangeResponse{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UserID:    c.UserID,
+			ChangedBy: nullUUIDPtr(c.ChangedBy),
+			OldState:  c.OldState,
+			NewState:  c.NewState,
+			Reason:    c.Reason,
+		}
+		if c.StateUntil.Valid {
+			change.StateUntil = &c.StateUntil.Time
+		}
+		resp = append(resp, change)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"database/sql"
-	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/fonspa/go-http-server/internal/auth"
 	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/handle"
 	"github.com/google/uuid"
 )
 
@@ -21,50 +22,100 @@ const (
 type userPayload struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Handle   string `json:"handle"`
 }
 
 // NOTE: We voluntarily omit the hashed user's password in there...
 type userResponse struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Password     string    `json:"-"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Email        string     `json:"email"`
+	Handle       string     `json:"handle"`
+	DisplayName  string     `json:"display_name"`
+	Bio          string     `json:"bio"`
+	Password     string     `json:"-"`
+	Token        string     `json:"token"`
+	RefreshToken string     `json:"refresh_token"`
+	IsChirpyRed  bool       `json:"is_chirpy_red"`
+	State        string     `json:"state"`
+	StateUntil   *time.Time `json:"state_until,omitempty"`
+}
+
+func newUserResponse(user database.User) userResponse {
+	resp := userResponse{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+		State:       user.State,
+	}
+	if user.StateUntil.Valid {
+		resp.StateUntil = &user.StateUntil.Time
+	}
+	return resp
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	var payload userPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := decodeJSON(w, r, &payload); err != nil {
 		log.Printf("unable to decode request: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to decode request")
 		return
 	}
+	// The handle is optional at sign up, and can be chosen later on the
+	// profile.
+	userHandle := strings.TrimPrefix(payload.Handle, "@")
+	if userHandle != "" {
+		if err := handle.Validate(userHandle); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	hashedPasswd, err := auth.HashPassword(payload.Password)
 	if err != nil {
 		log.Printf("unable to hash user password: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to hash password")
 		return
 	}
-	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("unable to begin transaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create new user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	user, err := qtx.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          payload.Email,
 		HashedPassword: hashedPasswd,
+		Handle:         sql.NullString{String: userHandle, Valid: userHandle != ""},
 	})
 	if err != nil {
+		if userHandle != "" && isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "this handle is already taken")
+			return
+		}
 		log.Printf("db error when creating new user: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to create new user")
 		return
 	}
-	respondWithJSON(w, http.StatusCreated, userResponse{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
-	})
+	if err := events.Publish(r.Context(), qtx, events.UserCreated{UserID: user.ID}); err != nil {
+		log.Printf("unable to publish user creation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create new user")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unable to commit user creation: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create new user")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, newUserResponse(user))
 }
 
 func (cfg *apiConfig) handlerDeleteAllUsers(w http.ResponseWriter, r *http.Request) {
@@ -84,7 +135,7 @@ func (cfg *apiConfig) handlerDeleteAllUsers(w http.ResponseWriter, r *http.Reque
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	var payload userPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := decodeJSON(w, r, &payload); err != nil {
 		log.Printf("unable to decode request: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to decode request")
 		return
@@ -100,6 +151,10 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusUnauthorized, "invalid user credentials")
 		return
 	}
+	if isSuspended(user) {
+		respondWithError(w, http.StatusForbidden, suspensionMessage(user))
+		return
+	}
 	// Create JWT
 	userToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, accessTokenDuration)
 	if err != nil {
@@ -117,15 +172,17 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	respondWithJSON(w, http.StatusOK, userResponse{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        userToken,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed.Bool,
-	})
+	// Past the session limit of the user, the oldest sessions are logged out
+	if _, err := cfg.db.RevokeExcessRefreshTokens(r.Context(), database.RevokeExcessRefreshTokensParams{
+		UserID: user.ID,
+		Keep:   int32(cfg.limitsOf(user).MaxSessions),
+	}); err != nil {
+		log.Printf("unable to revoke excess refresh tokens: %v", err)
+	}
+	resp := newUserResponse(user)
+	resp.Token = userToken
+	resp.RefreshToken = refreshToken
+	respondWithJSON(w, http.StatusOK, resp)
 }
 
 func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request) {
@@ -160,6 +217,10 @@ func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	// Suspended users can't get new access tokens either
+	if cfg.rejectSuspended(w, r, dbRefreshToken.UserID) {
+		return
+	}
 	// Make a new JWT for that user
 	accessToken, err := auth.MakeJWT(dbRefreshToken.UserID, cfg.jwtSecret, accessTokenDuration)
 	if err != nil {
@@ -202,7 +263,7 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 	var payload userPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := decodeJSON(w, r, &payload); err != nil {
 		log.Printf("unable to decode request: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to decode request")
 		return
@@ -223,58 +284,5 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "unable to update user's credentials")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, userResponse{
-		ID:          usr.ID,
-		CreatedAt:   usr.CreatedAt,
-		UpdatedAt:   usr.UpdatedAt,
-		Email:       usr.Email,
-		IsChirpyRed: usr.IsChirpyRed.Bool,
-	})
-}
-
-func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		log.Printf("Polka API Key not found: %v", err)
-		respondWithError(w, http.StatusUnauthorized, "polka api key not found")
-		return
-	}
-	if apiKey != cfg.polkaKey {
-		log.Printf("Invalid Polka API key")
-		respondWithError(w, http.StatusUnauthorized, "invalid API key")
-		return
-	}
-	var params parameters
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		log.Printf("Unable to decode Polka webhook request: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "unable to parse request")
-		return
-	}
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-	userID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		log.Printf("Unable to parse user ID: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "unable to parse user ID")
-		return
-	}
-	_, err = cfg.db.UpgradeUserToRed(r.Context(), userID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("could not find user with this ID: %v", err)
-			respondWithError(w, http.StatusNotFound, "user not found")
-			return
-		}
-		respondWithError(w, http.StatusInternalServerError, "could not upgrade user")
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
+	respondWithJSON(w, http.StatusOK, newUserResponse(usr))
 }
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/handle"
+	"github.com/fonspa/go-http-server/internal/web"
+	"github.com/google/uuid"
+)
+
+// sessionCookie holds the access token of the users logged in the web pages,
+// out of reach of scripts.
+const sessionCookie = "chirpy_session"
+
+// webViewer returns the user logged in the web pages, or false for anonymous
+// visitors, expired sessions and suspended users.
+func (cfg *apiConfig) webViewer(r *http.Request) (database.User, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(cookie.Value, cfg.jwtSecret)
+	if err != nil {
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil || isSuspended(user) {
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// pageMeta returns the description shared by the pages: their canonical URL
+// and the name of the logged in user.
+func (cfg *apiConfig) pageMeta(r *http.Request, viewer database.User) web.Meta {
+	meta := web.Meta{
+		Title: "Chirpy",
+		URL:   cfg.baseURL(r) + r.URL.Path,
+		Type:  "website",
+	}
+	if viewer.ID != uuid.Nil {
+		meta.Viewer = web.Author{Handle: viewer.Handle.String, DisplayName: viewer.DisplayName}.Name()
+		if !viewer.Handle.Valid && viewer.DisplayName == "" {
+			meta.Viewer = viewer.Email
+		}
+	}
+	return meta
+}
+
+// renderPage writes a page, or a plain error if it can't be rendered.
+func (cfg *apiConfig) renderPage(w http.ResponseWriter, code int, page string, data any) {
+	var buf bytes.Buffer
+	if err := cfg.pages.Render(&buf, page, data); err != nil {
+		log.Printf("unable to render page '%s': %v", page, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+func (cfg *apiConfig) renderErrorPage(w http.ResponseWriter, meta web.Meta, code int, message string) {
+	meta.Title = http.StatusText(code)
+	meta.NoIndex = true
+	cfg.renderPage(w, code, web.PageError, web.ErrorPage{Meta: meta, Status: code, Message: message})
+}
+
+// webChirps returns the chirps shown in the pages, with the same authors and
+// media as in the API, and with absolute URLs.
+func (cfg *apiConfig) webChirps(ctx context.Context, base string, chirps []database.Chirp) ([]web.Chirp, error) {
+	resp, err := cfg.chirpsResponse(ctx, chirps)
+	if err != nil {
+		return nil, err
+	}
+	pageChirps := make([]web.Chirp, 0, len(resp))
+	for _, c := range resp {
+		chirp := web.Chirp{
+			ID:   c.ID.String(),
+			Body: c.Body,
+			Author: web.Author{
+				Handle:      c.Author.Handle,
+				DisplayName: c.Author.DisplayName,
+				AvatarURL:   absoluteURL(base, c.Author.AvatarURL),
+			},
+			CreatedAt: c.CreatedAt,
+		}
+		for _, m := range c.Media {
+			chirp.Media = append(chirp.Media, web.Media{
+				URL:          absoluteURL(base, m.URL),
+				ThumbnailURL: absoluteURL(base, m.ThumbnailURL),
+				ContentType:  m.ContentType,
+				Width:        m.Width,
+				Height:       m.Height,
+			})
+		}
+		pageChirps = append(pageChirps, chirp)
+	}
+	return pageChirps, nil
+}
+
+// absoluteURL resolves the URLs of media served by this server, which are
+// relative with the local media store.
+func absoluteURL(base, u string) string {
+	if strings.HasPrefix(u, "/") {
+		return base + u
+	}
+	return u
+}
+
+// pageOfChirps trims a page of chirps, loaded with one more chirp than limit,
+// and returns the URL of the next page if there is one.
+func pageOfChirps(r *http.Request, chirps []database.Chirp, limit, offset int32) ([]database.Chirp, string) {
+	if len(chirps) <= int(limit) {
+		return chirps, ""
+	}
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(int(offset+limit)))
+	return chirps[:limit], r.URL.Path + "?" + query.Encode()
+}
+
+// handlerWebTimeline renders the latest public chirps.
+func (cfg *apiConfig) handlerWebTimeline(w http.ResponseWriter, r *http.Request) {
+	viewer, _ := cfg.webViewer(r)
+	meta := cfg.pageMeta(r, viewer)
+	meta.Description = "The latest chirps on Chirpy."
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		cfg.renderErrorPage(w, meta, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirps, err := cfg.db.GetLatestChirps(r.Context(), database.GetLatestChirpsParams{
+		ViewerID:   viewer.ID,
+		PageSize:   limit + 1,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve chirps from db: %v", err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "The chirps can't be shown right now.")
+		return
+	}
+	chirps, olderURL := pageOfChirps(r, chirps, limit, offset)
+	pageChirps, err := cfg.webChirps(r.Context(), cfg.baseURL(r), chirps)
+	if err != nil {
+		log.Printf("unable to build chirps page: %v", err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "The chirps can't be shown right now.")
+		return
+	}
+	cfg.renderPage(w, http.StatusOK, web.PageTimeline, web.TimelinePage{
+		Meta:     meta,
+		Chirps:   pageChirps,
+		OlderURL: olderURL,
+	})
+}
+
+// handlerWebChirp renders a chirp, with the Open Graph tags of its link
+// previews.
+func (cfg *apiConfig) handlerWebChirp(w http.ResponseWriter, r *http.Request) {
+	viewer, _ := cfg.webViewer(r)
+	meta := cfg.pageMeta(r, viewer)
+	id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		cfg.renderErrorPage(w, meta, http.StatusNotFound, "This chirp doesn't exist.")
+		return
+	}
+	dbChirp, err := cfg.db.GetChirpByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.renderErrorPage(w, meta, http.StatusNotFound, "This chirp doesn't exist.")
+			return
+		}
+		log.Printf("unable to retrieve chirp '%s': %v", id, err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This chirp can't be shown right now.")
+		return
+	}
+	if dbChirp.DeletedAt.Valid {
+		cfg.renderErrorPage(w, meta, http.StatusGone, "This chirp has been deleted.")
+		return
+	}
+	visible, err := cfg.chirpVisibleTo(r.Context(), dbChirp, viewer.ID)
+	if err != nil {
+		log.Printf("unable to check visibility of chirp '%s': %v", dbChirp.ID, err)
+	}
+	if !visible {
+		cfg.renderErrorPage(w, meta, http.StatusNotFound, "This chirp doesn't exist.")
+		return
+	}
+	base := cfg.baseURL(r)
+	chirps, err := cfg.webChirps(r.Context(), base, []database.Chirp{dbChirp})
+	if err != nil {
+		log.Printf("unable to build chirp page: %v", err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This chirp can't be shown right now.")
+		return
+	}
+	chirp := chirps[0]
+	meta.Title = chirp.Author.Name() + " on Chirpy"
+	meta.Description = web.Truncate(chirp.Body)
+	meta.Type = "article"
+	meta.Image = chirp.Author.AvatarURL
+	for _, m := range chirp.Media {
+		if strings.HasPrefix(m.ContentType, "image/") {
+			meta.Image = m.URL
+			break
+		}
+	}
+	meta.Feeds = userFeeds(base, dbChirp.UserID)
+	cfg.renderPage(w, http.StatusOK, web.PageChirp, web.ChirpPage{Meta: meta, Chirp: chirp})
+}
+
+// handlerWebProfile renders the profile of the user with the handle in the
+// path, and their latest chirps.
+func (cfg *apiConfig) handlerWebProfile(w http.ResponseWriter, r *http.Request) {
+	viewer, _ := cfg.webViewer(r)
+	meta := cfg.pageMeta(r, viewer)
+	user, err := cfg.db.GetUserByHandle(r.Context(), handle.Normalize(r.PathValue("handle")))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.renderErrorPage(w, meta, http.StatusNotFound, "This user doesn't exist.")
+			return
+		}
+		log.Printf("unable to get user by handle: %v", err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This profile can't be shown right now.")
+		return
+	}
+	if viewer.ID != uuid.Nil && viewer.ID != user.ID {
+		blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{UserA: viewer.ID, UserB: user.ID})
+		if err != nil || blocked {
+			cfg.renderErrorPage(w, meta, http.StatusNotFound, "This user doesn't exist.")
+			return
+		}
+	}
+	profile, err := cfg.webProfile(r.Context(), cfg.baseURL(r), user)
+	if err != nil {
+		log.Printf("unable to get profile of user '%s': %v", user.ID, err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This profile can't be shown right now.")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		cfg.renderErrorPage(w, meta, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirps, err := cfg.db.GetLatestChirpsByUserID(r.Context(), database.GetLatestChirpsByUserIDParams{
+		UserID:     user.ID,
+		ViewerID:   viewer.ID,
+		PageSize:   limit + 1,
+		PageOffset: offset,
+	})
+	if err != nil {
+		log.Printf("unable to retrieve chirps of user '%s': %v", user.ID, err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This profile can't be shown right now.")
+		return
+	}
+	chirps, olderURL := pageOfChirps(r, chirps, limit, offset)
+	pageChirps, err := cfg.webChirps(r.Context(), cfg.baseURL(r), chirps)
+	if err != nil {
+		log.Printf("unable to build profile page: %v", err)
+		cfg.renderErrorPage(w, meta, http.StatusInternalServerError, "This profile can't be shown right now.")
+		return
+	}
+	meta.Title = profile.Name()
+	if profile.DisplayName != "" {
+		meta.Title += " (@" + profile.Handle + ")"
+	}
+	meta.Description = web.Truncate(profile.Bio)
+	meta.Type = "profile"
+	meta.Image = profile.AvatarURL
+	meta.Feeds = userFeeds(cfg.baseURL(r), user.ID)
+	cfg.renderPage(w, http.StatusOK, web.PageProfile, web.ProfilePage{
+		Meta:     meta,
+		Profile:  profile,
+		Chirps:   pageChirps,
+		OlderURL: olderURL,
+	})
+}
+
+func (cfg *apiConfig) webProfile(ctx context.Context, base string, user database.User) (web.Profile, error) {
+	authors, err := cfg.authorsByID(ctx, []uuid.UUID{user.ID})
+	if err != nil {
+		return web.Profile{}, err
+	}
+	counts, err := cfg.db.GetFollowCounts(ctx, user.ID)
+	if err != nil {
+		return web.Profile{}, err
+	}
+	chirps, err := cfg.db.GetUserChirpCount(ctx, user.ID)
+	if err != nil {
+		return web.Profile{}, err
+	}
+	return web.Profile{
+		Author: web.Author{
+			Handle:      user.Handle.String,
+			DisplayName: user.DisplayName,
+			AvatarURL:   absoluteURL(base, authors[user.ID].AvatarURL),
+		},
+		Bio:         user.Bio,
+		IsChirpyRed: user.IsChirpyRed.Bool,
+		Followers:   counts.Followers,
+		Following:   counts.Following,
+		Chirps:      chirps,
+		JoinedAt:    user.CreatedAt,
+	}, nil
+}
+
+// userFeeds returns the feeds of the chirps of a user.
+func userFeeds(base string, userID uuid.UUID) []web.Feed {
+	prefix := base + "/users/" + userID.String() + "/feed"
+	return []web.Feed{
+		{Title: "Atom", Type: "application/atom+xml", URL: prefix + ".atom"},
+		{Title: "RSS", Type: "application/rss+xml", URL: prefix + ".rss"},
+		{Title: "JSON Feed", Type: "application/feed+json", URL: prefix + ".json"},
+	}
+}
+
+func (cfg *apiConfig) handlerWebLogin(w http.ResponseWriter, r *http.Request) {
+	viewer, ok := cfg.webViewer(r)
+	if ok {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	meta := cfg.pageMeta(r, viewer)
+	meta.Title = "Log in to Chirpy"
+	meta.NoIndex = true
+	cfg.renderPage(w, http.StatusOK, web.PageLogin, web.LoginPage{Meta: meta})
+}
+
+// handlerWebLoginSubmit logs a user in the web pages with the login form, and
+// keeps their access token in the session cookie.
+func (cfg *apiConfig) handlerWebLoginSubmit(w http.ResponseWriter, r *http.Request) {
+	meta := cfg.pageMeta(r, database.User{})
+	meta.Title = "Log in to Chirpy"
+	meta.NoIndex = true
+	email := r.PostFormValue("email")
+	page := web.LoginPage{Meta: meta, Email: email}
+	user, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if err == nil {
+		err = auth.CheckPasswordHash(user.HashedPassword, r.PostFormValue("password"))
+	}
+	if err != nil {
+		page.Error = "Invalid email or password."
+		cfg.renderPage(w, http.StatusUnauthorized, web.PageLogin, page)
+		return
+	}
+	if isSuspended(user) {
+		page.Error = "Your " + suspensionMessage(user) + "."
+		cfg.renderPage(w, http.StatusForbidden, web.PageLogin, page)
+		return
+	}
+	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, accessTokenDuration)
+	if err != nil {
+		log.Printf("unable to create JWT for user '%s': %v", user.ID, err)
+		page.Error = "You can't log in right now."
+		cfg.renderPage(w, http.StatusInternalServerError, web.PageLogin, page)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().UTC().Add(accessTokenDuration),
+		HttpOnly: true,
+		Secure:   cfg.platform != "dev",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (cfg *apiConfig) handlerWebLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   cfg.platform != "dev",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// profileURL returns the URL of the profile page of a user, who needs a
+// handle to have one.
+func profileURL(base, userHandle string) (string, bool) {
+	if userHandle == "" {
+		return "", false
+	}
+	return base + "/users/" + url.PathEscape(userHandle), true
+}
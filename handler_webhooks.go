@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookDeliverInterval = 5 * time.Second
+	// webhookBatchSize is how many deliveries are sent concurrently.
+	webhookBatchSize = 20
+	// webhookLease is how long a delivery being sent is hidden from the
+	// other server instances.
+	webhookLease     = time.Minute
+	webhookTestEvent = "webhook.test"
+
+	webhookStatusPending   = "pending"
+	webhookStatusDelivered = "delivered"
+	webhookStatusFailed    = "failed"
+)
+
+type webhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Global    bool      `json:"global"`
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type webhookAttemptResponse struct {
+	CreatedAt    time.Time `json:"created_at"`
+	StatusCode   *int32    `json:"status_code"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body"`
+	DurationMs   int32     `json:"duration_ms"`
+}
+
+type webhookDeliveryResponse struct {
+	ID            uuid.UUID                `json:"id"`
+	CreatedAt     time.Time                `json:"created_at"`
+	EventID       *uuid.UUID               `json:"event_id"`
+	Event         string                   `json:"event"`
+	Payload       json.RawMessage          `json:"payload"`
+	Status        string                   `json:"status"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+	Attempts      []webhookAttemptResponse `json:"attempts"`
+}
+
+func newWebhookResponse(h database.Webhook) webhookResponse {
+	eventTypes := h.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return webhookResponse{
+		ID:        h.ID,
+		CreatedAt: h.CreatedAt,
+		URL:       h.Url,
+		Events:    eventTypes,
+		Global:    h.Global,
+	}
+}
+
+func newWebhookDeliveryResponse(d database.WebhookDelivery, attempts []database.WebhookAttempt) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		Event:     d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  []webhookAttemptResponse{},
+	}
+	if d.EventID.Valid {
+		resp.EventID = &d.EventID.UUID
+	}
+	if d.Status == webhookStatusPending {
+		resp.NextAttemptAt = &d.NextAttemptAt
+	}
+	for _, a := range attempts {
+		attempt := webhookAttemptResponse{
+			CreatedAt:    a.CreatedAt,
+			Error:        a.Error.String,
+			ResponseBody: a.ResponseBody,
+			DurationMs:   a.DurationMs,
+		}
+		if a.StatusCode.Valid {
+			attempt.StatusCode = &a.StatusCode.Int32
+		}
+		resp.Attempts = append(resp.Attempts, attempt)
+	}
+	return resp
+}
+
+// eventSubject returns the user a domain event concerns, whose webhooks get
+// it.
+func eventSubject(e events.Event) uuid.UUID {
+	switch e := e.(type) {
+	case *events.ChirpCreated:
+		return e.AuthorID
+	case *events.ChirpDeleted:
+		return e.AuthorID
+	case *events.ChirpRestored:
+		return e.AuthorID
+	case *events.ChirpHidden:
+		return e.AuthorID
+	case *events.ChirpLiked:
+		return e.AuthorID
+	case *events.UserCreated:
+		return e.UserID
+	case *events.UserFollowed:
+		return e.FolloweeID
+	case *events.UserUpgraded:
+		return e.UserID
+	case *events.UserDowngraded:
+		return e.UserID
+	default:
+		return uuid.Nil
+	}
+}
+
+// queueWebhookDeliveries is the event subscriber creating the deliveries of
+// a domain event for the webhooks subscribed to it.
+func (cfg *apiConfig) queueWebhookDeliveries(ctx context.Context, r events.Record) error {
+	e, err := r.Decode()
+	if err != nil {
+		return err
+	}
+	return cfg.db.CreateEventWebhookDeliveries(ctx, database.CreateEventWebhookDeliveriesParams{
+		EventID:   r.ID,
+		EventType: r.Type,
+		Payload:   r.Payload,
+		SubjectID: eventSubject(e),
+	})
+}
+
+// deliverWebhooks sends the due webhook deliveries.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	for {
+		deliveries, err := cfg.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			BatchSize:  webhookBatchSize,
+			LeaseUntil: time.Now().UTC().Add(webhookLease),
+		})
+		if err != nil {
+			return err
+		}
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				hook, err := cfg.db.GetWebhookByID(ctx, d.WebhookID)
+				if err != nil {
+					log.Printf("unable to get webhook '%s': %v", d.WebhookID, err)
+					return
+				}
+				if _, _, err := cfg.sendWebhookDelivery(ctx, hook, d); err != nil {
+					log.Printf("unable to record webhook delivery '%s': %v", d.ID, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if len(deliveries) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+// sendWebhookDelivery makes an attempt of a claimed delivery, and records
+// its outcome. Failed deliveries are retried with an exponential backoff,
+// until they run out of attempts and move to the dead letters.
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, hook database.Webhook, d database.WebhookDelivery) (database.WebhookDelivery, database.WebhookAttempt, error) {
+	// Receivers deduplicate on the ID, which is the same for every endpoint
+	// getting an event.
+	id := d.ID
+	if d.EventID.Valid {
+		id = d.EventID.UUID
+	}
+	body, err := json.Marshal(struct {
+		ID        uuid.UUID       `json:"id"`
+		Type      string          `json:"type"`
+		CreatedAt time.Time       `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
+	}{id, d.EventType, d.CreatedAt, d.Payload})
+	if err != nil {
+		return d, database.WebhookAttempt{}, err
+	}
+	now := time.Now().UTC()
+	res := cfg.webhookSender.Send(ctx, webhooks.Request{
+		URL:        hook.Url,
+		Secret:     hook.Secret,
+		DeliveryID: d.ID.String(),
+		Event:      d.EventType,
+		Body:       body,
+	}, now)
+
+	attempt := database.WebhookAttempt{
+		CreatedAt:    now,
+		DeliveryID:   d.ID,
+		StatusCode:   sql.NullInt32{Int32: int32(res.StatusCode), Valid: res.StatusCode != 0},
+		ResponseBody: res.Body,
+		DurationMs:   int32(res.Duration.Milliseconds()),
+	}
+	if res.Err != nil {
+		attempt.Error = sql.NullString{String: res.Err.Error(), Valid: true}
+	}
+	if err := cfg.db.CreateWebhookAttempt(ctx, database.CreateWebhookAttemptParams{
+		DeliveryID:   attempt.DeliveryID,
+		StatusCode:   attempt.StatusCode,
+		Error:        attempt.Error,
+		ResponseBody: attempt.ResponseBody,
+		DurationMs:   attempt.DurationMs,
+	}); err != nil {
+		return d, attempt, err
+	}
+	status, next := webhookStatusDelivered, now
+	if !res.OK() {
+		status, next = webhookStatusPending, now.Add(webhooks.Backoff(int(d.Attempts)))
+		if d.Attempts >= webhooks.MaxAttempts {
+			status = webhookStatusFailed
+		}
+	}
+	d, err = cfg.db.SetWebhookDeliveryStatus(ctx, database.SetWebhookDeliveryStatusParams{
+		ID:            d.ID,
+		Status:        status,
+		NextAttemptAt: next,
+	})
+	return d, attempt, err
+}
+
+// ownedWebhook returns the webhook of the path, checking that it belongs to
+// the authenticated user. If not, it responds with the appropriate error
+// and returns false.
+func (cfg *apiConfig) ownedWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return database.Webhook{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return database.Webhook{}, false
+	}
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid webhook ID")
+		return database.Webhook{}, false
+	}
+	hook, err := cfg.db.GetWebhookByID(r.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && hook.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "webhook not found")
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		log.Printf("unable to get webhook '%s': %v", webhookID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get webhook")
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+// handlerCreateWebhook registers an endpoint getting the events concerning
+// the user, optionally filtered by type. Admins can register global
+// endpoints, getting the events of every user. The signing secret is only
+// returned in this response.
+func (cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Global bool     `json:"global"`
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	defer r.Body.Close()
+	var params parameters
+	if err := decodeJSON(w, r, &params); err != nil {
+		log.Printf("unable to decode request: %v", err)
+		respondWithError(w, http.StatusBadRequest, "unable to decode request")
+		return
+	}
+	if err := webhooks.ValidateURL(params.URL); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, e := range params.Events {
+		if !slices.Contains(events.Types, e) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown event '%s'", e))
+			return
+		}
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user '%s': %v", userID, err)
+		respondWithError(w, http.StatusUnauthorized, "unknown user")
+		return
+	}
+	if params.Global && !user.IsAdmin {
+		respondWithError(w, http.StatusForbidden, "only admins can register global webhooks")
+		return
+	}
+	count, err := cfg.db.CountWebhooksByUserID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to count webhooks: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create webhook")
+		return
+	}
+	if maxWebhooks := cfg.limitsOf(user).MaxWebhooks; count >= int64(maxWebhooks) {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("at most %d webhooks per user", maxWebhooks))
+		return
+	}
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		log.Printf("unable to generate webhook secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create webhook")
+		return
+	}
+	hook, err := cfg.db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID:     userID,
+		Url:        params.URL,
+		Secret:     secret,
+		Global:     params.Global,
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(params.Events))),
+	})
+	if err != nil {
+		log.Printf("unable to create webhook: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to create webhook")
+		return
+	}
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	hooks, err := cfg.db.GetWebhooksByUserID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get webhooks: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get webhooks")
+		return
+	}
+	resp := []webhookResponse{}
+	for _, h := range hooks {
+		resp = append(resp, newWebhookResponse(h))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		log.Printf("unable to delete webhook '%s': %v", hook.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerTestWebhook sends a webhook.test event to the endpoint right away,
+// and returns the delivery with the outcome of the attempt. Like other
+// deliveries, it is retried if it failed.
+func (cfg *apiConfig) handlerTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	payload, err := json.Marshal(struct {
+		WebhookID uuid.UUID `json:"webhook_id"`
+	}{hook.ID})
+	if err != nil {
+		log.Printf("unable to encode test event: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to send test event")
+		return
+	}
+	d, err := cfg.db.CreateWebhookDelivery(r.Context(), database.CreateWebhookDeliveryParams{
+		WebhookID:  hook.ID,
+		EventType:  webhookTestEvent,
+		Payload:    payload,
+		LeaseUntil: time.Now().UTC().Add(webhookLease),
+	})
+	if err != nil {
+		log.Printf("unable to create test delivery: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to send test event")
+		return
+	}
+	d, attempt, err := cfg.sendWebhookDelivery(r.Context(), hook, d)
+	if err != nil {
+		log.Printf("unable to record test delivery '%s': %v", d.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to send test event")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookDeliveryResponse(d, []database.WebhookAttempt{attempt}))
+}
+
+// handlerGetWebhookDeliveries returns the deliveries of a webhook, most
+// recent first, with every attempt made.
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	deliveries, err := cfg.db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		log.Printf("unable to get webhook deliveries: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get deliveries")
+		return
+	}
+	ids := make([]uuid.UUID, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	attempts, err := cfg.db.GetWebhookAttempts(r.Context(), ids)
+	if err != nil {
+		log.Printf("unable to get webhook attempts: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to get deliveries")
+		return
+	}
+	byDelivery := map[uuid.UUID][]database.WebhookAttempt{}
+	for _, a := range attempts {
+		byDelivery[a.DeliveryID] = append(byDelivery[a.DeliveryID], a)
+	}
+	resp := []webhookDeliveryResponse{}
+	for _, d := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(d, byDelivery[d.ID]))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerRetryWebhookDelivery moves a dead letter back to the pending
+// deliveries.
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid delivery ID")
+		return
+	}
+	d, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: hook.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "failed delivery not found")
+		return
+	}
+	if err != nil {
+		log.Printf("unable to retry webhook delivery '%s': %v", deliveryID, err)
+		respondWithError(w, http.StatusInternalServerError, "unable to retry delivery")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookDeliveryResponse(d, nil))
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/chirptext"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/realtime"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationEventChannel = "notification_events"
+
+	wsMaxConnsPerUser = 5
+	// wsBacklog is how many messages a connection can lag behind before it
+	// is closed.
+	wsBacklog    = 64
+	wsMaxTopics  = 50
+	wsReadLimit  = 4096
+	wsPingPeriod = 30 * time.Second
+	wsWriteWait  = 10 * time.Second
+	// wsExpiryNotice is how long before the token of a connection expires
+	// the client is asked for a new one.
+	wsExpiryNotice = time.Minute
+
+	// wsStatusTokenExpired closes connections whose token expired before the
+	// client sent a new one.
+	wsStatusTokenExpired websocket.StatusCode = 4001
+
+	topicTimeline      = "timeline"
+	topicNotifications = "notifications"
+	topicUserPrefix    = "user:"
+	topicTagPrefix     = "tag:"
+)
+
+// wsClientMessage is a message sent by WebSocket clients.
+type wsClientMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// wsServerMessage is a message sent to WebSocket clients.
+type wsServerMessage struct {
+	Type      string          `json:"type"`
+	Topic     string          `json:"topic,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// wsTopic returns the routing key of the topic named topic for userID, and
+// its canonical name.
+func wsTopic(userID uuid.UUID, topic string) (key, name string, err error) {
+	switch {
+	case topic == topicTimeline || topic == topicNotifications:
+		return topic + ":" + userID.String(), topic, nil
+	case strings.HasPrefix(topic, topicUserPrefix):
+		id, err := uuid.Parse(strings.TrimPrefix(topic, topicUserPrefix))
+		if err != nil {
+			return "", "", errors.New("invalid user ID")
+		}
+		name = topicUserPrefix + id.String()
+		return name, name, nil
+	case strings.HasPrefix(topic, topicTagPrefix):
+		tag := chirptext.NormalizeTag(strings.TrimPrefix(topic, topicTagPrefix))
+		if tag == "" {
+			return "", "", errors.New("invalid hashtag")
+		}
+		name = topicTagPrefix + tag
+		return name, name, nil
+	default:
+		return "", "", fmt.Errorf("unknown topic '%s'", topic)
+	}
+}
+
+// publishRealtimeChirp sends a chirp event to the connections subscribed to
+// the author, to the hashtags of the chirp, or to the timeline of the author
+// or of one of their followers.
+func (cfg *apiConfig) publishRealtimeChirp(ctx context.Context, eventType string, chirp database.Chirp, data []byte) error {
+	send := func(key, topic string) {
+		cfg.realtime.Publish(key, realtime.Message{
+			Topic:    topic,
+			Type:     eventType,
+			AuthorID: chirp.UserID,
+			Data:     data,
+		})
+	}
+	user := topicUserPrefix + chirp.UserID.String()
+	send(user, user)
+	for _, tag := range chirptext.Hashtags(chirp.Body) {
+		send(topicTagPrefix+tag, topicTagPrefix+tag)
+	}
+	followers, err := cfg.db.GetFollowerIDs(ctx, chirp.UserID)
+	if err != nil {
+		return fmt.Errorf("unable to get followers of '%s': %w", chirp.UserID, err)
+	}
+	for _, userID := range append(followers, chirp.UserID) {
+		send(topicTimeline+":"+userID.String(), topicTimeline)
+	}
+	return nil
+}
+
+// publishNotificationEvent sends the new unread count of a user to their
+// connections subscribed to notifications.
+func (cfg *apiConfig) publishNotificationEvent(ctx context.Context, payload string) error {
+	userID, err := uuid.Parse(payload)
+	if err != nil {
+		return err
+	}
+	key := topicNotifications + ":" + userID.String()
+	if !cfg.realtime.Subscribed(key) {
+		return nil
+	}
+	unread, err := cfg.db.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("unable to count unread notifications of '%s': %w", userID, err)
+	}
+	data, err := json.Marshal(struct {
+		UnreadCount int64 `json:"unread_count"`
+	}{unread})
+	if err != nil {
+		return err
+	}
+	cfg.realtime.Publish(key, realtime.Message{
+		Topic: topicNotifications,
+		Type:  "notification",
+		Data:  data,
+	})
+	return nil
+}
+
+// wsToken returns the access token of a WebSocket handshake. Browsers can't
+// set headers on WebSocket requests, so the token can be passed in the
+// access_token query parameter too.
+func wsToken(r *http.Request) (string, error) {
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		return token, nil
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token, nil
+	}
+	return "", errors.New("missing access token")
+}
+
+// handlerWebSocket upgrades the request to a WebSocket connection pushing
+// the events of the topics the client subscribes to. Clients send
+// {"type":"subscribe","topic":...} and {"type":"unsubscribe","topic":...}
+// messages, with one of these topics:
+//   - timeline: chirps of the user and of the users they follow
+//   - notifications: unread count of the user, when it changes
+//   - user:<id>: chirps of a user
+//   - tag:<hashtag>: chirps with a hashtag
+//
+// Chirps of users the client blocked or muted are left out. A minute before
+// the access token expires, the server sends a token_expiring message, and
+// closes the connection when it expires unless the client sent a new token
+// with {"type":"auth","token":...}.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := wsToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return
+	}
+	userID, expiresAt, err := auth.ValidateJWTExpiry(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	ids, err := cfg.db.GetHiddenAuthorIDs(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get hidden authors: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to open connection")
+		return
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	rtConn, err := cfg.realtime.Register(userID)
+	if errors.Is(err, realtime.ErrTooManyConnections) {
+		respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("at most %d connections per user", wsMaxConnsPerUser))
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "server shutting down")
+		return
+	}
+	defer rtConn.Close()
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		log.Printf("unable to accept websocket: %v", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	// The client messages are read in their own goroutine, and everything
+	// is written from the loop below.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	incoming := make(chan wsClientMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var msg wsClientMessage
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case incoming <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	send := func(msg wsServerMessage) error {
+		writeCtx, cancel := context.WithTimeout(ctx, wsWriteWait)
+		defer cancel()
+		return wsjson.Write(writeCtx, conn, msg)
+	}
+	// Tokens without an expiration time never need renewing
+	var notice, expiry <-chan time.Time
+	setExpiry := func(at time.Time) {
+		if at.IsZero() {
+			notice, expiry = nil, nil
+			return
+		}
+		notice = time.After(time.Until(at.Add(-wsExpiryNotice)))
+		expiry = time.After(time.Until(at))
+	}
+	setExpiry(expiresAt)
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErr:
+			if websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+				conn.Close(websocket.StatusPolicyViolation, "invalid message")
+			}
+			return
+		case <-rtConn.Done():
+			if errors.Is(rtConn.Err(), realtime.ErrTooSlow) {
+				conn.Close(websocket.StatusPolicyViolation, "connection too slow")
+			} else {
+				conn.Close(websocket.StatusGoingAway, "server shutting down")
+			}
+			return
+		case <-expiry:
+			conn.Close(wsStatusTokenExpired, "token expired")
+			return
+		case <-notice:
+			err = send(wsServerMessage{Type: "token_expiring", ExpiresAt: &expiresAt})
+		case <-ping.C:
+			pingCtx, cancel := context.WithTimeout(ctx, wsWriteWait)
+			err = conn.Ping(pingCtx)
+			cancel()
+		case msg := <-rtConn.C:
+			if hidden[msg.AuthorID] {
+				continue
+			}
+			err = send(wsServerMessage{Type: "event", Topic: msg.Topic, Event: msg.Type, Data: msg.Data})
+		case msg := <-incoming:
+			switch msg.Type {
+			case "subscribe", "unsubscribe":
+				key, name, topicErr := wsTopic(userID, msg.Topic)
+				switch {
+				case topicErr != nil:
+					err = send(wsServerMessage{Type: "error", Topic: msg.Topic, Error: topicErr.Error()})
+				case msg.Type == "subscribe" && rtConn.Topics() >= wsMaxTopics:
+					err = send(wsServerMessage{Type: "error", Topic: msg.Topic, Error: fmt.Sprintf("at most %d topics per connection", wsMaxTopics)})
+				case msg.Type == "subscribe":
+					rtConn.Subscribe(key)
+					err = send(wsServerMessage{Type: "subscribed", Topic: name})
+				default:
+					rtConn.Unsubscribe(key)
+					err = send(wsServerMessage{Type: "unsubscribed", Topic: name})
+				}
+			case "auth":
+				id, at, tokenErr := auth.ValidateJWTExpiry(msg.Token, cfg.jwtSecret)
+				if tokenErr != nil || id != userID {
+					err = send(wsServerMessage{Type: "error", Error: "invalid access token"})
+					break
+				}
+				expiresAt = at
+				setExpiry(at)
+				err = send(wsServerMessage{Type: "authenticated", ExpiresAt: &expiresAt})
+			default:
+				err = send(wsServerMessage{Type: "error", Error: fmt.Sprintf("unknown message type '%s'", msg.Type)})
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -1,19 +1,147 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"github.com/fonspa/go-http-server/internal/activitypub"
+	"github.com/fonspa/go-http-server/internal/auth"
+	"github.com/fonspa/go-http-server/internal/blob"
 	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/entitlements"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/filter"
+	"github.com/fonspa/go-http-server/internal/realtime"
+	"github.com/fonspa/go-http-server/internal/stream"
+	"github.com/fonspa/go-http-server/internal/timeline"
+	"github.com/fonspa/go-http-server/internal/web"
+	"github.com/fonspa/go-http-server/internal/webhooks"
+	"github.com/google/uuid"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
 	jwtSecret      string
 	polkaKey       string
+	// polkaLimiter limits the requests to the Polka webhook of each client.
+	polkaLimiter *webhooks.Limiter
+	blobStore    blob.Store
+	filter       atomic.Pointer[filter.Engine]
+	// entitlements gives the limits of each tier of users.
+	entitlements *entitlements.Config
+	// chirpRetention is how long a deleted chirp is kept before being
+	// purged. It is at least the longest undo window.
+	chirpRetention time.Duration
+	// reportHideThreshold is the number of open reports that hides a chirp
+	// until a moderator reviews it.
+	reportHideThreshold int
+	// timeline builds home timelines, by fan-out on read or on write.
+	timeline timeline.Strategy
+	trending *trendingState
+	// chirpStream dispatches the chirp events of every server instance to
+	// the clients of the chirp stream.
+	chirpStream *stream.Hub
+	// realtime routes the events of every server instance to the WebSocket
+	// connections subscribed to them.
+	realtime *realtime.Hub
+	// events dispatches the domain events of the outbox to in-process
+	// subscribers.
+	events *events.Bus
+	// webhookSender sends the deliveries of the webhooks registered by users.
+	webhookSender *webhooks.Sender
+	// publicURL is the URL the server is reached at, from PUBLIC_URL, or
+	// empty to build absolute URLs from the host of requests.
+	publicURL string
+	// federation implements ActivityPub, nil unless PUBLIC_URL is set.
+	federation *activitypub.Server
+	// pages renders the public web pages.
+	pages *web.Renderer
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// maxJSONBodySize is the largest JSON request body, far above what any
+// valid request needs.
+const maxJSONBodySize = 64 << 10
+
+// decodeJSON decodes the JSON body of r into v, failing on bodies larger than
+// maxJSONBodySize.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBodySize)).Decode(v)
+}
+
+// parsePagination reads the "limit" and "offset" query parameters.
+func parsePagination(r *http.Request) (limit, offset int32, err error) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = int32(n)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a positive integer")
+		}
+		offset = int32(n)
+	}
+	return limit, offset, nil
+}
+
+// optionalUserID returns the ID of the authenticated user, or uuid.Nil for
+// anonymous requests and invalid tokens, on endpoints that don't require
+// authentication but show more to logged in users.
+func (cfg *apiConfig) optionalUserID(r *http.Request) uuid.UUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return uuid.Nil
+	}
+	return userID
+}
+
+// authorizeAdmin checks that the request comes from an admin user. If not, it
+// responds with the appropriate error and returns false.
+func (cfg *apiConfig) authorizeAdmin(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("unable to get user '%s': %v", userID, err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return database.User{}, false
+	}
+	if !user.IsAdmin {
+		respondWithError(w, http.StatusForbidden, "admin access required")
+		return database.User{}, false
+	}
+	return user, true
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -43,3 +171,30 @@ func handlerHealthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
+
+// targetUser authenticates the request and returns the IDs of the user and of
+// the user in the path, who the action applies to, or writes an error response
+// and returns false.
+func (cfg *apiConfig) targetUser(w http.ResponseWriter, r *http.Request, action string) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid bearer token")
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("unable to validate JWT: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("you can't %s yourself", action))
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
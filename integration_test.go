@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fonspa/go-http-server/internal/activitypub"
+	"github.com/fonspa/go-http-server/internal/blob"
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/entitlements"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/realtime"
+	"github.com/fonspa/go-http-server/internal/stream"
+	"github.com/fonspa/go-http-server/internal/timeline"
+	"github.com/fonspa/go-http-server/internal/trending"
+	"github.com/fonspa/go-http-server/internal/web"
+	"github.com/fonspa/go-http-server/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// testInstance is a server instance of the integration tests, with its own
+// public URL and in-process state, and the shared test database.
+type testInstance struct {
+	cfg *apiConfig
+	srv *httptest.Server
+}
+
+// testUser is a user signed up through the API of an instance.
+type testUser struct {
+	ID     uuid.UUID
+	Handle string
+	Token  string
+}
+
+func newTestInstance(t *testing.T, db *sql.DB) *testInstance {
+	t.Helper()
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	dbQueries := database.New(db)
+	// Test servers listen on the loopback address
+	webhookSender := webhooks.NewSender(true)
+	federation, err := activitypub.NewServer(srv.URL, federationStore{db: dbQueries}, activitypub.NewClient(webhookSender.HTTPClient()))
+	if err != nil {
+		t.Fatalf("unable to set up federation: %v", err)
+	}
+	blobStore, err := blob.NewLocalStore(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatalf("unable to set up media storage: %v", err)
+	}
+	homeTimeline, _ := timeline.New(timeline.FanOutOnRead)
+	pages, err := web.New()
+	if err != nil {
+		t.Fatalf("unable to parse the web pages: %v", err)
+	}
+	cfg := &apiConfig{
+		db:                  dbQueries,
+		dbConn:              db,
+		platform:            "dev",
+		jwtSecret:           "integration-test-secret",
+		polkaKey:            "integration-test-key",
+		polkaLimiter:        webhooks.NewLimiter(polkaRatePerMinute, polkaRateBurst),
+		blobStore:           blobStore,
+		entitlements:        entitlements.Default(),
+		chirpRetention:      defaultChirpRetention,
+		reportHideThreshold: defaultReportHideThreshold,
+		timeline:            homeTimeline,
+		trending:            newTrendingState(trending.SystemClock),
+		chirpStream:         stream.NewHub(streamReplaySize, streamBacklog),
+		realtime:            realtime.NewHub(wsMaxConnsPerUser, wsBacklog),
+		events:              events.NewBus(),
+		webhookSender:       webhookSender,
+		publicURL:           srv.URL,
+		federation:          federation,
+		pages:               pages,
+	}
+	cfg.subscribeEvents()
+	if err := cfg.loadFilter(context.Background()); err != nil {
+		t.Fatalf("unable to load the chirp filter: %v", err)
+	}
+	handler = cfg.routes()
+	return &testInstance{cfg: cfg, srv: srv}
+}
+
+// do sends a request with a JSON body, unless body is nil, checks its status
+// and decodes the response into out, unless out is nil.
+func (in *testInstance) do(t *testing.T, method, path, token string, body any, wantStatus int, out any) {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, in.srv.URL+path, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := in.srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != wantStatus {
+		var resp bytes.Buffer
+		resp.ReadFrom(res.Body)
+		t.Fatalf("%s %s: want status %d, got %d: %s", method, path, wantStatus, res.StatusCode, resp.String())
+	}
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: unable to decode response: %v", method, path, err)
+		}
+	}
+}
+
+// signUp creates a user with a handle and logs them in.
+func (in *testInstance) signUp(t *testing.T) testUser {
+	t.Helper()
+	params := map[string]string{
+		"email":    "it-" + uuid.NewString() + "@example.com",
+		"password": "Integration-Test-Password-1",
+		"handle":   "it" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12],
+	}
+	var user userResponse
+	in.do(t, http.MethodPost, "/api/users", "", params, http.StatusCreated, &user)
+	in.do(t, http.MethodPost, "/api/login", "", params, http.StatusOK, &user)
+	return testUser{ID: user.ID, Handle: user.Handle, Token: user.Token}
+}
+
+// dispatch hands the pending domain events to the subscribers of the
+// instance, as its background job would.
+func (in *testInstance) dispatch(t *testing.T) {
+	t.Helper()
+	if err := in.cfg.dispatchEvents(context.Background()); err != nil {
+		t.Fatalf("unable to dispatch events: %v", err)
+	}
+}
+
+// TestTwoInstances runs two server instances against the database given by
+// CHIRPY_TEST_DB_URL, which must be migrated, and checks that users of one
+// see what users of the other do: locally, since both share the database,
+// and through federation, since each has its own public URL. Users and
+// remote actors created by the test are removed at the end.
+func TestTwoInstances(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("unable to open the database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	a, b := newTestInstance(t, db), newTestInstance(t, db)
+	alice, bob := a.signUp(t), b.signUp(t)
+	aliceActor, bobActor := a.cfg.federation.ActorID(alice.Handle), b.cfg.federation.ActorID(bob.Handle)
+	defer func() {
+		if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ANY($1)", pq.Array([]uuid.UUID{alice.ID, bob.ID})); err != nil {
+			t.Errorf("unable to clean up users: %v", err)
+		}
+		if _, err := db.ExecContext(ctx, "DELETE FROM remote_actors WHERE uri = ANY($1)", pq.Array([]string{aliceActor, bobActor})); err != nil {
+			t.Errorf("unable to clean up remote actors: %v", err)
+		}
+	}()
+
+	// Follow, both on the shared database and from the remote server
+	a.do(t, http.MethodPost, "/api/users/"+bob.ID.String()+"/follow", alice.Token, nil, http.StatusNoContent, nil)
+	a.do(t, http.MethodPost, "/api/remote-follows", alice.Token,
+		map[string]string{"account": bob.Handle + "@" + b.cfg.federation.Domain()}, http.StatusAccepted, nil)
+
+	// Create
+	var chirp chirpResponse
+	b.do(t, http.MethodPost, "/api/chirps", bob.Token, map[string]string{"body": "Hello from the other instance"}, http.StatusCreated, &chirp)
+	b.dispatch(t)
+	var page timelineResponse
+	a.do(t, http.MethodGet, "/api/timeline", alice.Token, nil, http.StatusOK, &page)
+	if len(page.Chirps) == 0 || page.Chirps[0].ID != chirp.ID {
+		t.Errorf("want chirp '%s' at the top of the timeline, got %v", chirp.ID, page.Chirps)
+	}
+	noteID := b.cfg.federation.NoteID(chirp.ID.String())
+	var remote []remoteChirpResponse
+	a.do(t, http.MethodGet, "/api/remote-chirps", alice.Token, nil, http.StatusOK, &remote)
+	if len(remote) != 1 || remote[0].URI != noteID || remote[0].Body != chirp.Body || remote[0].Author.URI != bobActor {
+		t.Fatalf("want the remote chirp '%s', got %+v", noteID, remote)
+	}
+
+	// Like
+	a.do(t, http.MethodPost, "/api/chirps/"+chirp.ID.String()+"/like", alice.Token, nil, http.StatusNoContent, nil)
+	a.do(t, http.MethodPost, "/api/remote-chirps/"+remote[0].ID.String()+"/like", alice.Token, nil, http.StatusNoContent, nil)
+	var remoteLikes int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM remote_likes WHERE chirp_id = $1", chirp.ID).Scan(&remoteLikes); err != nil {
+		t.Fatalf("unable to count remote likes: %v", err)
+	}
+	if remoteLikes != 1 {
+		t.Errorf("want 1 remote like, got %d", remoteLikes)
+	}
+	b.dispatch(t)
+	var notifications notificationsResponse
+	b.do(t, http.MethodGet, "/api/notifications", bob.Token, nil, http.StatusOK, &notifications)
+	types := map[string]bool{}
+	for _, n := range notifications.Notifications {
+		types[n.Type] = true
+	}
+	if !types[notificationFollow] || !types[notificationLike] {
+		t.Errorf("want follow and like notifications, got %+v", notifications.Notifications)
+	}
+
+	// Delete
+	b.do(t, http.MethodDelete, "/api/chirps/"+chirp.ID.String(), bob.Token, nil, http.StatusNoContent, nil)
+	b.dispatch(t)
+	a.do(t, http.MethodGet, "/api/chirps/"+chirp.ID.String(), alice.Token, nil, http.StatusGone, nil)
+	a.do(t, http.MethodGet, "/api/remote-chirps", alice.Token, nil, http.StatusOK, &remote)
+	if len(remote) != 0 {
+		t.Errorf("want no remote chirps once deleted, got %+v", remote)
+	}
+}
@@ -0,0 +1,167 @@
+// Package activitypub federates Chirpy users with Mastodon-compatible
+// servers, following the ActivityPub server-to-server protocol.
+//
+// Every local user with a handle is an actor, discoverable with WebFinger as
+// handle@domain. Their chirps are notes delivered to the inboxes of their
+// remote followers, and the notes of the remote actors they follow are
+// received in their inbox. Requests between servers are authenticated with
+// HTTP Signatures, see Sign and Verify.
+//
+// The Server implements the protocol on top of a Store, which holds local
+// users and what was received from remote servers.
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents.
+	ContentType = "application/activity+json"
+	// LDContentType is the alternative media type of ActivityPub documents,
+	// which servers must accept.
+	LDContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	ContextActivityStreams = "https://www.w3.org/ns/activitystreams"
+	ContextSecurity        = "https://w3id.org/security/v1"
+	// Public is the special collection addressing public objects.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Activity and object types.
+const (
+	TypePerson    = "Person"
+	TypeNote      = "Note"
+	TypeTombstone = "Tombstone"
+	TypeCreate    = "Create"
+	TypeDelete    = "Delete"
+	TypeFollow    = "Follow"
+	TypeAccept    = "Accept"
+	TypeLike      = "Like"
+	TypeUndo      = "Undo"
+
+	TypeOrderedCollection = "OrderedCollection"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned by Stores refusing an activity, like a note
+	// from an actor nobody follows.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Actor is the document describing a user.
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+}
+
+// PublicKey is the key an actor signs its requests with.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// Note is a chirp.
+type Note struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo string     `json:"attributedTo,omitempty"`
+	Content      string     `json:"content,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	Published    *time.Time `json:"published,omitempty"`
+	To           []string   `json:"to,omitempty"`
+	Cc           []string   `json:"cc,omitempty"`
+}
+
+// Activity is an action of an actor on an object. Object is either the ID
+// of the object or the object itself.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+	Published *time.Time      `json:"published,omitempty"`
+}
+
+// NewActivity returns an activity of actor on object, which is either an ID
+// or an object to embed.
+func NewActivity(typ, id, actor string, object any) (Activity, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return Activity{}, err
+	}
+	return Activity{Context: ContextActivityStreams, ID: id, Type: typ, Actor: actor, Object: raw}, nil
+}
+
+// ObjectID returns the ID of the object of a.
+func (a Activity) ObjectID() string {
+	var id string
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(a.Object, &obj)
+	return obj.ID
+}
+
+// ObjectType returns the type of the object of a, or "" when only its ID is
+// given.
+func (a Activity) ObjectType() string {
+	var obj struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(a.Object, &obj)
+	return obj.Type
+}
+
+// Embedded decodes the object embedded in a into v.
+func (a Activity) Embedded(v any) error {
+	return json.Unmarshal(a.Object, v)
+}
+
+// OrderedCollection is a paged or inline list of items.
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+// WebFinger is the response of the WebFinger endpoint, pointing to the actor
+// of an account.
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
@@ -0,0 +1,142 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Timeout bounds every request to remote servers.
+	Timeout = 10 * time.Second
+	// maxDocumentSize is the largest document read from remote servers.
+	maxDocumentSize = 1 << 20
+	userAgent       = "Chirpy-ActivityPub/1.0"
+)
+
+// Signer is the key of a local actor, signing its requests.
+type Signer struct {
+	KeyID string
+	Key   *rsa.PrivateKey
+}
+
+// Client sends requests to remote servers.
+type Client struct {
+	http *http.Client
+	// Scheme is used to reach remote servers by domain, for WebFinger.
+	Scheme string
+}
+
+// NewClient returns a Client sending requests with c, which should refuse
+// private addresses in production.
+func NewClient(c *http.Client) *Client {
+	return &Client{http: c, Scheme: "https"}
+}
+
+// Get fetches the document at uri into v, signing the request with signer
+// if not nil, as servers in authorized fetch mode require.
+func (c *Client) Get(ctx context.Context, uri string, signer *Signer, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", ContentType+", "+LDContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if signer != nil {
+		if err := Sign(req, signer.KeyID, signer.Key, nil, time.Now()); err != nil {
+			return err
+		}
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return fmt.Errorf("%w: %s", ErrNotFound, uri)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d fetching %s", resp.StatusCode, uri)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// FetchActor fetches the actor document at uri.
+func (c *Client) FetchActor(ctx context.Context, uri string, signer *Signer) (Actor, error) {
+	var actor Actor
+	if err := c.Get(ctx, uri, signer, &actor); err != nil {
+		return Actor{}, err
+	}
+	if actor.ID != uri || actor.Inbox == "" || actor.PublicKey.PublicKeyPem == "" {
+		return Actor{}, fmt.Errorf("invalid actor document at %s", uri)
+	}
+	return actor, nil
+}
+
+// Finger resolves an account, given as user@domain, to the ID of its actor
+// with WebFinger.
+func (c *Client) Finger(ctx context.Context, account string) (string, error) {
+	account = strings.TrimPrefix(strings.TrimPrefix(account, "acct:"), "@")
+	_, domain, ok := strings.Cut(account, "@")
+	if !ok || domain == "" || strings.ContainsAny(domain, "/?#@") {
+		return "", fmt.Errorf("invalid account '%s'", account)
+	}
+	u := url.URL{
+		Scheme:   c.Scheme,
+		Host:     domain,
+		Path:     "/.well-known/webfinger",
+		RawQuery: url.Values{"resource": {"acct:" + account}}.Encode(),
+	}
+	var wf WebFinger
+	if err := c.Get(ctx, u.String(), nil, &wf); err != nil {
+		return "", err
+	}
+	for _, link := range wf.Links {
+		if link.Rel == "self" && (link.Type == ContentType || link.Type == LDContentType) {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no actor for '%s'", ErrNotFound, account)
+}
+
+// Post delivers activity to inbox, signed by signer.
+func (c *Client) Post(ctx context.Context, inbox string, signer Signer, activity Activity) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	if err := Sign(req, signer.KeyID, signer.Key, body, time.Now()); err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentSize))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d delivering to %s", resp.StatusCode, inbox)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package activitypub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is a Store in memory.
+type memStore struct {
+	mu           sync.Mutex
+	users        map[string]LocalActor
+	notes        map[string]LocalNote
+	noteAuthors  map[string]string
+	remoteActors map[string]RemoteActor
+	// followers maps usernames to the follow IDs of their followers.
+	followers map[string]map[string]string
+	following map[string]memFollow
+	remote    map[string]RemoteNote
+	likes     map[string]map[string]string
+}
+
+type memFollow struct {
+	username string
+	actorID  string
+	accepted bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		users:        map[string]LocalActor{},
+		notes:        map[string]LocalNote{},
+		noteAuthors:  map[string]string{},
+		remoteActors: map[string]RemoteActor{},
+		followers:    map[string]map[string]string{},
+		following:    map[string]memFollow{},
+		remote:       map[string]RemoteNote{},
+		likes:        map[string]map[string]string{},
+	}
+}
+
+func (m *memStore) addUser(t *testing.T, username string) {
+	t.Helper()
+	priv, pub, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[username] = LocalActor{Username: username, Name: strings.ToUpper(username), PublicKeyPEM: pub, PrivateKeyPEM: priv}
+}
+
+func (m *memStore) addNote(username string, note LocalNote) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notes[note.ID] = note
+	m.noteAuthors[note.ID] = username
+}
+
+func (m *memStore) LocalActor(_ context.Context, username string) (LocalActor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.users[username]
+	if !ok {
+		return LocalActor{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *memStore) LocalNotes(_ context.Context, username string, limit int) ([]LocalNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var notes []LocalNote
+	for id, n := range m.notes {
+		if m.noteAuthors[id] == username && len(notes) < limit {
+			notes = append(notes, n)
+		}
+	}
+	return notes, nil
+}
+
+func (m *memStore) LocalNote(_ context.Context, id string) (LocalNote, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notes[id]
+	if !ok {
+		return LocalNote{}, "", ErrNotFound
+	}
+	return n, m.noteAuthors[id], nil
+}
+
+func (m *memStore) RemoteActor(_ context.Context, id string) (RemoteActor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.remoteActors[id]
+	if !ok {
+		return RemoteActor{}, ErrNotFound
+	}
+	return a, nil
+}
+
+func (m *memStore) SaveRemoteActor(_ context.Context, actor RemoteActor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remoteActors[actor.ID] = actor
+	return nil
+}
+
+func (m *memStore) Followers(_ context.Context, username string) ([]RemoteActor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var followers []RemoteActor
+	for id := range m.followers[username] {
+		followers = append(followers, m.remoteActors[id])
+	}
+	return followers, nil
+}
+
+func (m *memStore) AddFollower(_ context.Context, username, followerID, followID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.followers[username] == nil {
+		m.followers[username] = map[string]string{}
+	}
+	m.followers[username][followerID] = followID
+	return nil
+}
+
+func (m *memStore) RemoveFollower(_ context.Context, username, followerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.followers[username], followerID)
+	return nil
+}
+
+func (m *memStore) AddFollowing(_ context.Context, username, actorID, followID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.following[followID] = memFollow{username: username, actorID: actorID}
+	return nil
+}
+
+func (m *memStore) AcceptFollowing(_ context.Context, followID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.following[followID]
+	if !ok || f.actorID != actorID {
+		return ErrNotFound
+	}
+	f.accepted = true
+	m.following[followID] = f
+	return nil
+}
+
+func (m *memStore) StoreNote(_ context.Context, note RemoteNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.following {
+		if f.actorID == note.AuthorID && f.accepted {
+			m.remote[note.ID] = note
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+func (m *memStore) DeleteNote(_ context.Context, id, authorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, ok := m.remote[id]; ok && n.AuthorID == authorID {
+		delete(m.remote, id)
+	}
+	return nil
+}
+
+func (m *memStore) AddLike(_ context.Context, noteID, actorID, likeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.notes[noteID]; !ok {
+		return ErrNotFound
+	}
+	if m.likes[noteID] == nil {
+		m.likes[noteID] = map[string]string{}
+	}
+	m.likes[noteID][actorID] = likeID
+	return nil
+}
+
+func (m *memStore) RemoveLike(_ context.Context, noteID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.likes[noteID], actorID)
+	return nil
+}
+
+// instance is a Chirpy server listening on localhost.
+type instance struct {
+	*Server
+	store *memStore
+	url   string
+}
+
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	store := newMemStore()
+	s, err := NewServer(srv.URL, store, NewClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("GET /.well-known/webfinger", s.HandleWebFinger)
+	mux.HandleFunc("GET /ap/users/{username}", s.HandleActor)
+	mux.HandleFunc("GET /ap/users/{username}/outbox", s.HandleOutbox)
+	mux.HandleFunc("GET /ap/users/{username}/followers", s.HandleFollowers)
+	mux.HandleFunc("GET /ap/notes/{id}", s.HandleNote)
+	mux.HandleFunc("POST /ap/users/{username}/inbox", s.HandleInbox)
+	mux.HandleFunc("POST /ap/inbox", s.HandleInbox)
+	return &instance{Server: s, store: store, url: srv.URL}
+}
+
+func TestFederation(t *testing.T) {
+	ctx := context.Background()
+	a, b := newInstance(t), newInstance(t)
+	a.store.addUser(t, "alice")
+	a.store.addUser(t, "carol")
+	b.store.addUser(t, "bob")
+	aliceID := a.ActorID("alice")
+	bobID := b.ActorID("bob")
+
+	// bob@b follows alice@a, found with WebFinger
+	actorID, err := b.client.Finger(ctx, "alice@"+a.Domain())
+	if err != nil || actorID != aliceID {
+		t.Fatalf("WebFinger: want %s, got %s (%v)", aliceID, actorID, err)
+	}
+	alice, err := b.Follow(ctx, "bob", "alice@"+a.Domain())
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if alice.ID != aliceID || alice.Username != "alice" {
+		t.Errorf("unexpected actor %+v", alice)
+	}
+	if _, ok := a.store.followers["alice"][bobID]; !ok {
+		t.Fatal("bob should follow alice on a")
+	}
+	for _, f := range b.store.following {
+		if !f.accepted {
+			t.Fatal("the follow should be accepted on b")
+		}
+	}
+
+	// alice publishes a note, delivered to b
+	published := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	note := LocalNote{ID: "note-1", Content: "Hello <fediverse> & friends", Published: published}
+	a.store.addNote("alice", note)
+	if err := a.PublishNote(ctx, "alice", note); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	received, ok := b.store.remote[a.NoteID("note-1")]
+	if !ok {
+		t.Fatal("the note should be stored on b")
+	}
+	if received.Content != note.Content || received.AuthorID != aliceID || !received.Published.Equal(published) {
+		t.Errorf("unexpected remote note %+v", received)
+	}
+
+	// and is listed in her outbox
+	var outbox OrderedCollection
+	if err := b.client.Get(ctx, aliceID+"/outbox", nil, &outbox); err != nil {
+		t.Fatal(err)
+	}
+	if outbox.TotalItems != 1 {
+		t.Errorf("want 1 note in the outbox, got %d", outbox.TotalItems)
+	}
+
+	// bob likes it
+	if err := b.Like(ctx, "bob", received); err != nil {
+		t.Fatalf("like: %v", err)
+	}
+	if _, ok := a.store.likes["note-1"][bobID]; !ok {
+		t.Error("the like of bob should be stored on a")
+	}
+
+	// notes of actors nobody follows are refused
+	carolNote := LocalNote{ID: "note-2", Content: "spam", Published: published}
+	create, err := a.createActivity("carol", carolNote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.deliver(ctx, "carol", create, []string{b.url + "/ap/inbox"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("want 403, got %v", err)
+	}
+
+	// and so are notes claiming an ID on another server
+	forged := a.noteDocument("alice", LocalNote{ID: "note-3", Content: "forged", Published: published})
+	forged.ID = b.NoteID("note-3")
+	create, err = NewActivity(TypeCreate, forged.ID+"/activity", forged.AttributedTo, forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.deliver(ctx, "alice", create, []string{b.url + "/ap/inbox"})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("want 403, got %v", err)
+	}
+	if _, ok := b.store.remote[forged.ID]; ok {
+		t.Error("the forged note should not be stored on b")
+	}
+
+	// alice deletes her note
+	if err := a.DeleteNote(ctx, "alice", "note-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := b.store.remote[a.NoteID("note-1")]; ok {
+		t.Error("the note should be deleted on b")
+	}
+}
+
+func TestInboxRejectsUnsigned(t *testing.T) {
+	a := newInstance(t)
+	a.store.addUser(t, "alice")
+	body := `{"id":"https://evil.example/1","type":"Follow","actor":"https://evil.example/users/eve","object":"` + a.ActorID("alice") + `"}`
+	resp, err := http.Post(a.url+"/ap/users/alice/inbox", ContentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want 401, got %d", resp.StatusCode)
+	}
+	if len(a.store.followers["alice"]) != 0 {
+		t.Error("unsigned follows should be ignored")
+	}
+}
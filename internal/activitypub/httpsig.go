@@ -0,0 +1,211 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures, as implemented by Mastodon: the draft-cavage-http-signatures
+// scheme with rsa-sha256, signing the request target, the Host and Date
+// headers and, for requests with a body, the Digest header.
+
+const (
+	keySize = 2048
+	// MaxClockSkew is how far the Date of a signed request can be from the
+	// server time.
+	MaxClockSkew = time.Hour
+)
+
+var ErrInvalidSignature = errors.New("invalid HTTP signature")
+
+// GenerateKey returns a new RSA key pair of an actor, PEM-encoded.
+func GenerateKey() (privatePEM, publicPEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return "", "", err
+	}
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}))
+	publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	return privatePEM, publicPEM, nil
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA private key, in PKCS #8 or
+// PKCS #1 form.
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey parses a PEM-encoded RSA public key, in PKIX or PKCS #1
+// form.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM public key")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Digest returns the Digest header of body.
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Sign signs r at now with the key of keyID. body is the body of r, nil for
+// GET requests.
+func Sign(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte, now time.Time) error {
+	headers := []string{"(request-target)", "host", "date"}
+	r.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+	if body != nil {
+		r.Header.Set("Digest", Digest(body))
+		headers = append(headers, "digest")
+	}
+	hashed := sha256.Sum256([]byte(signingString(r, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+// KeyID returns the ID of the key r claims to be signed with, which Verify
+// needs the public key of.
+func KeyID(r *http.Request) (string, error) {
+	params, err := signatureParams(r)
+	if err != nil {
+		return "", err
+	}
+	return params["keyId"], nil
+}
+
+// Verify checks that r was signed with key at most MaxClockSkew from now.
+// body is the body of r, nil for GET requests, checked against the Digest
+// header.
+func Verify(r *http.Request, key *rsa.PublicKey, body []byte, now time.Time) error {
+	params, err := signatureParams(r)
+	if err != nil {
+		return err
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return fmt.Errorf("%w: unsupported algorithm '%s'", ErrInvalidSignature, alg)
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		required = append(required, "digest")
+	}
+	for _, h := range required {
+		if !slices.Contains(headers, h) {
+			return fmt.Errorf("%w: %s isn't signed", ErrInvalidSignature, h)
+		}
+	}
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid Date header", ErrInvalidSignature)
+	}
+	if d := now.Sub(date); d > MaxClockSkew || d < -MaxClockSkew {
+		return fmt.Errorf("%w: Date too far from now", ErrInvalidSignature)
+	}
+	if body != nil && r.Header.Get("Digest") != Digest(body) {
+		return fmt.Errorf("%w: digest mismatch", ErrInvalidSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return fmt.Errorf("%w: invalid signature encoding", ErrInvalidSignature)
+	}
+	hashed := sha256.Sum256([]byte(signingString(r, headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signatureParams parses the Signature header of r.
+func signatureParams(r *http.Request) (map[string]string, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		return nil, fmt.Errorf("%w: missing Signature header", ErrInvalidSignature)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed Signature header", ErrInvalidSignature)
+		}
+		params[k] = strings.Trim(v, `"`)
+	}
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, fmt.Errorf("%w: malformed Signature header", ErrInvalidSignature)
+	}
+	return params, nil
+}
+
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		var v string
+		switch h {
+		case "(request-target)":
+			v = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			v = r.Host
+		default:
+			v = strings.Join(r.Header.Values(h), ", ")
+		}
+		lines[i] = h + ": " + v
+	}
+	return strings.Join(lines, "\n")
+}
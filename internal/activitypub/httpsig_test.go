@@ -0,0 +1,129 @@
+package activitypub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+)
+
+func TestSignVerify(t *testing.T) {
+	privPEM, pubPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParsePrivateKey(privPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParsePublicKey(pubPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPEM, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParsePublicKey(otherPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+	signed := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "https://chirpy.example/ap/inbox", strings.NewReader(string(body)))
+		if err := Sign(r, "https://remote.example/users/bob#main-key", key, body, now); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	cases := []struct {
+		name     string
+		request  func() *http.Request
+		wrongKey bool
+		body     []byte
+		now      time.Time
+		wantErr  bool
+	}{
+		{name: "valid", request: signed, body: body, now: now},
+		{name: "clock skew", request: signed, body: body, now: now.Add(30 * time.Minute)},
+		{name: "tampered body", request: signed, body: []byte(`{"type":"Delete"}`), now: now, wantErr: true},
+		{name: "other key", request: signed, wrongKey: true, body: body, now: now, wantErr: true},
+		{name: "too old", request: signed, body: body, now: now.Add(2 * time.Hour), wantErr: true},
+		{
+			name: "other target",
+			request: func() *http.Request {
+				r := signed()
+				r.URL.Path = "/ap/users/alice/inbox"
+				return r
+			},
+			body: body, now: now, wantErr: true,
+		},
+		{
+			name: "digest not signed",
+			request: func() *http.Request {
+				r := signed()
+				r.Header.Set("Signature", strings.Replace(r.Header.Get("Signature"), " digest", "", 1))
+				return r
+			},
+			body: body, now: now, wantErr: true,
+		},
+		{
+			name: "unsigned",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "https://chirpy.example/ap/inbox", nil)
+			},
+			body: body, now: now, wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := pub
+			if c.wrongKey {
+				k = other
+			}
+			err := Verify(c.request(), k, c.body, c.now)
+			if c.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("want ErrInvalidSignature, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	keyID, err := KeyID(signed())
+	if err != nil || keyID != "https://remote.example/users/bob#main-key" {
+		t.Errorf("unexpected key ID '%s': %v", keyID, err)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{content: "hello", want: "hello"},
+		{content: "<p>hello <a href=\"https://x.example\">@bob</a></p>", want: "hello @bob"},
+		{content: "<p>one<br>two</p><p>three</p>", want: "one\ntwo\n\nthree"},
+		{content: "<p>5 &lt; 6 &amp;&amp; true</p>", want: "5 < 6 && true"},
+		{content: "<p>broken <b", want: "broken"},
+	}
+	for _, c := range cases {
+		if got := PlainText(c.content); got != c.want {
+			t.Errorf("PlainText(%q): want %q, got %q", c.content, c.want, got)
+		}
+	}
+	text := "one\ntwo & <three>\n\nfour"
+	if got := PlainText(chirptext.HTML(text)); got != text {
+		t.Errorf("want %q back, got %q", text, got)
+	}
+}
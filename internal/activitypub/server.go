@@ -0,0 +1,645 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+	"github.com/google/uuid"
+)
+
+// outboxSize is how many notes are listed in outboxes.
+const outboxSize = 20
+
+// LocalActor is a local user.
+type LocalActor struct {
+	Username      string
+	Name          string
+	Summary       string
+	PublicKeyPEM  string
+	PrivateKeyPEM string
+}
+
+// LocalNote is a public chirp of a local user. Content is plain text.
+type LocalNote struct {
+	ID        string
+	Content   string
+	Published time.Time
+}
+
+// RemoteActor is an actor of another server.
+type RemoteActor struct {
+	ID           string
+	Username     string
+	Inbox        string
+	SharedInbox  string
+	PublicKeyPEM string
+}
+
+// RemoteNote is a note of a remote actor. Content is plain text.
+type RemoteNote struct {
+	ID        string
+	AuthorID  string
+	Content   string
+	URL       string
+	Published time.Time
+}
+
+// Store holds the local users and what was received from remote servers.
+// Local users are identified by their username, remote actors and objects
+// by their ID. Methods return ErrNotFound for unknown users and objects.
+type Store interface {
+	LocalActor(ctx context.Context, username string) (LocalActor, error)
+	// LocalNotes returns the latest public notes of a local user, newest
+	// first.
+	LocalNotes(ctx context.Context, username string, limit int) ([]LocalNote, error)
+	// LocalNote returns a public note and the username of its author.
+	LocalNote(ctx context.Context, id string) (LocalNote, string, error)
+
+	// RemoteActor returns a remote actor saved by SaveRemoteActor.
+	RemoteActor(ctx context.Context, id string) (RemoteActor, error)
+	SaveRemoteActor(ctx context.Context, actor RemoteActor) error
+
+	// Followers returns the remote followers of a local user.
+	Followers(ctx context.Context, username string) ([]RemoteActor, error)
+	AddFollower(ctx context.Context, username, followerID, followID string) error
+	RemoveFollower(ctx context.Context, username, followerID string) error
+	// AddFollowing records that a local user asked to follow a remote actor
+	// with the Follow activity followID, and AcceptFollowing that the actor
+	// accepted it.
+	AddFollowing(ctx context.Context, username, actorID, followID string) error
+	AcceptFollowing(ctx context.Context, followID, actorID string) error
+
+	// StoreNote stores a remote note, or returns ErrForbidden if it isn't
+	// wanted, like the notes of actors nobody follows. Storing a note twice
+	// is harmless.
+	StoreNote(ctx context.Context, note RemoteNote) error
+	DeleteNote(ctx context.Context, id, authorID string) error
+	// AddLike records that a remote actor liked a local note.
+	AddLike(ctx context.Context, noteID, actorID, likeID string) error
+	RemoveLike(ctx context.Context, noteID, actorID string) error
+}
+
+// Server implements ActivityPub for the local users of Store.
+type Server struct {
+	baseURL string
+	domain  string
+	store   Store
+	client  *Client
+}
+
+// NewServer returns a Server whose documents live under baseURL, the public
+// URL of the instance. Remote servers are reached with the scheme of
+// baseURL, which is https but in development.
+func NewServer(baseURL string, store Store, client *Client) (*Server, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+		return nil, fmt.Errorf("invalid base URL '%s'", baseURL)
+	}
+	client.Scheme = u.Scheme
+	return &Server{baseURL: u.String(), domain: u.Host, store: store, client: client}, nil
+}
+
+// Domain returns the domain of the accounts of the local users.
+func (s *Server) Domain() string {
+	return s.domain
+}
+
+// ActorID returns the ID of the actor of a local user.
+func (s *Server) ActorID(username string) string {
+	return s.baseURL + "/ap/users/" + url.PathEscape(username)
+}
+
+// NoteID returns the ID of a local note.
+func (s *Server) NoteID(id string) string {
+	return s.baseURL + "/ap/notes/" + url.PathEscape(id)
+}
+
+func (s *Server) keyID(username string) string {
+	return s.ActorID(username) + "#main-key"
+}
+
+// localUsername returns the username of a local actor ID, or "".
+func (s *Server) localUsername(id string) string {
+	username, ok := strings.CutPrefix(id, s.baseURL+"/ap/users/")
+	if !ok || strings.Contains(username, "/") {
+		return ""
+	}
+	username, err := url.PathUnescape(username)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+// localNoteID returns the local ID of a note ID, or "".
+func (s *Server) localNoteID(id string) string {
+	noteID, ok := strings.CutPrefix(id, s.baseURL+"/ap/notes/")
+	if !ok || strings.Contains(noteID, "/") {
+		return ""
+	}
+	return noteID
+}
+
+func (s *Server) actorDocument(a LocalActor) Actor {
+	id := s.ActorID(a.Username)
+	return Actor{
+		Context:           []string{ContextActivityStreams, ContextSecurity},
+		ID:                id,
+		Type:              TypePerson,
+		PreferredUsername: a.Username,
+		Name:              a.Name,
+		Summary:           a.Summary,
+		URL:               id,
+		Inbox:             id + "/inbox",
+		Outbox:            id + "/outbox",
+		Followers:         id + "/followers",
+		PublicKey: PublicKey{
+			ID:           s.keyID(a.Username),
+			Owner:        id,
+			PublicKeyPem: a.PublicKeyPEM,
+		},
+		Endpoints: &Endpoints{SharedInbox: s.baseURL + "/ap/inbox"},
+	}
+}
+
+func (s *Server) noteDocument(username string, n LocalNote) Note {
+	published := n.Published.UTC()
+	id := s.NoteID(n.ID)
+	return Note{
+		ID:           id,
+		Type:         TypeNote,
+		AttributedTo: s.ActorID(username),
+		Content:      chirptext.HTML(n.Content),
+		URL:          id,
+		Published:    &published,
+		To:           []string{Public},
+		Cc:           []string{s.ActorID(username) + "/followers"},
+	}
+}
+
+func (s *Server) createActivity(username string, n LocalNote) (Activity, error) {
+	note := s.noteDocument(username, n)
+	a, err := NewActivity(TypeCreate, note.ID+"/activity", note.AttributedTo, note)
+	a.To, a.Cc, a.Published = note.To, note.Cc, note.Published
+	return a, err
+}
+
+// HandleWebFinger serves the WebFinger endpoint,
+// /.well-known/webfinger?resource=acct:username@domain.
+func (s *Server) HandleWebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	var username string
+	if acct, ok := strings.CutPrefix(resource, "acct:"); ok {
+		user, domain, _ := strings.Cut(acct, "@")
+		if !strings.EqualFold(domain, s.domain) {
+			writeError(w, http.StatusNotFound, "unknown account")
+			return
+		}
+		username = user
+	} else {
+		username = s.localUsername(resource)
+	}
+	actor, err := s.store.LocalActor(r.Context(), username)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	id := s.ActorID(actor.Username)
+	w.Header().Set("Content-Type", "application/jrd+json")
+	json.NewEncoder(w).Encode(WebFinger{
+		Subject: "acct:" + actor.Username + "@" + s.domain,
+		Aliases: []string{id},
+		Links: []WebFingerLink{
+			{Rel: "self", Type: ContentType, Href: id},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: id},
+		},
+	})
+}
+
+// HandleActor serves the actor of the user in the "username" path value.
+func (s *Server) HandleActor(w http.ResponseWriter, r *http.Request) {
+	actor, err := s.store.LocalActor(r.Context(), r.PathValue("username"))
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	writeDocument(w, s.actorDocument(actor))
+}
+
+// HandleOutbox serves the latest notes of the user in the "username" path
+// value.
+func (s *Server) HandleOutbox(w http.ResponseWriter, r *http.Request) {
+	actor, err := s.store.LocalActor(r.Context(), r.PathValue("username"))
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	notes, err := s.store.LocalNotes(r.Context(), actor.Username, outboxSize)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	outbox := OrderedCollection{
+		Context:      ContextActivityStreams,
+		ID:           s.ActorID(actor.Username) + "/outbox",
+		Type:         TypeOrderedCollection,
+		TotalItems:   len(notes),
+		OrderedItems: []any{},
+	}
+	for _, n := range notes {
+		a, err := s.createActivity(actor.Username, n)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to build outbox")
+			return
+		}
+		outbox.OrderedItems = append(outbox.OrderedItems, a)
+	}
+	writeDocument(w, outbox)
+}
+
+// HandleFollowers serves the number of followers of the user in the
+// "username" path value. Who they are isn't disclosed.
+func (s *Server) HandleFollowers(w http.ResponseWriter, r *http.Request) {
+	actor, err := s.store.LocalActor(r.Context(), r.PathValue("username"))
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	followers, err := s.store.Followers(r.Context(), actor.Username)
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	writeDocument(w, OrderedCollection{
+		Context:    ContextActivityStreams,
+		ID:         s.ActorID(actor.Username) + "/followers",
+		Type:       TypeOrderedCollection,
+		TotalItems: len(followers),
+	})
+}
+
+// HandleNote serves the note in the "id" path value.
+func (s *Server) HandleNote(w http.ResponseWriter, r *http.Request) {
+	note, username, err := s.store.LocalNote(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	doc := s.noteDocument(username, note)
+	doc.Context = ContextActivityStreams
+	writeDocument(w, doc)
+}
+
+// HandleInbox receives the activities of remote actors, both in the inboxes
+// of users and in the shared inbox. Requests must be signed by the actor of
+// the activity.
+func (s *Server) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocumentSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	var activity Activity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Type == "" || activity.Actor == "" {
+		writeError(w, http.StatusBadRequest, "invalid activity")
+		return
+	}
+	actor, err := s.verify(r, body)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if actor.ID != activity.Actor {
+		writeError(w, http.StatusUnauthorized, "activity isn't signed by its actor")
+		return
+	}
+	if err := s.receive(r.Context(), actor, activity); err != nil {
+		s.writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify returns the remote actor who signed r.
+func (s *Server) verify(r *http.Request, body []byte) (RemoteActor, error) {
+	keyID, err := KeyID(r)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	actorID, _, _ := strings.Cut(keyID, "#")
+	actor, err := s.remoteActor(r.Context(), actorID, false)
+	if err != nil {
+		return RemoteActor{}, fmt.Errorf("unable to get the key of '%s': %w", actorID, err)
+	}
+	err = verifyWith(r, actor, body)
+	if errors.Is(err, ErrInvalidSignature) {
+		// The actor may have rotated its key since it was saved
+		actor, err = s.remoteActor(r.Context(), actorID, true)
+		if err != nil {
+			return RemoteActor{}, fmt.Errorf("unable to get the key of '%s': %w", actorID, err)
+		}
+		err = verifyWith(r, actor, body)
+	}
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	return actor, nil
+}
+
+func verifyWith(r *http.Request, actor RemoteActor, body []byte) error {
+	key, err := ParsePublicKey(actor.PublicKeyPEM)
+	if err != nil {
+		return err
+	}
+	return Verify(r, key, body, time.Now())
+}
+
+// remoteActor returns the remote actor id, fetching it if it wasn't saved
+// yet or refresh is set.
+func (s *Server) remoteActor(ctx context.Context, id string, refresh bool) (RemoteActor, error) {
+	if !refresh {
+		actor, err := s.store.RemoteActor(ctx, id)
+		if !errors.Is(err, ErrNotFound) {
+			return actor, err
+		}
+	}
+	doc, err := s.client.FetchActor(ctx, id, nil)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	actor := RemoteActor{
+		ID:           doc.ID,
+		Username:     doc.PreferredUsername,
+		Inbox:        doc.Inbox,
+		PublicKeyPEM: doc.PublicKey.PublicKeyPem,
+	}
+	if doc.Endpoints != nil {
+		actor.SharedInbox = doc.Endpoints.SharedInbox
+	}
+	if err := s.store.SaveRemoteActor(ctx, actor); err != nil {
+		return RemoteActor{}, err
+	}
+	return actor, nil
+}
+
+// receive applies an activity of actor. Unsupported activities are ignored.
+func (s *Server) receive(ctx context.Context, actor RemoteActor, a Activity) error {
+	switch a.Type {
+	case TypeFollow:
+		username := s.localUsername(a.ObjectID())
+		if _, err := s.store.LocalActor(ctx, username); err != nil {
+			return err
+		}
+		if err := s.store.AddFollower(ctx, username, actor.ID, a.ID); err != nil {
+			return err
+		}
+		accept, err := NewActivity(TypeAccept, s.ActorID(username)+"#accepts/"+uuid.NewString(), s.ActorID(username), a)
+		if err != nil {
+			return err
+		}
+		return s.deliver(ctx, username, accept, []string{actor.Inbox})
+	case TypeAccept:
+		return s.store.AcceptFollowing(ctx, a.ObjectID(), actor.ID)
+	case TypeCreate:
+		if a.ObjectType() != TypeNote {
+			return nil
+		}
+		var note Note
+		if err := a.Embedded(&note); err != nil {
+			return err
+		}
+		if note.AttributedTo != actor.ID {
+			return fmt.Errorf("%w: note not attributed to its sender", ErrForbidden)
+		}
+		if !sameHost(note.ID, actor.ID) {
+			return fmt.Errorf("%w: note not hosted by the server of its sender", ErrForbidden)
+		}
+		published := time.Now().UTC()
+		if note.Published != nil {
+			published = note.Published.UTC()
+		}
+		return s.store.StoreNote(ctx, RemoteNote{
+			ID:        note.ID,
+			AuthorID:  actor.ID,
+			Content:   PlainText(note.Content),
+			URL:       note.URL,
+			Published: published,
+		})
+	case TypeDelete:
+		id := a.ObjectID()
+		if id == actor.ID {
+			// Deleted accounts are left as they are
+			return nil
+		}
+		return s.store.DeleteNote(ctx, id, actor.ID)
+	case TypeLike:
+		noteID := s.localNoteID(a.ObjectID())
+		if noteID == "" {
+			return nil
+		}
+		return s.store.AddLike(ctx, noteID, actor.ID, a.ID)
+	case TypeUndo:
+		var undone Activity
+		if err := a.Embedded(&undone); err != nil || undone.Actor != actor.ID {
+			return nil
+		}
+		switch undone.Type {
+		case TypeFollow:
+			return s.store.RemoveFollower(ctx, s.localUsername(undone.ObjectID()), actor.ID)
+		case TypeLike:
+			if noteID := s.localNoteID(undone.ObjectID()); noteID != "" {
+				return s.store.RemoveLike(ctx, noteID, actor.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// sameHost reports whether two URLs are on the same host, so that actors
+// can't create or overwrite the notes of other servers.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+// deliver sends an activity of a local user to inboxes, and returns the
+// errors of the deliveries that failed.
+func (s *Server) deliver(ctx context.Context, username string, a Activity, inboxes []string) error {
+	local, err := s.store.LocalActor(ctx, username)
+	if err != nil {
+		return err
+	}
+	key, err := ParsePrivateKey(local.PrivateKeyPEM)
+	if err != nil {
+		return err
+	}
+	signer := Signer{KeyID: s.keyID(username), Key: key}
+	var errs []error
+	for _, inbox := range inboxes {
+		if err := s.client.Post(ctx, inbox, signer, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// followerInboxes returns the inboxes to deliver the activities of a local
+// user to, once per server when they have a shared inbox.
+func (s *Server) followerInboxes(ctx context.Context, username string) ([]string, error) {
+	followers, err := s.store.Followers(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var inboxes []string
+	for _, f := range followers {
+		inbox := f.Inbox
+		if f.SharedInbox != "" {
+			inbox = f.SharedInbox
+		}
+		if !seen[inbox] {
+			seen[inbox] = true
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes, nil
+}
+
+// PublishNote delivers a new note of a local user to their followers.
+func (s *Server) PublishNote(ctx context.Context, username string, note LocalNote) error {
+	inboxes, err := s.followerInboxes(ctx, username)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	a, err := s.createActivity(username, note)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, username, a, inboxes)
+}
+
+// DeleteNote tells the followers of a local user that a note of theirs was
+// deleted.
+func (s *Server) DeleteNote(ctx context.Context, username, id string) error {
+	inboxes, err := s.followerInboxes(ctx, username)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	noteID := s.NoteID(id)
+	a, err := NewActivity(TypeDelete, noteID+"#delete", s.ActorID(username), Note{ID: noteID, Type: TypeTombstone})
+	if err != nil {
+		return err
+	}
+	a.To = []string{Public}
+	return s.deliver(ctx, username, a, inboxes)
+}
+
+// Follow asks a remote account, given as user@domain or as the ID of its
+// actor, to accept a local user as follower. The follow is pending until
+// the remote server sends an Accept.
+func (s *Server) Follow(ctx context.Context, username, account string) (RemoteActor, error) {
+	actorID := account
+	if !strings.HasPrefix(account, "https://") && !strings.HasPrefix(account, "http://") {
+		id, err := s.client.Finger(ctx, account)
+		if err != nil {
+			return RemoteActor{}, err
+		}
+		actorID = id
+	}
+	if s.localUsername(actorID) != "" {
+		return RemoteActor{}, fmt.Errorf("%w: '%s' is a local account", ErrForbidden, account)
+	}
+	actor, err := s.remoteActor(ctx, actorID, true)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	followID := s.ActorID(username) + "#follows/" + uuid.NewString()
+	if err := s.store.AddFollowing(ctx, username, actor.ID, followID); err != nil {
+		return RemoteActor{}, err
+	}
+	a, err := NewActivity(TypeFollow, followID, s.ActorID(username), actor.ID)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	return actor, s.deliver(ctx, username, a, []string{actor.Inbox})
+}
+
+// Like tells the author of a remote note that a local user liked it.
+func (s *Server) Like(ctx context.Context, username string, note RemoteNote) error {
+	author, err := s.remoteActor(ctx, note.AuthorID, false)
+	if err != nil {
+		return err
+	}
+	a, err := NewActivity(TypeLike, s.ActorID(username)+"#likes/"+uuid.NewString(), s.ActorID(username), note.ID)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, username, a, []string{author.Inbox})
+}
+
+func (s *Server) writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, ErrForbidden):
+		writeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "unable to process request")
+	}
+}
+
+func writeDocument(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", ContentType)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// PlainText returns the text of the HTML content of a note: tags are
+// dropped, and line breaks and paragraphs become newlines.
+func PlainText(content string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(content, '<')
+		if start < 0 {
+			b.WriteString(content)
+			break
+		}
+		b.WriteString(content[:start])
+		end := strings.IndexByte(content[start:], '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.ToLower(strings.Trim(content[start+1:start+end], "/ "))
+		name, _, _ := strings.Cut(tag, " ")
+		switch {
+		case name == "br":
+			b.WriteString("\n")
+		case name == "p" && content[start+1] == '/':
+			b.WriteString("\n\n")
+		}
+		content = content[start+end+1:]
+	}
+	return strings.TrimSpace(html.UnescapeString(b.String()))
+}
@@ -107,6 +107,24 @@ func TestJWT(t *testing.T) {
 	}
 }
 
+func TestJWTExpiry(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeJWT(userID, "mytokensecret", time.Hour)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+	gotID, expiresAt, err := ValidateJWTExpiry(token, "mytokensecret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotID != userID {
+		t.Errorf("want ID %v, got %v", userID, gotID)
+	}
+	if d := time.Until(expiresAt); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("want expiry in an hour, got %v", expiresAt)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	cases := []struct {
 		name      string
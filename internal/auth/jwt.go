@@ -33,32 +33,48 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	id, _, err := ValidateJWTExpiry(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTExpiry is like ValidateJWT, and also returns when the token
+// expires, for long-lived connections to check it again. The time is zero
+// for tokens that never expire.
+func ValidateJWTExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
 	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
 	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
 	if err != nil {
 		log.Printf("unable to parse the JWT token string: %v", err)
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
 		log.Printf("unable to parse issuer from JWT: %v", err)
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 	if issuer != tokenIssuer {
-		return uuid.Nil, errors.New("invalid JWT issuer")
+		return uuid.Nil, time.Time{}, errors.New("invalid JWT issuer")
 	}
 	subject, err := token.Claims.GetSubject()
 	if err != nil {
 		log.Printf("unable to parse claims from JWT: %v", err)
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 	id, err := uuid.Parse(subject)
 	if err != nil {
 		log.Printf("unable to parse uuid from JWT subject: %v", err)
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
+	}
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil {
+		log.Printf("unable to parse expiration time from JWT: %v", err)
+		return uuid.Nil, time.Time{}, err
+	}
+	if expiresAt == nil {
+		return id, time.Time{}, nil
 	}
-	return id, nil
+	return id, expiresAt.Time, nil
 }
 
 func MakeRefreshToken() (string, error) {
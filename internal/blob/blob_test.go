@@ -0,0 +1,80 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "/media/")
+	if err != nil {
+		t.Fatalf("unable to create store: %v", err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "a/b.txt", "text/plain", strings.NewReader("hello"), 5); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "a", "b.txt"))
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("want blob content 'hello', got '%s' (err: %v)", got, err)
+	}
+	if url := store.URL("a/b.txt"); url != "/media/a/b.txt" {
+		t.Errorf("want URL '/media/a/b.txt', got '%s'", url)
+	}
+	if err := store.Put(ctx, "../escape", "text/plain", strings.NewReader(""), 0); err == nil {
+		t.Errorf("want error for key escaping the directory")
+	}
+	if err := store.Delete(ctx, "a/b.txt"); err != nil {
+		t.Fatalf("unable to delete blob: %v", err)
+	}
+	if err := store.Delete(ctx, "a/b.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestS3StorePut(t *testing.T) {
+	var gotPath, gotAuth, gotBody, gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		gotType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	store := &S3Store{
+		Endpoint:        srv.URL,
+		Bucket:          "chirpy",
+		Region:          "us-east-1",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		now:             func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	if err := store.Put(context.Background(), "img/x.png", "image/png", strings.NewReader("data"), 4); err != nil {
+		t.Fatalf("unable to put blob: %v", err)
+	}
+	if gotPath != "/chirpy/img/x.png" {
+		t.Errorf("want path '/chirpy/img/x.png', got '%s'", gotPath)
+	}
+	if gotBody != "data" || gotType != "image/png" {
+		t.Errorf("unexpected body '%s' or content type '%s'", gotBody, gotType)
+	}
+	wantPrefix := "AWS4-HMAC-SHA256 Credential=AKID/20250102/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(gotAuth, wantPrefix) {
+		t.Errorf("unexpected Authorization header '%s'", gotAuth)
+	}
+	if url := store.URL("img/x.png"); url != srv.URL+"/chirpy/img/x.png" {
+		t.Errorf("unexpected URL '%s'", url)
+	}
+}
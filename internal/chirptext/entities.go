@@ -0,0 +1,163 @@
+package chirptext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type EntityType string
+
+const (
+	EntityHashtag EntityType = "hashtag"
+	EntityMention EntityType = "mention"
+)
+
+const (
+	maxHashtagLen = 100
+	maxMentionLen = 320
+)
+
+// Entity is a hashtag or mention found in a chirp body. Offsets are half-open
+// ranges covering the sigil, given both in bytes and in runes so that clients
+// can slice the body in whatever unit their string type uses.
+type Entity struct {
+	Type      EntityType `json:"type"`
+	Text      string     `json:"text"`
+	Start     int        `json:"start"`
+	End       int        `json:"end"`
+	RuneStart int        `json:"rune_start"`
+	RuneEnd   int        `json:"rune_end"`
+}
+
+// Extract returns the hashtags and mentions of body, in order of appearance.
+func Extract(body string) []Entity {
+	var entities []Entity
+	runeIdx := 0
+	prev := rune(-1)
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		if (r == '#' || r == '@') && !isWordRune(prev) && prev != '@' && prev != '#' {
+			var end int
+			if r == '#' {
+				end = scanHashtag(body, i+size)
+			} else {
+				end = scanMention(body, i+size)
+			}
+			if end > i+size {
+				text := body[i+size : end]
+				n := utf8.RuneCountInString(body[i:end])
+				entities = append(entities, Entity{
+					Type:      entityType(r),
+					Text:      text,
+					Start:     i,
+					End:       end,
+					RuneStart: runeIdx,
+					RuneEnd:   runeIdx + n,
+				})
+				runeIdx += n
+				prev, _ = utf8.DecodeLastRuneInString(body[:end])
+				i = end
+				continue
+			}
+		}
+		prev = r
+		runeIdx++
+		i += size
+	}
+	return entities
+}
+
+// Hashtags returns the distinct normalized tags of body.
+func Hashtags(body string) []string {
+	return distinct(body, EntityHashtag, NormalizeTag)
+}
+
+// Mentions returns the distinct mentioned identifiers of body, as written.
+func Mentions(body string) []string {
+	return distinct(body, EntityMention, func(s string) string { return s })
+}
+
+// NormalizeTag returns the canonical form of a tag, as stored and queried.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func distinct(body string, typ EntityType, normalize func(string) string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, e := range Extract(body) {
+		if e.Type != typ {
+			continue
+		}
+		v := normalize(e.Text)
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func entityType(sigil rune) EntityType {
+	if sigil == '#' {
+		return EntityHashtag
+	}
+	return EntityMention
+}
+
+// scanHashtag returns the end of a hashtag body starting at i. A tag made only
+// of digits (e.g. "#1") is not a hashtag.
+func scanHashtag(s string, i int) int {
+	start, hasLetter := i, false
+	for i < len(s) && i-start < maxHashtagLen {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isWordRune(r) {
+			break
+		}
+		if !unicode.IsDigit(r) {
+			hasLetter = true
+		}
+		i += size
+	}
+	if !hasLetter {
+		return start
+	}
+	return i
+}
+
+// scanMention returns the end of a mention body starting at i, either a local
+// identifier or one qualified with a domain ("name@example.com").
+func scanMention(s string, i int) int {
+	start := i
+	for i < len(s) && i-start < maxMentionLen && isMentionByte(s[i]) {
+		i++
+	}
+	if i == start {
+		return start
+	}
+	if i+1 < len(s) && s[i] == '@' && isDomainByte(s[i+1]) {
+		j := i + 1
+		for j < len(s) && j-start < maxMentionLen && isDomainByte(s[j]) {
+			j++
+		}
+		i = j
+	}
+	// Trailing punctuation belongs to the sentence, not the mention.
+	return start + len(strings.TrimRight(s[start:i], ".-"))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isMentionByte(b byte) bool {
+	return b == '_' || b == '.' || b == '+' || b == '-' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+func isDomainByte(b byte) bool {
+	return b == '.' || b == '-' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
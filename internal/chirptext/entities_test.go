@@ -0,0 +1,64 @@
+package chirptext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "no entities",
+			body: "just a plain chirp",
+			want: nil,
+		},
+		{
+			name: "hashtag and mention",
+			body: "hi @alice, see #golang!",
+			want: []Entity{
+				{Type: EntityMention, Text: "alice", Start: 3, End: 9, RuneStart: 3, RuneEnd: 9},
+				{Type: EntityHashtag, Text: "golang", Start: 15, End: 22, RuneStart: 15, RuneEnd: 22},
+			},
+		},
+		{
+			name: "multibyte offsets",
+			body: "café #crème",
+			want: []Entity{
+				{Type: EntityHashtag, Text: "crème", Start: 6, End: 13, RuneStart: 5, RuneEnd: 11},
+			},
+		},
+		{
+			name: "qualified mention with trailing dot",
+			body: "ping @bob@example.com.",
+			want: []Entity{
+				{Type: EntityMention, Text: "bob@example.com", Start: 5, End: 21, RuneStart: 5, RuneEnd: 21},
+			},
+		},
+		{
+			name: "email and numeric tag are ignored",
+			body: "mail me at joe@example.com about #1",
+			want: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Extract(c.body)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags("#Go and #go and #Rust")
+	want := []string{"go", "rust"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
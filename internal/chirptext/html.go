@@ -0,0 +1,18 @@
+package chirptext
+
+import (
+	"html"
+	"strings"
+)
+
+// HTML returns the HTML content of a text, for feeds and federated notes: it
+// is escaped, and its line breaks and paragraphs are kept.
+func HTML(text string) string {
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, "<p>"+strings.ReplaceAll(html.EscapeString(p), "\n", "<br>")+"</p>")
+		}
+	}
+	return strings.Join(paragraphs, "")
+}
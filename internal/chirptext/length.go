@@ -0,0 +1,133 @@
+package chirptext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// urlPattern matches the links that count for a fixed weight, whatever their
+// actual length, so that long URLs don't eat the whole chirp.
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// Normalize returns the NFC form of s, so that visually identical strings are
+// stored and counted the same way. Windows line breaks become newlines.
+func Normalize(s string) string {
+	return norm.NFC.String(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// Length returns the length of s as seen by a reader: the number of grapheme
+// clusters, with every URL counted as urlWeight. s should be normalized first.
+func Length(s string, urlWeight int) int {
+	n := 0
+	last := 0
+	for _, loc := range urlSpans(s) {
+		n += uniseg.GraphemeClusterCount(s[last:loc[0]]) + urlWeight
+		last = loc[1]
+	}
+	return n + uniseg.GraphemeClusterCount(s[last:])
+}
+
+// urlSpans returns the byte ranges of the URLs of s. Trailing punctuation is
+// left out, as in "see https://example.com.".
+func urlSpans(s string) [][]int {
+	locs := urlPattern.FindAllStringIndex(s, -1)
+	for _, loc := range locs {
+		loc[1] = loc[0] + len(strings.TrimRight(s[loc[0]:loc[1]], ".,:;!?)]}'\""))
+	}
+	return locs
+}
+
+const (
+	// MaxBytesPerChar bounds the size of a text of n characters to
+	// n*MaxBytesPerChar bytes, however its characters are made up.
+	MaxBytesPerChar = 32
+	// MaxClusterRunes is the most code points a character can have. The
+	// longest emoji sequences have about 10, while stacks of combining marks
+	// would otherwise make a single character of any size.
+	MaxClusterRunes = 16
+)
+
+const (
+	blackFlag = 0x1F3F4
+	cancelTag = 0xE007F
+)
+
+// CheckCharacters rejects control characters (other than newlines and tabs),
+// characters that render as nothing, which are used to pad chirps or hide
+// content from filters, and characters made of more than MaxClusterRunes
+// code points.
+func CheckCharacters(s string) error {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			return fmt.Errorf("invalid UTF-8 at byte %d", i-size)
+		case r == blackFlag:
+			// Tag characters are only allowed to spell the subdivision
+			// flags, like Scotland's: a black flag, tags and a cancel tag.
+			i += flagTagsLen(s[i:])
+		case r == '\n' || r == '\t':
+		case unicode.IsControl(r):
+			return fmt.Errorf("control character %U is not allowed", r)
+		case isInvisible(r):
+			return fmt.Errorf("invisible character %U is not allowed", r)
+		}
+	}
+	state := -1
+	for rest := s; rest != ""; {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if utf8.RuneCountInString(cluster) > MaxClusterRunes {
+			return fmt.Errorf("characters can have at most %d code points", MaxClusterRunes)
+		}
+	}
+	return nil
+}
+
+// flagTagsLen returns the length of the tags of a subdivision flag at the
+// start of s, cancel tag included, or 0 if there is no complete one.
+func flagTagsLen(s string) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !isTag(r) {
+			return 0
+		}
+		n += size
+		if r == cancelTag {
+			if n == size {
+				return 0
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func isTag(r rune) bool {
+	return 0xE0020 <= r && r <= cancelTag
+}
+
+func isInvisible(r rune) bool {
+	switch {
+	case r == 0x200C || r == 0x200D:
+		// Zero-width (non-)joiners are needed by emoji sequences and some scripts.
+		return false
+	case r == 0x200E || r == 0x200F:
+		// Left-to-right and right-to-left marks order mixed-direction text.
+		return false
+	case unicode.Is(unicode.Cf, r), unicode.Is(unicode.Zl, r), unicode.Is(unicode.Zp, r):
+		return true
+	case r == 0x115F, r == 0x1160, r == 0x3164, r == 0xFFA0:
+		// Hangul fillers are letters but display as blank space.
+		return true
+	}
+	return false
+}
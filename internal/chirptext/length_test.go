@@ -0,0 +1,91 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "ascii", text: "hello", want: 5},
+		{name: "accents after NFC", text: Normalize("café"), want: 4},
+		{name: "emoji with modifiers", text: "👍🏽 ok", want: 4},
+		{name: "family emoji", text: "👨\u200d👩\u200d👧", want: 1},
+		{name: "url has a fixed weight", text: "see https://example.com/a/very/long/path.", want: 4 + 23 + 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Length(c.text, 23); got != c.want {
+				t.Errorf("want length %d, got %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestLengthCountsVisibleCharacters(t *testing.T) {
+	// 60 accented characters are 120 bytes but fit in a chirp.
+	text := strings.Repeat("é", 60)
+	if got := Length(text, 23); got != 60 {
+		t.Errorf("want length 60, got %d", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "nfc", text: "e\u0301", want: "\u00e9"},
+		{name: "windows line breaks", text: "hello\r\nworld\r\n", want: "hello\nworld\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Normalize(c.text)
+			if got != c.want {
+				t.Errorf("want %q, got %q", c.want, got)
+			}
+			if err := CheckCharacters(got); err != nil {
+				t.Errorf("normalized text should be allowed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckCharacters(t *testing.T) {
+	cases := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "plain text", text: "hello\nworld\t!", wantErr: false},
+		{name: "zwj emoji", text: "👨\u200d👩\u200d👧", wantErr: false},
+		{name: "control character", text: "bell\a", wantErr: true},
+		{name: "direction marks", text: "שלום\u200e world\u200f", wantErr: false},
+		{name: "carriage return", text: "hello\rworld", wantErr: true},
+		{name: "zero width space", text: "hid\u200bden", wantErr: true},
+		{name: "bidi override", text: "\u202eevil", wantErr: true},
+		{name: "hangul filler", text: "\u3164", wantErr: true},
+		{name: "invalid utf-8", text: "bad\xff", wantErr: true},
+		{name: "scotland flag", text: "go \U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F!", wantErr: false},
+		{name: "black flag", text: "\U0001F3F4 pirates", wantErr: false},
+		{name: "tags outside a flag", text: "hi\U000E0068\U000E0069\U000E007F", wantErr: true},
+		{name: "unterminated flag", text: "\U0001F3F4\U000E0067\U000E0062 hidden", wantErr: true},
+		{name: "kiss with skin tones", text: "👩🏻\u200d❤️\u200d💋\u200d👨🏼", wantErr: false},
+		{name: "stacked combining marks", text: "a" + strings.Repeat("\u0301", MaxClusterRunes), wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckCharacters(c.text)
+			if (err != nil) != c.wantErr {
+				t.Errorf("want err: %v, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
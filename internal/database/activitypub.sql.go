@@ -0,0 +1,467 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: activitypub.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const acceptRemoteFollow = `-- name: AcceptRemoteFollow :execrows
+
+UPDATE remote_follows
+SET accepted_at = NOW() AT TIME ZONE 'utc'
+FROM remote_actors
+WHERE remote_follows.remote_actor_id = remote_actors.id
+AND remote_follows.follow_uri = $1 AND remote_actors.uri = $2
+`
+
+type AcceptRemoteFollowParams struct {
+	FollowUri string
+	ActorUri  string
+}
+
+func (q *Queries) AcceptRemoteFollow(ctx context.Context, arg AcceptRemoteFollowParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptRemoteFollow, arg.FollowUri, arg.ActorUri)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const addRemoteFollow = `-- name: AddRemoteFollow :exec
+
+INSERT INTO remote_follows (user_id, remote_actor_id, follow_uri, created_at)
+SELECT $1, remote_actors.id, $2, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = $3
+ON CONFLICT (user_id, remote_actor_id) DO UPDATE SET
+    follow_uri = EXCLUDED.follow_uri,
+    created_at = EXCLUDED.created_at,
+    accepted_at = NULL
+`
+
+type AddRemoteFollowParams struct {
+	UserID    uuid.UUID
+	FollowUri string
+	ActorUri  string
+}
+
+// AddRemoteFollow records a new follow request, which replaces any previous
+// one.
+func (q *Queries) AddRemoteFollow(ctx context.Context, arg AddRemoteFollowParams) error {
+	_, err := q.db.ExecContext(ctx, addRemoteFollow, arg.UserID, arg.FollowUri, arg.ActorUri)
+	return err
+}
+
+const addRemoteFollower = `-- name: AddRemoteFollower :exec
+
+INSERT INTO remote_followers (user_id, remote_actor_id, follow_uri, created_at)
+SELECT $1, remote_actors.id, $2, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = $3
+ON CONFLICT (user_id, remote_actor_id) DO UPDATE SET follow_uri = EXCLUDED.follow_uri
+`
+
+type AddRemoteFollowerParams struct {
+	UserID    uuid.UUID
+	FollowUri string
+	ActorUri  string
+}
+
+func (q *Queries) AddRemoteFollower(ctx context.Context, arg AddRemoteFollowerParams) error {
+	_, err := q.db.ExecContext(ctx, addRemoteFollower, arg.UserID, arg.FollowUri, arg.ActorUri)
+	return err
+}
+
+const addRemoteLike = `-- name: AddRemoteLike :exec
+
+INSERT INTO remote_likes (chirp_id, remote_actor_id, like_uri, created_at)
+SELECT $1, remote_actors.id, $2, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = $3
+ON CONFLICT (chirp_id, remote_actor_id) DO NOTHING
+`
+
+type AddRemoteLikeParams struct {
+	ChirpID  uuid.UUID
+	LikeUri  string
+	ActorUri string
+}
+
+func (q *Queries) AddRemoteLike(ctx context.Context, arg AddRemoteLikeParams) error {
+	_, err := q.db.ExecContext(ctx, addRemoteLike, arg.ChirpID, arg.LikeUri, arg.ActorUri)
+	return err
+}
+
+const createActorKey = `-- name: CreateActorKey :exec
+
+INSERT INTO actor_keys (user_id, created_at, public_key, private_key)
+VALUES ($1, NOW() AT TIME ZONE 'utc', $2, $3)
+ON CONFLICT (user_id) DO NOTHING
+`
+
+type CreateActorKeyParams struct {
+	UserID     uuid.UUID
+	PublicKey  string
+	PrivateKey string
+}
+
+func (q *Queries) CreateActorKey(ctx context.Context, arg CreateActorKeyParams) error {
+	_, err := q.db.ExecContext(ctx, createActorKey, arg.UserID, arg.PublicKey, arg.PrivateKey)
+	return err
+}
+
+const createRemoteChirp = `-- name: CreateRemoteChirp :exec
+
+INSERT INTO remote_chirps (id, uri, remote_actor_id, body, url, published_at, created_at)
+SELECT gen_random_uuid(), $1, remote_actors.id, $2, $3, $4, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = $5
+ON CONFLICT (uri) DO NOTHING
+`
+
+type CreateRemoteChirpParams struct {
+	Uri         string
+	Body        string
+	Url         string
+	PublishedAt time.Time
+	ActorUri    string
+}
+
+func (q *Queries) CreateRemoteChirp(ctx context.Context, arg CreateRemoteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, createRemoteChirp,
+		arg.Uri,
+		arg.Body,
+		arg.Url,
+		arg.PublishedAt,
+		arg.ActorUri,
+	)
+	return err
+}
+
+const deleteRemoteChirp = `-- name: DeleteRemoteChirp :exec
+
+DELETE FROM remote_chirps
+USING remote_actors
+WHERE remote_chirps.remote_actor_id = remote_actors.id
+AND remote_chirps.uri = $1 AND remote_actors.uri = $2
+`
+
+type DeleteRemoteChirpParams struct {
+	Uri      string
+	ActorUri string
+}
+
+func (q *Queries) DeleteRemoteChirp(ctx context.Context, arg DeleteRemoteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteRemoteChirp, arg.Uri, arg.ActorUri)
+	return err
+}
+
+const getActorKey = `-- name: GetActorKey :one
+SELECT user_id, created_at, public_key, private_key FROM actor_keys
+WHERE user_id = $1
+`
+
+func (q *Queries) GetActorKey(ctx context.Context, userID uuid.UUID) (ActorKey, error) {
+	row := q.db.QueryRowContext(ctx, getActorKey, userID)
+	var i ActorKey
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.PublicKey,
+		&i.PrivateKey,
+	)
+	return i, err
+}
+
+const getFederatedChirpsByUserID = `-- name: GetFederatedChirpsByUserID :many
+
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator FROM chirps
+WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetFederatedChirpsByUserIDParams struct {
+	UserID    uuid.UUID
+	MaxChirps int32
+}
+
+// GetFederatedChirpsByUserID returns the latest public chirps of a user,
+// newest first.
+func (q *Queries) GetFederatedChirpsByUserID(ctx context.Context, arg GetFederatedChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getFederatedChirpsByUserID, arg.UserID, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRemoteActorByURI = `-- name: GetRemoteActorByURI :one
+
+SELECT id, uri, username, inbox, shared_inbox, public_key, fetched_at FROM remote_actors
+WHERE uri = $1
+`
+
+func (q *Queries) GetRemoteActorByURI(ctx context.Context, uri string) (RemoteActor, error) {
+	row := q.db.QueryRowContext(ctx, getRemoteActorByURI, uri)
+	var i RemoteActor
+	err := row.Scan(
+		&i.ID,
+		&i.Uri,
+		&i.Username,
+		&i.Inbox,
+		&i.SharedInbox,
+		&i.PublicKey,
+		&i.FetchedAt,
+	)
+	return i, err
+}
+
+const getRemoteChirpByID = `-- name: GetRemoteChirpByID :one
+
+SELECT remote_chirps.id, remote_chirps.uri, remote_chirps.remote_actor_id, remote_chirps.body, remote_chirps.url, remote_chirps.published_at, remote_chirps.created_at, remote_actors.uri AS actor_uri
+FROM remote_chirps
+JOIN remote_actors ON remote_actors.id = remote_chirps.remote_actor_id
+WHERE remote_chirps.id = $1
+`
+
+type GetRemoteChirpByIDRow struct {
+	ID            uuid.UUID
+	Uri           string
+	RemoteActorID uuid.UUID
+	Body          string
+	Url           string
+	PublishedAt   time.Time
+	CreatedAt     time.Time
+	ActorUri      string
+}
+
+func (q *Queries) GetRemoteChirpByID(ctx context.Context, id uuid.UUID) (GetRemoteChirpByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getRemoteChirpByID, id)
+	var i GetRemoteChirpByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Uri,
+		&i.RemoteActorID,
+		&i.Body,
+		&i.Url,
+		&i.PublishedAt,
+		&i.CreatedAt,
+		&i.ActorUri,
+	)
+	return i, err
+}
+
+const getRemoteFollowers = `-- name: GetRemoteFollowers :many
+
+SELECT remote_actors.id, remote_actors.uri, remote_actors.username, remote_actors.inbox, remote_actors.shared_inbox, remote_actors.public_key, remote_actors.fetched_at FROM remote_actors
+JOIN remote_followers ON remote_followers.remote_actor_id = remote_actors.id
+WHERE remote_followers.user_id = $1
+`
+
+func (q *Queries) GetRemoteFollowers(ctx context.Context, userID uuid.UUID) ([]RemoteActor, error) {
+	rows, err := q.db.QueryContext(ctx, getRemoteFollowers, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RemoteActor
+	for rows.Next() {
+		var i RemoteActor
+		if err := rows.Scan(
+			&i.ID,
+			&i.Uri,
+			&i.Username,
+			&i.Inbox,
+			&i.SharedInbox,
+			&i.PublicKey,
+			&i.FetchedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRemoteTimeline = `-- name: GetRemoteTimeline :many
+
+SELECT remote_chirps.id, remote_chirps.uri, remote_chirps.remote_actor_id, remote_chirps.body, remote_chirps.url, remote_chirps.published_at, remote_chirps.created_at, remote_actors.uri AS actor_uri, remote_actors.username AS actor_username
+FROM remote_chirps
+JOIN remote_actors ON remote_actors.id = remote_chirps.remote_actor_id
+JOIN remote_follows ON remote_follows.remote_actor_id = remote_chirps.remote_actor_id
+WHERE remote_follows.user_id = $1 AND remote_follows.accepted_at IS NOT NULL
+ORDER BY remote_chirps.published_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetRemoteTimelineParams struct {
+	UserID uuid.UUID
+	Limit  int32
+	Offset int32
+}
+
+type GetRemoteTimelineRow struct {
+	ID            uuid.UUID
+	Uri           string
+	RemoteActorID uuid.UUID
+	Body          string
+	Url           string
+	PublishedAt   time.Time
+	CreatedAt     time.Time
+	ActorUri      string
+	ActorUsername string
+}
+
+// GetRemoteTimeline returns the remote chirps of the actors a user follows,
+// newest first.
+func (q *Queries) GetRemoteTimeline(ctx context.Context, arg GetRemoteTimelineParams) ([]GetRemoteTimelineRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRemoteTimeline, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRemoteTimelineRow
+	for rows.Next() {
+		var i GetRemoteTimelineRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Uri,
+			&i.RemoteActorID,
+			&i.Body,
+			&i.Url,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.ActorUri,
+			&i.ActorUsername,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isRemoteActorFollowed = `-- name: IsRemoteActorFollowed :one
+
+SELECT EXISTS (
+    SELECT 1 FROM remote_follows
+    JOIN remote_actors ON remote_actors.id = remote_follows.remote_actor_id
+    WHERE remote_actors.uri = $1 AND remote_follows.accepted_at IS NOT NULL
+)
+`
+
+func (q *Queries) IsRemoteActorFollowed(ctx context.Context, actorUri string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isRemoteActorFollowed, actorUri)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const removeRemoteFollower = `-- name: RemoveRemoteFollower :exec
+
+DELETE FROM remote_followers
+USING remote_actors
+WHERE remote_followers.remote_actor_id = remote_actors.id
+AND remote_followers.user_id = $1 AND remote_actors.uri = $2
+`
+
+type RemoveRemoteFollowerParams struct {
+	UserID   uuid.UUID
+	ActorUri string
+}
+
+func (q *Queries) RemoveRemoteFollower(ctx context.Context, arg RemoveRemoteFollowerParams) error {
+	_, err := q.db.ExecContext(ctx, removeRemoteFollower, arg.UserID, arg.ActorUri)
+	return err
+}
+
+const removeRemoteLike = `-- name: RemoveRemoteLike :exec
+
+DELETE FROM remote_likes
+USING remote_actors
+WHERE remote_likes.remote_actor_id = remote_actors.id
+AND remote_likes.chirp_id = $1 AND remote_actors.uri = $2
+`
+
+type RemoveRemoteLikeParams struct {
+	ChirpID  uuid.UUID
+	ActorUri string
+}
+
+func (q *Queries) RemoveRemoteLike(ctx context.Context, arg RemoveRemoteLikeParams) error {
+	_, err := q.db.ExecContext(ctx, removeRemoteLike, arg.ChirpID, arg.ActorUri)
+	return err
+}
+
+const upsertRemoteActor = `-- name: UpsertRemoteActor :exec
+
+INSERT INTO remote_actors (id, uri, username, inbox, shared_inbox, public_key, fetched_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW() AT TIME ZONE 'utc')
+ON CONFLICT (uri) DO UPDATE SET
+    username = EXCLUDED.username,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key = EXCLUDED.public_key,
+    fetched_at = EXCLUDED.fetched_at
+`
+
+type UpsertRemoteActorParams struct {
+	Uri         string
+	Username    string
+	Inbox       string
+	SharedInbox string
+	PublicKey   string
+}
+
+func (q *Queries) UpsertRemoteActor(ctx context.Context, arg UpsertRemoteActorParams) error {
+	_, err := q.db.ExecContext(ctx, upsertRemoteActor,
+		arg.Uri,
+		arg.Username,
+		arg.Inbox,
+		arg.SharedInbox,
+		arg.PublicKey,
+	)
+	return err
+}
@@ -0,0 +1,185 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: bookmarks.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const bookmarkChirp = `-- name: BookmarkChirp :exec
+INSERT INTO chirp_bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING
+`
+
+type BookmarkChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) BookmarkChirp(ctx context.Context, arg BookmarkChirpParams) error {
+	_, err := q.db.ExecContext(ctx, bookmarkChirp, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const getBookmarkedChirps = `-- name: GetBookmarkedChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirp_bookmarks
+JOIN chirps ON chirps.id = chirp_bookmarks.chirp_id
+WHERE chirp_bookmarks.user_id = $1
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = $1 OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+)
+ORDER BY chirp_bookmarks.created_at DESC
+LIMIT $3 OFFSET $2
+`
+
+type GetBookmarkedChirpsParams struct {
+	UserID     uuid.UUID
+	PageOffset int32
+	PageSize   int32
+}
+
+func (q *Queries) GetBookmarkedChirps(ctx context.Context, arg GetBookmarkedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkedChirps, arg.UserID, arg.PageOffset, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPinnedChirpIDs = `-- name: GetPinnedChirpIDs :many
+
+SELECT chirp_id FROM chirp_pins
+WHERE user_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetPinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedChirpIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var chirp_id uuid.UUID
+		if err := rows.Scan(&chirp_id); err != nil {
+			return nil, err
+		}
+		items = append(items, chirp_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isChirpPinned = `-- name: IsChirpPinned :one
+
+SELECT EXISTS (SELECT 1 FROM chirp_pins WHERE chirp_id = $1)::boolean AS pinned
+`
+
+func (q *Queries) IsChirpPinned(ctx context.Context, chirpID uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isChirpPinned, chirpID)
+	var pinned bool
+	err := row.Scan(&pinned)
+	return pinned, err
+}
+
+const pinChirp = `-- name: PinChirp :execrows
+
+INSERT INTO chirp_pins (chirp_id, user_id, created_at)
+SELECT $1::uuid, $2::uuid, NOW() AT TIME ZONE 'utc'
+WHERE (SELECT COUNT(*) FROM chirp_pins WHERE chirp_pins.user_id = $2::uuid) < $3::int
+ON CONFLICT DO NOTHING
+`
+
+type PinChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+	MaxPins int32
+}
+
+// The chirp is only pinned if its author has less than max_pins pinned
+// chirps. The count is only reliable with the author locked, see LockUser.
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pinChirp, arg.ChirpID, arg.UserID, arg.MaxPins)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeBookmark = `-- name: RemoveBookmark :exec
+
+DELETE FROM chirp_bookmarks
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type RemoveBookmarkParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) RemoveBookmark(ctx context.Context, arg RemoveBookmarkParams) error {
+	_, err := q.db.ExecContext(ctx, removeBookmark, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :exec
+
+DELETE FROM chirp_pins
+WHERE chirp_id = $1 AND user_id = $2
+`
+
+type UnpinChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, unpinChirp, arg.ChirpID, arg.UserID)
+	return err
+}
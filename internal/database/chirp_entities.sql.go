@@ -0,0 +1,219 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chirp_entities.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const addChirpHashtag = `-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AddChirpHashtagParams struct {
+	ChirpID uuid.UUID
+	Tag     string
+}
+
+func (q *Queries) AddChirpHashtag(ctx context.Context, arg AddChirpHashtagParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpHashtag, arg.ChirpID, arg.Tag)
+	return err
+}
+
+const addChirpMention = `-- name: AddChirpMention :exec
+
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT chirps.id, $1::uuid FROM chirps
+WHERE chirps.id = $2::uuid
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid)
+)
+ON CONFLICT DO NOTHING
+`
+
+type AddChirpMentionParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+// Mentions between users who blocked each other aren't recorded.
+func (q *Queries) AddChirpMention(ctx context.Context, arg AddChirpMentionParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpMention, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const claimChirpMentions = `-- name: ClaimChirpMentions :many
+
+UPDATE chirp_mentions
+SET notified = TRUE
+WHERE chirp_id = $1 AND NOT notified
+RETURNING user_id
+`
+
+// ClaimChirpMentions marks the mentions of a chirp as notified, and returns
+// the users who weren't notified yet.
+func (q *Queries) ClaimChirpMentions(ctx context.Context, chirpID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, claimChirpMentions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteChirpEntities = `-- name: DeleteChirpEntities :exec
+
+WITH deleted_hashtags AS (
+    DELETE FROM chirp_hashtags WHERE chirp_hashtags.chirp_id = $1
+)
+DELETE FROM chirp_mentions
+WHERE chirp_mentions.chirp_id = $1
+`
+
+func (q *Queries) DeleteChirpEntities(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpEntities, chirpID)
+	return err
+}
+
+const getChirpsByHashtag = `-- name: GetChirpsByHashtag :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = $1 AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at ASC
+`
+
+type GetChirpsByHashtagParams struct {
+	Tag      string
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByHashtag(ctx context.Context, arg GetChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByHashtag, arg.Tag, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsMentioningUser = `-- name: GetChirpsMentioningUser :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = $1 AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at ASC
+`
+
+type GetChirpsMentioningUserParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsMentioningUser(ctx context.Context, arg GetChirpsMentioningUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsMentioningUser, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,79 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chirp_events.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const getHiddenAuthorIDs = `-- name: GetHiddenAuthorIDs :many
+
+SELECT blocks.blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = $1::uuid
+UNION
+SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $1::uuid
+UNION
+SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = $1::uuid
+`
+
+// GetHiddenAuthorIDs returns the users whose chirps viewer doesn't see
+// because of a block, either way, or a mute.
+func (q *Queries) GetHiddenAuthorIDs(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getHiddenAuthorIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyChirpEvent = `-- name: NotifyChirpEvent :exec
+SELECT pg_notify('chirp_events', json_build_object(
+    'id', nextval('chirp_event_ids'),
+    'type', $1::text,
+    'chirp_id', $2::uuid,
+    'author_id', $3::uuid
+)::text)
+`
+
+type NotifyChirpEventParams struct {
+	Type     string
+	ChirpID  uuid.UUID
+	AuthorID uuid.UUID
+}
+
+// The notification is sent when the surrounding transaction commits, if any.
+func (q *Queries) NotifyChirpEvent(ctx context.Context, arg NotifyChirpEventParams) error {
+	_, err := q.db.ExecContext(ctx, notifyChirpEvent, arg.Type, arg.ChirpID, arg.AuthorID)
+	return err
+}
+
+const notifyNotificationEvent = `-- name: NotifyNotificationEvent :exec
+
+SELECT pg_notify('notification_events', CAST($1::uuid AS text))
+`
+
+// NotifyNotificationEvent tells live connections that user has a new
+// notification, when the surrounding transaction commits.
+func (q *Queries) NotifyNotificationEvent(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, notifyNotificationEvent, userID)
+	return err
+}
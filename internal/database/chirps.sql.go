@@ -7,29 +7,58 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const countChirpsByUserIDSince = `-- name: CountChirpsByUserIDSince :one
+
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at >= $2
+`
+
+type CountChirpsByUserIDSinceParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+func (q *Queries) CountChirpsByUserIDSince(ctx context.Context, arg CountChirpsByUserIDSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserIDSince, arg.UserID, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW() AT TIME ZONE 'utc',
     NOW() AT TIME ZONE 'utc',
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	Status    string
+	PublishAt sql.NullTime
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,29 +66,84 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
+const deleteUnpublishedChirp = `-- name: DeleteUnpublishedChirp :many
 
-DELETE FROM chirps
-WHERE id = $1
+WITH deleted AS (
+    DELETE FROM chirps
+    WHERE chirps.id = $1 AND chirps.user_id = $2 AND chirps.status IN ('draft', 'scheduled')
+    RETURNING chirps.id
+)
+SELECT media_files.storage_key, media_files.thumbnail_key
+FROM media_files
+JOIN deleted ON media_files.chirp_id = deleted.id
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
+type DeleteUnpublishedChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+type DeleteUnpublishedChirpRow struct {
+	StorageKey   string
+	ThumbnailKey sql.NullString
+}
+
+// DeleteUnpublishedChirp returns the keys of the files of the media of the
+// deleted chirp, like PurgeDeletedChirps.
+func (q *Queries) DeleteUnpublishedChirp(ctx context.Context, arg DeleteUnpublishedChirpParams) ([]DeleteUnpublishedChirpRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteUnpublishedChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteUnpublishedChirpRow
+	for rows.Next() {
+		var i DeleteUnpublishedChirpRow
+		if err := rows.Scan(&i.StorageKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
 
-SELECT id, created_at, updated_at, body, user_id from chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
+WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = $1::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +157,11 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -89,7 +178,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 
 const getChirpByID = `-- name: GetChirpByID :one
 
-SELECT id, created_at, updated_at, body, user_id from chirps
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
 WHERE id = $1
 `
 
@@ -102,19 +191,172 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
 	)
 	return i, err
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
 
-SELECT id, created_at, updated_at, body, user_id from chirps
-WHERE user_id = $1
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
+WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestChirps = `-- name: GetLatestChirps :many
+
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
+WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = $1::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at DESC
+LIMIT $3 OFFSET $2
+`
+
+type GetLatestChirpsParams struct {
+	ViewerID   uuid.UUID
+	PageOffset int32
+	PageSize   int32
+}
+
+// A page of the chirps of GetAllChirps, newest first.
+func (q *Queries) GetLatestChirps(ctx context.Context, arg GetLatestChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getLatestChirps, arg.ViewerID, arg.PageOffset, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestChirpsByUserID = `-- name: GetLatestChirpsByUserID :many
+
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
+WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetLatestChirpsByUserIDParams struct {
+	UserID     uuid.UUID
+	ViewerID   uuid.UUID
+	PageOffset int32
+	PageSize   int32
+}
+
+// A page of the chirps of GetChirpsByUserID, newest first.
+func (q *Queries) GetLatestChirpsByUserID(ctx context.Context, arg GetLatestChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getLatestChirpsByUserID,
+		arg.UserID,
+		arg.ViewerID,
+		arg.PageOffset,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +370,11 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -141,3 +388,259 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getUnpublishedChirpsByUserID = `-- name: GetUnpublishedChirpsByUserID :many
+
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL
+ORDER BY publish_at ASC NULLS LAST, created_at ASC
+`
+
+func (q *Queries) GetUnpublishedChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUnpublishedChirpsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockChirp = `-- name: LockChirp :one
+
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator from chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+// LockChirp returns a chirp like GetChirpByID, locking it until the end of
+// the transaction.
+func (q *Queries) LockChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, lockChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
+
+const moderatorDeleteChirp = `-- name: ModeratorDeleteChirp :execrows
+
+WITH unpinned AS (
+    DELETE FROM chirp_pins WHERE chirp_pins.chirp_id = $1
+)
+UPDATE chirps
+SET
+    deleted_at = COALESCE(deleted_at, NOW() AT TIME ZONE 'utc'),
+    deleted_by_moderator = TRUE,
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND NOT deleted_by_moderator
+`
+
+// Chirps already deleted by their author are marked too, so that they can't
+// be restored.
+func (q *Queries) ModeratorDeleteChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moderatorDeleteChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const publishDueChirp = `-- name: PublishDueChirp :one
+
+UPDATE chirps
+SET
+    status = 'published',
+    created_at = NOW() AT TIME ZONE 'utc',
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW() AT TIME ZONE 'utc' AND deleted_at IS NULL
+    ORDER BY publish_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+// PublishDueChirp claims the scheduled chirp due the longest ago, skipping
+// those claimed by other transactions, and publishes it. It returns no row
+// when no chirp is due.
+func (q *Queries) PublishDueChirp(ctx context.Context) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishDueChirp)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :many
+
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE deleted_at < $1::timestamp
+    RETURNING id
+)
+SELECT media_files.storage_key, media_files.thumbnail_key
+FROM media_files
+JOIN purged ON media_files.chirp_id = purged.id
+`
+
+type PurgeDeletedChirpsRow struct {
+	StorageKey   string
+	ThumbnailKey sql.NullString
+}
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) ([]PurgeDeletedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeDeletedChirpsRow
+	for rows.Next() {
+		var i PurgeDeletedChirpsRow
+		if err := rows.Scan(&i.StorageKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND deleted_at IS NOT NULL AND NOT deleted_by_moderator
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+func (q *Queries) RestoreChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :exec
+
+WITH unpinned AS (
+    DELETE FROM chirp_pins WHERE chirp_pins.chirp_id = $1
+)
+UPDATE chirps
+SET deleted_at = NOW() AT TIME ZONE 'utc', updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+// Deleting a chirp unpins it, and restoring it doesn't pin it again.
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirp, id)
+	return err
+}
+
+const updateUnpublishedChirp = `-- name: UpdateUnpublishedChirp :one
+
+UPDATE chirps
+SET
+    body = $1,
+    status = $2,
+    publish_at = $3,
+    created_at = CASE WHEN $2 = 'published' THEN NOW() AT TIME ZONE 'utc' ELSE created_at END,
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $4 AND user_id = $5 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, deleted_at, hidden_at, deleted_by_moderator
+`
+
+type UpdateUnpublishedChirpParams struct {
+	Body      string
+	Status    string
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) UpdateUnpublishedChirp(ctx context.Context, arg UpdateUnpublishedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateUnpublishedChirp,
+		arg.Body,
+		arg.Status,
+		arg.PublishAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.DeletedByModerator,
+	)
+	return i, err
+}
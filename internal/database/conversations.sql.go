@@ -0,0 +1,308 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: conversations.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const addConversationParticipant = `-- name: AddConversationParticipant :exec
+
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+`
+
+type AddConversationParticipantParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) AddConversationParticipant(ctx context.Context, arg AddConversationParticipantParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationParticipant, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const createConversation = `-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc')
+RETURNING id, created_at, updated_at
+`
+
+func (q *Queries) CreateConversation(ctx context.Context) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation)
+	var i Conversation
+	err := row.Scan(&i.ID, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+
+WITH message AS (
+    INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+    SELECT gen_random_uuid(), $1::uuid, $2::uuid, $3::text, NOW() AT TIME ZONE 'utc'
+    WHERE NOT EXISTS (
+        SELECT 1 FROM conversation_participants
+        JOIN blocks ON (blocks.blocker_id = conversation_participants.user_id AND blocks.blocked_id = $2::uuid)
+            OR (blocks.blocker_id = $2::uuid AND blocks.blocked_id = conversation_participants.user_id)
+        WHERE conversation_participants.conversation_id = $1::uuid
+    )
+    RETURNING id, conversation_id, sender_id, body, created_at
+), touched AS (
+    UPDATE conversations
+    SET updated_at = message.created_at
+    FROM message
+    WHERE conversations.id = message.conversation_id
+)
+SELECT id, conversation_id, sender_id, body, created_at FROM message
+`
+
+type CreateMessageParams struct {
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type CreateMessageRow struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+// The message is only created if no other participant and the sender
+// blocked each other.
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (CreateMessageRow, error) {
+	row := q.db.QueryRowContext(ctx, createMessage, arg.ConversationID, arg.SenderID, arg.Body)
+	var i CreateMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteMessageForUser = `-- name: DeleteMessageForUser :execrows
+
+INSERT INTO message_deletions (message_id, user_id, created_at)
+SELECT messages.id, $1::uuid, NOW() AT TIME ZONE 'utc' FROM messages
+WHERE messages.id = $2::uuid AND messages.conversation_id = $3::uuid
+ON CONFLICT DO NOTHING
+`
+
+type DeleteMessageForUserParams struct {
+	UserID         uuid.UUID
+	MessageID      uuid.UUID
+	ConversationID uuid.UUID
+}
+
+func (q *Queries) DeleteMessageForUser(ctx context.Context, arg DeleteMessageForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMessageForUser, arg.UserID, arg.MessageID, arg.ConversationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getConversationParticipants = `-- name: GetConversationParticipants :many
+
+SELECT conversation_id, user_id, joined_at, last_read_at FROM conversation_participants
+WHERE conversation_id = $1
+ORDER BY joined_at ASC, user_id ASC
+`
+
+func (q *Queries) GetConversationParticipants(ctx context.Context, conversationID uuid.UUID) ([]ConversationParticipant, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationParticipants, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ConversationParticipant
+	for rows.Next() {
+		var i ConversationParticipant
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UserID,
+			&i.JoinedAt,
+			&i.LastReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getConversationsByUserID = `-- name: GetConversationsByUserID :many
+
+SELECT
+    conversations.id, conversations.created_at, conversations.updated_at,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id != $1
+        AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+        AND NOT EXISTS (
+            SELECT 1 FROM message_deletions
+            WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = $1
+        )
+    )::bigint AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = $1
+ORDER BY conversations.updated_at DESC
+LIMIT $3 OFFSET $2
+`
+
+type GetConversationsByUserIDParams struct {
+	UserID     uuid.UUID
+	PageOffset int32
+	PageSize   int32
+}
+
+type GetConversationsByUserIDRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UnreadCount int64
+}
+
+func (q *Queries) GetConversationsByUserID(ctx context.Context, arg GetConversationsByUserIDParams) ([]GetConversationsByUserIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationsByUserID, arg.UserID, arg.PageOffset, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetConversationsByUserIDRow
+	for rows.Next() {
+		var i GetConversationsByUserIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessages = `-- name: GetMessages :many
+
+SELECT messages.id, messages.conversation_id, messages.sender_id, messages.body, messages.created_at FROM messages
+WHERE messages.conversation_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM message_deletions
+    WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = $2
+)
+AND (
+    $3::uuid IS NULL
+    OR (messages.created_at, messages.id) < (
+        SELECT before.created_at, before.id FROM messages AS before
+        WHERE before.id = $3::uuid
+    )
+)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT $4
+`
+
+type GetMessagesParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	BeforeID       uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) GetMessages(ctx context.Context, arg GetMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, getMessages,
+		arg.ConversationID,
+		arg.UserID,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isConversationParticipant = `-- name: IsConversationParticipant :one
+
+SELECT EXISTS (
+    SELECT 1 FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+)::boolean AS participant
+`
+
+type IsConversationParticipantParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) IsConversationParticipant(ctx context.Context, arg IsConversationParticipantParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isConversationParticipant, arg.ConversationID, arg.UserID)
+	var participant bool
+	err := row.Scan(&participant)
+	return participant, err
+}
+
+const markConversationRead = `-- name: MarkConversationRead :exec
+
+UPDATE conversation_participants
+SET last_read_at = NOW() AT TIME ZONE 'utc'
+WHERE conversation_id = $1 AND user_id = $2
+`
+
+type MarkConversationReadParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error {
+	_, err := q.db.ExecContext(ctx, markConversationRead, arg.ConversationID, arg.UserID)
+	return err
+}
@@ -0,0 +1,151 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: feeds.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getTagFeedChirps = `-- name: GetTagFeedChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.tag = $1 AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND users.state <> 'shadow_banned'
+ORDER BY chirps.created_at DESC
+LIMIT $2
+`
+
+type GetTagFeedChirpsParams struct {
+	Tag       string
+	MaxChirps int32
+}
+
+// The latest public chirps with a hashtag, newest first.
+func (q *Queries) GetTagFeedChirps(ctx context.Context, arg GetTagFeedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTagFeedChirps, arg.Tag, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTagFeedUpdatedAt = `-- name: GetTagFeedUpdatedAt :one
+
+SELECT COALESCE(MAX(GREATEST(chirps.updated_at, users.updated_at)), 'epoch')::timestamp AS updated_at
+FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.tag = $1 AND chirps.status = 'published'
+`
+
+// The last change to the published chirps with a hashtag or to their
+// authors, or the epoch if there is none.
+func (q *Queries) GetTagFeedUpdatedAt(ctx context.Context, tag string) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getTagFeedUpdatedAt, tag)
+	var updated_at time.Time
+	err := row.Scan(&updated_at)
+	return updated_at, err
+}
+
+const getUserFeedChirps = `-- name: GetUserFeedChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND users.state <> 'shadow_banned'
+ORDER BY chirps.created_at DESC
+LIMIT $2
+`
+
+type GetUserFeedChirpsParams struct {
+	UserID    uuid.UUID
+	MaxChirps int32
+}
+
+// The latest public chirps of a user, newest first.
+func (q *Queries) GetUserFeedChirps(ctx context.Context, arg GetUserFeedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFeedChirps, arg.UserID, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserFeedUpdatedAt = `-- name: GetUserFeedUpdatedAt :one
+SELECT GREATEST(users.updated_at, COALESCE(MAX(chirps.updated_at), users.updated_at))::timestamp AS updated_at
+FROM users
+LEFT JOIN chirps ON chirps.user_id = users.id AND chirps.status = 'published'
+WHERE users.id = $1
+GROUP BY users.id
+`
+
+// The last change to the published chirps of a user, deleting and hiding
+// them included, or to the user.
+func (q *Queries) GetUserFeedUpdatedAt(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getUserFeedUpdatedAt, id)
+	var updated_at time.Time
+	err := row.Scan(&updated_at)
+	return updated_at, err
+}
@@ -0,0 +1,110 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: filter_terms.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const addChirpFilterFlag = `-- name: AddChirpFilterFlag :exec
+
+INSERT INTO chirp_filter_flags (chirp_id, term, created_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING
+`
+
+type AddChirpFilterFlagParams struct {
+	ChirpID uuid.UUID
+	Term    string
+}
+
+func (q *Queries) AddChirpFilterFlag(ctx context.Context, arg AddChirpFilterFlagParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpFilterFlag, arg.ChirpID, arg.Term)
+	return err
+}
+
+const deleteFilterTerm = `-- name: DeleteFilterTerm :execrows
+
+DELETE FROM filter_terms
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFilterTerm(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFilterTerm, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFilterTerms = `-- name: GetFilterTerms :many
+SELECT id, created_at, updated_at, term, action FROM filter_terms
+ORDER BY term ASC
+`
+
+func (q *Queries) GetFilterTerms(ctx context.Context) ([]FilterTerm, error) {
+	rows, err := q.db.QueryContext(ctx, getFilterTerms)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterTerm
+	for rows.Next() {
+		var i FilterTerm
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Term,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertFilterTerm = `-- name: UpsertFilterTerm :one
+
+INSERT INTO filter_terms (id, created_at, updated_at, term, action)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2
+)
+ON CONFLICT (term) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW() AT TIME ZONE 'utc'
+RETURNING id, created_at, updated_at, term, action
+`
+
+type UpsertFilterTermParams struct {
+	Term   string
+	Action string
+}
+
+func (q *Queries) UpsertFilterTerm(ctx context.Context, arg UpsertFilterTermParams) (FilterTerm, error) {
+	row := q.db.QueryRowContext(ctx, upsertFilterTerm, arg.Term, arg.Action)
+	var i FilterTerm
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Term,
+		&i.Action,
+	)
+	return i, err
+}
@@ -0,0 +1,187 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: media_files.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const attachMediaFileToChirp = `-- name: AttachMediaFileToChirp :one
+
+UPDATE media_files
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL
+RETURNING id, created_at, user_id, chirp_id, position, content_type, size_bytes, storage_key, thumbnail_key, width, height
+`
+
+type AttachMediaFileToChirpParams struct {
+	ChirpID  uuid.NullUUID
+	Position int32
+	ID       uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) AttachMediaFileToChirp(ctx context.Context, arg AttachMediaFileToChirpParams) (MediaFile, error) {
+	row := q.db.QueryRowContext(ctx, attachMediaFileToChirp,
+		arg.ChirpID,
+		arg.Position,
+		arg.ID,
+		arg.UserID,
+	)
+	var i MediaFile
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Position,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.Width,
+		&i.Height,
+	)
+	return i, err
+}
+
+const createMediaFile = `-- name: CreateMediaFile :one
+INSERT INTO media_files (id, created_at, user_id, content_type, size_bytes, storage_key, thumbnail_key, width, height)
+VALUES (
+    $1,
+    NOW() AT TIME ZONE 'utc',
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING id, created_at, user_id, chirp_id, position, content_type, size_bytes, storage_key, thumbnail_key, width, height
+`
+
+type CreateMediaFileParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ContentType  string
+	SizeBytes    int64
+	StorageKey   string
+	ThumbnailKey sql.NullString
+	Width        sql.NullInt32
+	Height       sql.NullInt32
+}
+
+func (q *Queries) CreateMediaFile(ctx context.Context, arg CreateMediaFileParams) (MediaFile, error) {
+	row := q.db.QueryRowContext(ctx, createMediaFile,
+		arg.ID,
+		arg.UserID,
+		arg.ContentType,
+		arg.SizeBytes,
+		arg.StorageKey,
+		arg.ThumbnailKey,
+		arg.Width,
+		arg.Height,
+	)
+	var i MediaFile
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Position,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.Width,
+		&i.Height,
+	)
+	return i, err
+}
+
+const detachMediaFilesFromChirp = `-- name: DetachMediaFilesFromChirp :exec
+
+UPDATE media_files
+SET chirp_id = NULL, position = 0
+WHERE chirp_id = $1
+`
+
+func (q *Queries) DetachMediaFilesFromChirp(ctx context.Context, chirpID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, detachMediaFilesFromChirp, chirpID)
+	return err
+}
+
+const getMediaFileByID = `-- name: GetMediaFileByID :one
+
+SELECT id, created_at, user_id, chirp_id, position, content_type, size_bytes, storage_key, thumbnail_key, width, height FROM media_files
+WHERE id = $1
+`
+
+func (q *Queries) GetMediaFileByID(ctx context.Context, id uuid.UUID) (MediaFile, error) {
+	row := q.db.QueryRowContext(ctx, getMediaFileByID, id)
+	var i MediaFile
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ChirpID,
+		&i.Position,
+		&i.ContentType,
+		&i.SizeBytes,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.Width,
+		&i.Height,
+	)
+	return i, err
+}
+
+const getMediaFilesForChirps = `-- name: GetMediaFilesForChirps :many
+
+SELECT id, created_at, user_id, chirp_id, position, content_type, size_bytes, storage_key, thumbnail_key, width, height FROM media_files
+WHERE chirp_id = ANY($1::uuid[])
+ORDER BY chirp_id, position ASC
+`
+
+func (q *Queries) GetMediaFilesForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]MediaFile, error) {
+	rows, err := q.db.QueryContext(ctx, getMediaFilesForChirps, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MediaFile
+	for rows.Next() {
+		var i MediaFile
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Position,
+			&i.ContentType,
+			&i.SizeBytes,
+			&i.StorageKey,
+			&i.ThumbnailKey,
+			&i.Width,
+			&i.Height,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -6,17 +6,207 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	PublicKey  string
+	PrivateKey string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Body               string
+	UserID             uuid.UUID
+	Status             string
+	PublishAt          sql.NullTime
+	DeletedAt          sql.NullTime
+	HiddenAt           sql.NullTime
+	DeletedByModerator bool
+}
+
+type ChirpBookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpFilterFlag struct {
+	ChirpID    uuid.UUID
+	Term       string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type ChirpHashtag struct {
+	ChirpID uuid.UUID
+	Tag     string
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID  uuid.UUID
+	UserID   uuid.UUID
+	Notified bool
+}
+
+type ChirpPin struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	ResolvedAt sql.NullTime
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type FilterTerm struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
+	Term      string
+	Action    string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type MediaFile struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int64
+	StorageKey   string
+	ThumbnailKey sql.NullString
+	Width        sql.NullInt32
+	Height       sql.NullInt32
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type MessageDeletion struct {
+	MessageID uuid.UUID
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ModeratorID  uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Action       string
+	Note         string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	GroupKey  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ReadAt    sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type OutboxDelivery struct {
+	EventID       uuid.UUID
+	Subscriber    string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	DeliveredAt   sql.NullTime
+	FailedAt      sql.NullTime
+}
+
+type OutboxEvent struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	Type       string
+	Payload    json.RawMessage
+	ExpandedAt sql.NullTime
+}
+
+type PolkaPayload struct {
+	ID         uuid.UUID
+	ReceivedAt time.Time
+	EventID    sql.NullString
+	Payload    []byte
+	Outcome    string
+}
+
+type PolkaProcessedEvent struct {
+	EventID     string
+	ProcessedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +218,81 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID          uuid.UUID
+	Uri         string
+	Username    string
+	Inbox       string
+	SharedInbox string
+	PublicKey   string
+	FetchedAt   time.Time
+}
+
+type RemoteChirp struct {
+	ID            uuid.UUID
+	Uri           string
+	RemoteActorID uuid.UUID
+	Body          string
+	Url           string
+	PublishedAt   time.Time
+	CreatedAt     time.Time
+}
+
+type RemoteFollow struct {
+	UserID        uuid.UUID
+	RemoteActorID uuid.UUID
+	FollowUri     string
+	CreatedAt     time.Time
+	AcceptedAt    sql.NullTime
+}
+
+type RemoteFollower struct {
+	UserID        uuid.UUID
+	RemoteActorID uuid.UUID
+	FollowUri     string
+	CreatedAt     time.Time
+}
+
+type RemoteLike struct {
+	ChirpID       uuid.UUID
+	RemoteActorID uuid.UUID
+	LikeUri       string
+	CreatedAt     time.Time
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CanceledAt         sql.NullTime
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type TrendingItem struct {
+	SnapshotID uuid.UUID
+	Kind       string
+	Rank       int32
+	Tag        sql.NullString
+	ChirpID    uuid.NullUUID
+	Score      float64
+}
+
+type TrendingSnapshot struct {
+	ID         uuid.UUID
+	TimeWindow string
+	ComputedAt time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +300,54 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    sql.NullBool
+	IsAdmin        bool
+	State          string
+	StateUntil     sql.NullTime
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarMediaID  uuid.NullUUID
+}
+
+type UserStateChange struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	ChangedBy  uuid.NullUUID
+	OldState   string
+	NewState   string
+	StateUntil sql.NullTime
+	Reason     string
+}
+
+type Webhook struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	Global     bool
+}
+
+type WebhookAttempt struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	DeliveryID   uuid.UUID
+	StatusCode   sql.NullInt32
+	Error        sql.NullString
+	ResponseBody string
+	DurationMs   int32
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	EventID       uuid.NullUUID
+	EventType     string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
 }
@@ -0,0 +1,274 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countOpenChirpReports = `-- name: CountOpenChirpReports :one
+
+SELECT COUNT(*) FROM chirp_reports
+WHERE chirp_id = $1 AND resolved_at IS NULL
+`
+
+func (q *Queries) CountOpenChirpReports(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOpenChirpReports, chirpID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createChirpReport = `-- name: CreateChirpReport :execrows
+INSERT INTO chirp_reports (id, created_at, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+`
+
+type CreateChirpReportParams struct {
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+}
+
+func (q *Queries) CreateChirpReport(ctx context.Context, arg CreateChirpReportParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createChirpReport,
+		arg.ChirpID,
+		arg.ReporterID,
+		arg.Reason,
+		arg.Details,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createModerationAction = `-- name: CreateModerationAction :one
+
+INSERT INTO moderation_actions (id, created_at, moderator_id, chirp_id, target_user_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, moderator_id, chirp_id, target_user_id, action, note
+`
+
+type CreateModerationActionParams struct {
+	ModeratorID  uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Action       string
+	Note         string
+}
+
+func (q *Queries) CreateModerationAction(ctx context.Context, arg CreateModerationActionParams) (ModerationAction, error) {
+	row := q.db.QueryRowContext(ctx, createModerationAction,
+		arg.ModeratorID,
+		arg.ChirpID,
+		arg.TargetUserID,
+		arg.Action,
+		arg.Note,
+	)
+	var i ModerationAction
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ModeratorID,
+		&i.ChirpID,
+		&i.TargetUserID,
+		&i.Action,
+		&i.Note,
+	)
+	return i, err
+}
+
+const getModerationActions = `-- name: GetModerationActions :many
+
+SELECT id, created_at, moderator_id, chirp_id, target_user_id, action, note FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type GetModerationActionsParams struct {
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) GetModerationActions(ctx context.Context, arg GetModerationActionsParams) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationActions, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ModeratorID,
+			&i.ChirpID,
+			&i.TargetUserID,
+			&i.Action,
+			&i.Note,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getModerationQueue = `-- name: GetModerationQueue :many
+
+SELECT
+    chirps.id,
+    chirps.created_at,
+    chirps.body,
+    chirps.user_id,
+    chirps.hidden_at,
+    (
+        SELECT COUNT(*) FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    )::bigint AS report_count,
+    COALESCE((
+        SELECT array_agg(DISTINCT chirp_reports.reason) FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    ), '{}')::text[] AS reasons,
+    COALESCE((
+        SELECT array_agg(chirp_filter_flags.term) FROM chirp_filter_flags
+        WHERE chirp_filter_flags.chirp_id = chirps.id AND chirp_filter_flags.resolved_at IS NULL
+    ), '{}')::text[] AS flagged_terms
+FROM chirps
+WHERE chirps.deleted_at IS NULL AND (
+    EXISTS (
+        SELECT 1 FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    ) OR EXISTS (
+        SELECT 1 FROM chirp_filter_flags
+        WHERE chirp_filter_flags.chirp_id = chirps.id AND chirp_filter_flags.resolved_at IS NULL
+    )
+)
+ORDER BY report_count DESC, chirps.created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type GetModerationQueueParams struct {
+	Limit  int32
+	Offset int32
+}
+
+type GetModerationQueueRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	HiddenAt     sql.NullTime
+	ReportCount  int64
+	Reasons      []string
+	FlaggedTerms []string
+}
+
+func (q *Queries) GetModerationQueue(ctx context.Context, arg GetModerationQueueParams) ([]GetModerationQueueRow, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationQueue, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetModerationQueueRow
+	for rows.Next() {
+		var i GetModerationQueueRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.ReportCount,
+			pq.Array(&i.Reasons),
+			pq.Array(&i.FlaggedTerms),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :execrows
+
+UPDATE chirps
+SET hidden_at = NOW() AT TIME ZONE 'utc', updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveChirpReports = `-- name: ResolveChirpReports :exec
+
+WITH resolved_flags AS (
+    UPDATE chirp_filter_flags
+    SET resolved_at = NOW() AT TIME ZONE 'utc'
+    WHERE chirp_filter_flags.chirp_id = $1 AND chirp_filter_flags.resolved_at IS NULL
+)
+UPDATE chirp_reports
+SET resolved_at = NOW() AT TIME ZONE 'utc'
+WHERE chirp_reports.chirp_id = $1 AND chirp_reports.resolved_at IS NULL
+`
+
+func (q *Queries) ResolveChirpReports(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resolveChirpReports, chirpID)
+	return err
+}
+
+const unhideChirp = `-- name: UnhideChirp :exec
+
+UPDATE chirps
+SET hidden_at = NULL, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unhideChirp, id)
+	return err
+}
@@ -0,0 +1,267 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+
+SELECT COUNT(*) FROM notifications
+WHERE notifications.user_id = $1 AND notifications.read_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM notification_actors
+    WHERE notification_actors.notification_id = notifications.id
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = notification_actors.actor_id)
+        OR (blocks.blocker_id = notification_actors.actor_id AND blocks.blocked_id = $1)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = $1 AND mutes.muted_id = notification_actors.actor_id
+    )
+)
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getNotificationPreferences = `-- name: GetNotificationPreferences :many
+
+SELECT type, enabled FROM notification_preferences
+WHERE user_id = $1
+`
+
+type GetNotificationPreferencesRow struct {
+	Type    string
+	Enabled bool
+}
+
+func (q *Queries) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]GetNotificationPreferencesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNotificationPreferencesRow
+	for rows.Next() {
+		var i GetNotificationPreferencesRow
+		if err := rows.Scan(&i.Type, &i.Enabled); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNotifications = `-- name: GetNotifications :many
+
+WITH visible_actors AS (
+    SELECT notification_actors.notification_id, notification_actors.actor_id, notification_actors.created_at FROM notification_actors
+    JOIN notifications ON notifications.id = notification_actors.notification_id
+    WHERE notifications.user_id = $1
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = notification_actors.actor_id)
+        OR (blocks.blocker_id = notification_actors.actor_id AND blocks.blocked_id = $1)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = $1 AND mutes.muted_id = notification_actors.actor_id
+    )
+)
+SELECT
+    notifications.id, notifications.type, notifications.chirp_id,
+    notifications.created_at, notifications.updated_at, notifications.read_at,
+    (SELECT COUNT(*) FROM visible_actors WHERE visible_actors.notification_id = notifications.id)::bigint AS actor_count,
+    ARRAY(
+        SELECT visible_actors.actor_id FROM visible_actors
+        WHERE visible_actors.notification_id = notifications.id
+        ORDER BY visible_actors.created_at DESC
+        LIMIT 3
+    )::uuid[] AS recent_actor_ids
+FROM notifications
+WHERE notifications.user_id = $1
+AND (NOT $2::boolean OR notifications.read_at IS NULL)
+AND EXISTS (SELECT 1 FROM visible_actors WHERE visible_actors.notification_id = notifications.id)
+ORDER BY notifications.updated_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetNotificationsParams struct {
+	UserID     uuid.UUID
+	UnreadOnly bool
+	PageOffset int32
+	PageSize   int32
+}
+
+type GetNotificationsRow struct {
+	ID             uuid.UUID
+	Type           string
+	ChirpID        uuid.NullUUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ReadAt         sql.NullTime
+	ActorCount     int64
+	RecentActorIds []uuid.UUID
+}
+
+// Actors blocked or muted since the event are left out, along with the
+// notifications that have no other actor.
+func (q *Queries) GetNotifications(ctx context.Context, arg GetNotificationsParams) ([]GetNotificationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.PageOffset,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNotificationsRow
+	for rows.Next() {
+		var i GetNotificationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Type,
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReadAt,
+			&i.ActorCount,
+			pq.Array(&i.RecentActorIds),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNotificationsRead = `-- name: MarkNotificationsRead :execrows
+
+UPDATE notifications
+SET read_at = NOW() AT TIME ZONE 'utc'
+WHERE user_id = $1 AND read_at IS NULL
+AND (cardinality($2::uuid[]) = 0 OR id = ANY($2::uuid[]))
+`
+
+type MarkNotificationsReadParams struct {
+	UserID uuid.UUID
+	Ids    []uuid.UUID
+}
+
+func (q *Queries) MarkNotificationsRead(ctx context.Context, arg MarkNotificationsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationsRead, arg.UserID, pq.Array(arg.Ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const notify = `-- name: Notify :execrows
+WITH allowed AS (
+    SELECT 1 WHERE $2::uuid != $1::uuid
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = $1::uuid)
+        OR (blocks.blocker_id = $1::uuid AND blocks.blocked_id = $2::uuid)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = $1::uuid
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM notification_preferences
+        WHERE notification_preferences.user_id = $2::uuid
+        AND notification_preferences.type = $3::text AND NOT notification_preferences.enabled
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = $1::uuid AND users.state = 'shadow_banned'
+    )
+), notification AS (
+    INSERT INTO notifications (id, user_id, type, chirp_id, group_key, created_at, updated_at)
+    SELECT gen_random_uuid(), $2::uuid, $3::text, $4::uuid, $5::text,
+        NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc'
+    FROM allowed
+    ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+    DO UPDATE SET updated_at = EXCLUDED.updated_at
+    RETURNING id
+)
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+SELECT notification.id, $1::uuid, NOW() AT TIME ZONE 'utc' FROM notification
+ON CONFLICT DO NOTHING
+`
+
+type NotifyParams struct {
+	ActorID     uuid.UUID
+	RecipientID uuid.UUID
+	Type        string
+	ChirpID     uuid.NullUUID
+	GroupKey    string
+}
+
+// Notify adds actor to the unread notification of recipient with the same
+// group key, creating it if needed. Nothing is recorded for users who blocked
+// each other, actors muted by the recipient or shadow banned, or disabled
+// notification types. No row is affected when the actor was already in the
+// notification.
+func (q *Queries) Notify(ctx context.Context, arg NotifyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, notify,
+		arg.ActorID,
+		arg.RecipientID,
+		arg.Type,
+		arg.ChirpID,
+		arg.GroupKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setNotificationPreference = `-- name: SetNotificationPreference :exec
+
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled
+`
+
+type SetNotificationPreferenceParams struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+func (q *Queries) SetNotificationPreference(ctx context.Context, arg SetNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, setNotificationPreference, arg.UserID, arg.Type, arg.Enabled)
+	return err
+}
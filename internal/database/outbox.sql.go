@@ -0,0 +1,225 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: outbox.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimOutboxDeliveries = `-- name: ClaimOutboxDeliveries :many
+
+WITH due AS (
+    SELECT outbox_deliveries.event_id, outbox_deliveries.subscriber FROM outbox_deliveries
+    WHERE outbox_deliveries.delivered_at IS NULL AND outbox_deliveries.failed_at IS NULL
+    AND outbox_deliveries.next_attempt_at <= NOW() AT TIME ZONE 'utc'
+    ORDER BY outbox_deliveries.next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+), claimed AS (
+    UPDATE outbox_deliveries SET attempts = outbox_deliveries.attempts + 1, next_attempt_at = $2
+    FROM due
+    WHERE outbox_deliveries.event_id = due.event_id AND outbox_deliveries.subscriber = due.subscriber
+    RETURNING outbox_deliveries.event_id, outbox_deliveries.subscriber, outbox_deliveries.attempts
+)
+SELECT claimed.event_id, claimed.subscriber, claimed.attempts,
+    outbox_events.created_at, outbox_events.type, outbox_events.payload
+FROM claimed
+JOIN outbox_events ON outbox_events.id = claimed.event_id
+`
+
+type ClaimOutboxDeliveriesParams struct {
+	BatchSize  int32
+	LeaseUntil time.Time
+}
+
+type ClaimOutboxDeliveriesRow struct {
+	EventID    uuid.UUID
+	Subscriber string
+	Attempts   int32
+	CreatedAt  time.Time
+	Type       string
+	Payload    json.RawMessage
+}
+
+// ClaimOutboxDeliveries leases the due deliveries until lease_until, so
+// that other server instances skip them while they are handled.
+func (q *Queries) ClaimOutboxDeliveries(ctx context.Context, arg ClaimOutboxDeliveriesParams) ([]ClaimOutboxDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimOutboxDeliveries, arg.BatchSize, arg.LeaseUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimOutboxDeliveriesRow
+	for rows.Next() {
+		var i ClaimOutboxDeliveriesRow
+		if err := rows.Scan(
+			&i.EventID,
+			&i.Subscriber,
+			&i.Attempts,
+			&i.CreatedAt,
+			&i.Type,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOutboxEvent = `-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (id, created_at, type, payload)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2)
+`
+
+type CreateOutboxEventParams struct {
+	Type    string
+	Payload json.RawMessage
+}
+
+func (q *Queries) CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) error {
+	_, err := q.db.ExecContext(ctx, createOutboxEvent, arg.Type, arg.Payload)
+	return err
+}
+
+const expandOutboxEvents = `-- name: ExpandOutboxEvents :many
+
+WITH pending AS (
+    SELECT id, type FROM outbox_events
+    WHERE expanded_at IS NULL
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+), deliveries AS (
+    INSERT INTO outbox_deliveries (event_id, subscriber, next_attempt_at)
+    SELECT pending.id, subscribers.name, NOW() AT TIME ZONE 'utc'
+    FROM pending
+    JOIN jsonb_to_recordset($2::jsonb) AS subscribers(type TEXT, name TEXT) ON subscribers.type = pending.type
+    ON CONFLICT DO NOTHING
+)
+UPDATE outbox_events SET expanded_at = NOW() AT TIME ZONE 'utc'
+FROM pending
+WHERE outbox_events.id = pending.id
+RETURNING outbox_events.id
+`
+
+type ExpandOutboxEventsParams struct {
+	BatchSize   int32
+	Subscribers json.RawMessage
+}
+
+// ExpandOutboxEvents creates the deliveries of the events not expanded yet,
+// one per subscriber of their type. Subscribers are passed as a JSON array
+// of {"type": ..., "name": ...} objects.
+func (q *Queries) ExpandOutboxEvents(ctx context.Context, arg ExpandOutboxEventsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expandOutboxEvents, arg.BatchSize, arg.Subscribers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const failOutboxDelivery = `-- name: FailOutboxDelivery :exec
+
+UPDATE outbox_deliveries SET failed_at = NOW() AT TIME ZONE 'utc', last_error = $3
+WHERE event_id = $1 AND subscriber = $2
+`
+
+type FailOutboxDeliveryParams struct {
+	EventID    uuid.UUID
+	Subscriber string
+	LastError  sql.NullString
+}
+
+func (q *Queries) FailOutboxDelivery(ctx context.Context, arg FailOutboxDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, failOutboxDelivery, arg.EventID, arg.Subscriber, arg.LastError)
+	return err
+}
+
+const markOutboxDelivered = `-- name: MarkOutboxDelivered :exec
+
+UPDATE outbox_deliveries SET delivered_at = NOW() AT TIME ZONE 'utc', last_error = NULL
+WHERE event_id = $1 AND subscriber = $2
+`
+
+type MarkOutboxDeliveredParams struct {
+	EventID    uuid.UUID
+	Subscriber string
+}
+
+func (q *Queries) MarkOutboxDelivered(ctx context.Context, arg MarkOutboxDeliveredParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxDelivered, arg.EventID, arg.Subscriber)
+	return err
+}
+
+const pruneOutboxEvents = `-- name: PruneOutboxEvents :execrows
+
+DELETE FROM outbox_events
+WHERE outbox_events.created_at < $1 AND outbox_events.expanded_at IS NOT NULL
+AND NOT EXISTS (
+    SELECT 1 FROM outbox_deliveries
+    WHERE outbox_deliveries.event_id = outbox_events.id AND outbox_deliveries.delivered_at IS NULL
+)
+`
+
+// PruneOutboxEvents deletes the events created before cutoff that every
+// subscriber handled. Failed deliveries are kept for inspection.
+func (q *Queries) PruneOutboxEvents(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneOutboxEvents, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryOutboxDelivery = `-- name: RetryOutboxDelivery :exec
+
+UPDATE outbox_deliveries SET next_attempt_at = $3, last_error = $4
+WHERE event_id = $1 AND subscriber = $2
+`
+
+type RetryOutboxDeliveryParams struct {
+	EventID       uuid.UUID
+	Subscriber    string
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+}
+
+func (q *Queries) RetryOutboxDelivery(ctx context.Context, arg RetryOutboxDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, retryOutboxDelivery,
+		arg.EventID,
+		arg.Subscriber,
+		arg.NextAttemptAt,
+		arg.LastError,
+	)
+	return err
+}
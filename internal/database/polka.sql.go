@@ -0,0 +1,43 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: polka.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createPolkaPayload = `-- name: CreatePolkaPayload :exec
+INSERT INTO polka_payloads (id, received_at, event_id, payload, outcome)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3)
+`
+
+type CreatePolkaPayloadParams struct {
+	EventID sql.NullString
+	Payload []byte
+	Outcome string
+}
+
+func (q *Queries) CreatePolkaPayload(ctx context.Context, arg CreatePolkaPayloadParams) error {
+	_, err := q.db.ExecContext(ctx, createPolkaPayload, arg.EventID, arg.Payload, arg.Outcome)
+	return err
+}
+
+const markPolkaEventProcessed = `-- name: MarkPolkaEventProcessed :execrows
+
+INSERT INTO polka_processed_events (event_id, processed_at)
+VALUES ($1, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING
+`
+
+// MarkPolkaEventProcessed affects no row if the event was already processed.
+func (q *Queries) MarkPolkaEventProcessed(ctx context.Context, eventID string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPolkaEventProcessed, eventID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
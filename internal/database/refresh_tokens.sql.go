@@ -65,6 +65,35 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Re
 	return i, err
 }
 
+const revokeExcessRefreshTokens = `-- name: RevokeExcessRefreshTokens :execrows
+
+UPDATE refresh_tokens
+SET
+    updated_at = NOW() AT TIME ZONE 'utc',
+    revoked_at = NOW() AT TIME ZONE 'utc'
+WHERE refresh_tokens.user_id = $1 AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.token NOT IN (
+    SELECT recent.token FROM refresh_tokens AS recent
+    WHERE recent.user_id = $1 AND recent.revoked_at IS NULL AND recent.expires_at > NOW() AT TIME ZONE 'utc'
+    ORDER BY recent.created_at DESC
+    LIMIT $2
+)
+`
+
+type RevokeExcessRefreshTokensParams struct {
+	UserID uuid.UUID
+	Keep   int32
+}
+
+// RevokeExcessRefreshTokens revokes the refresh tokens of a user but the
+// keep most recent active ones.
+func (q *Queries) RevokeExcessRefreshTokens(ctx context.Context, arg RevokeExcessRefreshTokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeExcessRefreshTokens, arg.UserID, arg.Keep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 
 UPDATE refresh_tokens
@@ -0,0 +1,168 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getLapsedSubscriptions = `-- name: GetLapsedSubscriptions :many
+
+SELECT id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at FROM subscriptions
+WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end <= $1
+ORDER BY current_period_end
+LIMIT $2
+FOR UPDATE SKIP LOCKED
+`
+
+type GetLapsedSubscriptionsParams struct {
+	Now       time.Time
+	BatchSize int32
+}
+
+// GetLapsedSubscriptions returns the subscriptions still granting Chirpy Red
+// whose period ended before now.
+func (q *Queries) GetLapsedSubscriptions(ctx context.Context, arg GetLapsedSubscriptionsParams) ([]Subscription, error) {
+	rows, err := q.db.QueryContext(ctx, getLapsedSubscriptions, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Plan,
+			&i.Status,
+			&i.CurrentPeriodStart,
+			&i.CurrentPeriodEnd,
+			&i.CanceledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscriptionByUserID = `-- name: GetSubscriptionByUserID :one
+SELECT id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionByUserID, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const syncUserChirpyRed = `-- name: SyncUserChirpyRed :one
+
+UPDATE users
+SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due', 'canceled')
+    AND subscriptions.current_period_end > NOW() AT TIME ZONE 'utc'
+), updated_at = NOW() AT TIME ZONE 'utc'
+WHERE users.id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id
+`
+
+// SyncUserChirpyRed derives is_chirpy_red from the subscription of the user.
+func (q *Queries) SyncUserChirpyRed(ctx context.Context, userID uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, syncUserChirpyRed, userID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+	)
+	return i, err
+}
+
+const upsertSubscription = `-- name: UpsertSubscription :one
+
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', $1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE SET
+    updated_at = EXCLUDED.updated_at,
+    plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = EXCLUDED.canceled_at
+RETURNING id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at
+`
+
+type UpsertSubscriptionParams struct {
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CanceledAt         sql.NullTime
+}
+
+func (q *Queries) UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, upsertSubscription,
+		arg.UserID,
+		arg.Plan,
+		arg.Status,
+		arg.CurrentPeriodStart,
+		arg.CurrentPeriodEnd,
+		arg.CanceledAt,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
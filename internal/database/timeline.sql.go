@@ -0,0 +1,226 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: timeline.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const backfillTimeline = `-- name: BackfillTimeline :exec
+
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT $1::uuid AS user_id, recent.id, recent.created_at
+FROM (
+    SELECT chirps.id, chirps.created_at FROM chirps
+    WHERE chirps.user_id = $2::uuid AND chirps.status = 'published' AND chirps.deleted_at IS NULL
+    ORDER BY chirps.created_at DESC
+    LIMIT $3::int
+) AS recent
+ON CONFLICT DO NOTHING
+`
+
+type BackfillTimelineParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	MaxChirps  int32
+}
+
+func (q *Queries) BackfillTimeline(ctx context.Context, arg BackfillTimelineParams) error {
+	_, err := q.db.ExecContext(ctx, backfillTimeline, arg.FollowerID, arg.FolloweeID, arg.MaxChirps)
+	return err
+}
+
+const fanOutChirp = `-- name: FanOutChirp :exec
+
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT recipients.user_id, $1::uuid AS chirp_id, $2::timestamp AS created_at
+FROM (
+    SELECT follows.follower_id AS user_id FROM follows
+    WHERE follows.followee_id = $3::uuid
+    UNION
+    SELECT $3::uuid AS user_id
+) AS recipients
+ON CONFLICT DO NOTHING
+`
+
+type FanOutChirpParams struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	AuthorID  uuid.UUID
+}
+
+func (q *Queries) FanOutChirp(ctx context.Context, arg FanOutChirpParams) error {
+	_, err := q.db.ExecContext(ctx, fanOutChirp, arg.ChirpID, arg.CreatedAt, arg.AuthorID)
+	return err
+}
+
+const getTimelineFanOutOnRead = `-- name: GetTimelineFanOutOnRead :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM chirps
+WHERE (
+    chirps.user_id = $1
+    OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1)
+)
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = $1 OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1 AND mutes.muted_id = chirps.user_id
+)
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type GetTimelineFanOutOnReadParams struct {
+	UserID          uuid.UUID
+	BeforeCreatedAt sql.NullTime
+	BeforeID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) GetTimelineFanOutOnRead(ctx context.Context, arg GetTimelineFanOutOnReadParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineFanOutOnRead,
+		arg.UserID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimelineFanOutOnWrite = `-- name: GetTimelineFanOutOnWrite :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM timeline_entries
+JOIN chirps ON chirps.id = timeline_entries.chirp_id
+WHERE timeline_entries.user_id = $1
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = $1 OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1 AND mutes.muted_id = chirps.user_id
+)
+AND (
+    $2::timestamp IS NULL
+    OR (timeline_entries.created_at, timeline_entries.chirp_id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+LIMIT $4
+`
+
+type GetTimelineFanOutOnWriteParams struct {
+	UserID          uuid.UUID
+	BeforeCreatedAt sql.NullTime
+	BeforeID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) GetTimelineFanOutOnWrite(ctx context.Context, arg GetTimelineFanOutOnWriteParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineFanOutOnWrite,
+		arg.UserID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeFromTimeline = `-- name: RemoveFromTimeline :exec
+
+DELETE FROM timeline_entries
+WHERE timeline_entries.user_id = $1
+AND timeline_entries.chirp_id IN (SELECT chirps.id FROM chirps WHERE chirps.user_id = $2)
+`
+
+type RemoveFromTimelineParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) RemoveFromTimeline(ctx context.Context, arg RemoveFromTimelineParams) error {
+	_, err := q.db.ExecContext(ctx, removeFromTimeline, arg.FollowerID, arg.FolloweeID)
+	return err
+}
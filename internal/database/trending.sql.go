@@ -0,0 +1,293 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: trending.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addTrendingItem = `-- name: AddTrendingItem :exec
+
+INSERT INTO trending_items (snapshot_id, kind, rank, tag, chirp_id, score)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type AddTrendingItemParams struct {
+	SnapshotID uuid.UUID
+	Kind       string
+	Rank       int32
+	Tag        sql.NullString
+	ChirpID    uuid.NullUUID
+	Score      float64
+}
+
+func (q *Queries) AddTrendingItem(ctx context.Context, arg AddTrendingItemParams) error {
+	_, err := q.db.ExecContext(ctx, addTrendingItem,
+		arg.SnapshotID,
+		arg.Kind,
+		arg.Rank,
+		arg.Tag,
+		arg.ChirpID,
+		arg.Score,
+	)
+	return err
+}
+
+const createTrendingSnapshot = `-- name: CreateTrendingSnapshot :one
+
+INSERT INTO trending_snapshots (id, time_window, computed_at)
+VALUES (gen_random_uuid(), $1, $2)
+RETURNING id, time_window, computed_at
+`
+
+type CreateTrendingSnapshotParams struct {
+	TimeWindow string
+	ComputedAt time.Time
+}
+
+func (q *Queries) CreateTrendingSnapshot(ctx context.Context, arg CreateTrendingSnapshotParams) (TrendingSnapshot, error) {
+	row := q.db.QueryRowContext(ctx, createTrendingSnapshot, arg.TimeWindow, arg.ComputedAt)
+	var i TrendingSnapshot
+	err := row.Scan(&i.ID, &i.TimeWindow, &i.ComputedAt)
+	return i, err
+}
+
+const getHashtagEventsSince = `-- name: GetHashtagEventsSince :many
+
+SELECT chirp_hashtags.tag, chirps.id AS chirp_id, chirps.created_at FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= $1::timestamp AND chirps.status = 'published'
+ORDER BY chirps.created_at ASC
+`
+
+type GetHashtagEventsSinceRow struct {
+	Tag       string
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) GetHashtagEventsSince(ctx context.Context, since time.Time) ([]GetHashtagEventsSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getHashtagEventsSince, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetHashtagEventsSinceRow
+	for rows.Next() {
+		var i GetHashtagEventsSinceRow
+		if err := rows.Scan(&i.Tag, &i.ChirpID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestTrendingSnapshot = `-- name: GetLatestTrendingSnapshot :one
+
+SELECT id, time_window, computed_at FROM trending_snapshots
+WHERE time_window = $1
+ORDER BY computed_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestTrendingSnapshot(ctx context.Context, timeWindow string) (TrendingSnapshot, error) {
+	row := q.db.QueryRowContext(ctx, getLatestTrendingSnapshot, timeWindow)
+	var i TrendingSnapshot
+	err := row.Scan(&i.ID, &i.TimeWindow, &i.ComputedAt)
+	return i, err
+}
+
+const getLikeEventsSince = `-- name: GetLikeEventsSince :many
+SELECT chirp_likes.chirp_id, chirp_likes.user_id, chirp_likes.created_at FROM chirp_likes
+WHERE chirp_likes.created_at >= $1::timestamp
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirp_likes.user_id AND users.state = 'shadow_banned'
+)
+ORDER BY chirp_likes.created_at ASC
+`
+
+// Likes of shadow-banned users don't count.
+func (q *Queries) GetLikeEventsSince(ctx context.Context, since time.Time) ([]ChirpLike, error) {
+	rows, err := q.db.QueryContext(ctx, getLikeEventsSince, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpLike
+	for rows.Next() {
+		var i ChirpLike
+		if err := rows.Scan(&i.ChirpID, &i.UserID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrendableChirpIDs = `-- name: GetTrendableChirpIDs :many
+
+SELECT chirps.id FROM chirps
+WHERE chirps.id = ANY($1::uuid[])
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+)
+`
+
+// GetTrendableChirpIDs returns the chirps among ids that can appear in
+// trends: published, neither deleted nor hidden, by an author who isn't
+// shadow-banned.
+func (q *Queries) GetTrendableChirpIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getTrendableChirpIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrendingChirps = `-- name: GetTrendingChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.status, chirps.publish_at, chirps.deleted_at, chirps.hidden_at, chirps.deleted_by_moderator FROM trending_items
+JOIN chirps ON chirps.id = trending_items.chirp_id
+WHERE trending_items.snapshot_id = $1 AND trending_items.kind = 'chirp'
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY trending_items.rank ASC
+`
+
+type GetTrendingChirpsParams struct {
+	SnapshotID uuid.UUID
+	ViewerID   uuid.UUID
+}
+
+// Chirps are filtered again, as they may have been hidden since the
+// snapshot, and for the blocks and mutes of the viewer.
+func (q *Queries) GetTrendingChirps(ctx context.Context, arg GetTrendingChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTrendingChirps, arg.SnapshotID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.DeletedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrendingHashtags = `-- name: GetTrendingHashtags :many
+
+SELECT tag::text AS tag, score FROM trending_items
+WHERE snapshot_id = $1 AND kind = 'hashtag'
+ORDER BY rank ASC
+`
+
+type GetTrendingHashtagsRow struct {
+	Tag   string
+	Score float64
+}
+
+func (q *Queries) GetTrendingHashtags(ctx context.Context, snapshotID uuid.UUID) ([]GetTrendingHashtagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTrendingHashtags, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTrendingHashtagsRow
+	for rows.Next() {
+		var i GetTrendingHashtagsRow
+		if err := rows.Scan(&i.Tag, &i.Score); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneTrendingSnapshots = `-- name: PruneTrendingSnapshots :exec
+
+DELETE FROM trending_snapshots
+WHERE computed_at < $1::timestamp
+`
+
+func (q *Queries) PruneTrendingSnapshots(ctx context.Context, computedBefore time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneTrendingSnapshots, computedBefore)
+	return err
+}
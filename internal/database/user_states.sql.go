@@ -0,0 +1,178 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: user_states.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createUserStateChange = `-- name: CreateUserStateChange :one
+
+INSERT INTO user_state_changes (id, created_at, user_id, changed_by, old_state, new_state, state_until, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING id, created_at, user_id, changed_by, old_state, new_state, state_until, reason
+`
+
+type CreateUserStateChangeParams struct {
+	UserID     uuid.UUID
+	ChangedBy  uuid.NullUUID
+	OldState   string
+	NewState   string
+	StateUntil sql.NullTime
+	Reason     string
+}
+
+func (q *Queries) CreateUserStateChange(ctx context.Context, arg CreateUserStateChangeParams) (UserStateChange, error) {
+	row := q.db.QueryRowContext(ctx, createUserStateChange,
+		arg.UserID,
+		arg.ChangedBy,
+		arg.OldState,
+		arg.NewState,
+		arg.StateUntil,
+		arg.Reason,
+	)
+	var i UserStateChange
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.ChangedBy,
+		&i.OldState,
+		&i.NewState,
+		&i.StateUntil,
+		&i.Reason,
+	)
+	return i, err
+}
+
+const getUserStateChanges = `-- name: GetUserStateChanges :many
+
+SELECT id, created_at, user_id, changed_by, old_state, new_state, state_until, reason FROM user_state_changes
+WHERE user_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetUserStateChanges(ctx context.Context, userID uuid.UUID) ([]UserStateChange, error) {
+	rows, err := q.db.QueryContext(ctx, getUserStateChanges, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserStateChange
+	for rows.Next() {
+		var i UserStateChange
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.ChangedBy,
+			&i.OldState,
+			&i.NewState,
+			&i.StateUntil,
+			&i.Reason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersWithExpiredState = `-- name: GetUsersWithExpiredState :many
+
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id FROM users
+WHERE state != 'active' AND state_until <= NOW() AT TIME ZONE 'utc'
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetUsersWithExpiredState(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersWithExpiredState)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.IsAdmin,
+			&i.State,
+			&i.StateUntil,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarMediaID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserState = `-- name: SetUserState :one
+UPDATE users
+SET state = $1, state_until = $2, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id
+`
+
+type SetUserStateParams struct {
+	State      string
+	StateUntil sql.NullTime
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserState(ctx context.Context, arg SetUserStateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserState, arg.State, arg.StateUntil, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+	)
+	return i, err
+}
@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW() AT TIME ZONE 'utc',
     NOW() AT TIME ZONE 'utc',
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +42,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
 	)
 	return i, err
 }
@@ -54,7 +65,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 
 const getUserByEmail = `-- name: GetUserByEmail :one
 
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id FROM users
 WHERE email = $1
 `
 
@@ -68,6 +79,156 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id FROM users
+WHERE LOWER(handle) = LOWER($1::text)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
+	)
+	return i, err
+}
+
+const getUserChirpCount = `-- name: GetUserChirpCount :one
+
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+`
+
+func (q *Queries) GetUserChirpCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getUserChirpCount, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getUserProfiles = `-- name: GetUserProfiles :many
+
+SELECT users.id, users.handle, users.display_name, media_files.storage_key AS avatar_key, media_files.thumbnail_key AS avatar_thumbnail_key
+FROM users
+LEFT JOIN media_files ON media_files.id = users.avatar_media_id
+WHERE users.id = ANY($1::uuid[])
+`
+
+type GetUserProfilesRow struct {
+	ID                 uuid.UUID
+	Handle             sql.NullString
+	DisplayName        string
+	AvatarKey          sql.NullString
+	AvatarThumbnailKey sql.NullString
+}
+
+func (q *Queries) GetUserProfiles(ctx context.Context, ids []uuid.UUID) ([]GetUserProfilesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserProfiles, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserProfilesRow
+	for rows.Next() {
+		var i GetUserProfilesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.AvatarKey,
+			&i.AvatarThumbnailKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUser = `-- name: LockUser :one
+
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+// LockUser returns a user like GetUserByID, locking them until the end of
+// the transaction, to serialize the changes of a user checked against their
+// limits.
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, lockUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
 	)
 	return i, err
 }
@@ -77,7 +238,7 @@ const updateUserCredentials = `-- name: UpdateUserCredentials :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = NOW() AT TIME ZONE 'utc'
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id
 `
 
 type UpdateUserCredentialsParams struct {
@@ -96,20 +257,41 @@ func (q *Queries) UpdateUserCredentials(ctx context.Context, arg UpdateUserCrede
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
 	)
 	return i, err
 }
 
-const upgradeUserToRed = `-- name: UpgradeUserToRed :one
+const updateUserProfile = `-- name: UpdateUserProfile :one
 
 UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW() AT TIME ZONE 'utc'
-WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET handle = $1, display_name = $2, bio = $3, avatar_media_id = $4, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, state, state_until, handle, display_name, bio, avatar_media_id
 `
 
-func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUserToRed, id)
+type UpdateUserProfileParams struct {
+	Handle        sql.NullString
+	DisplayName   string
+	Bio           string
+	AvatarMediaID uuid.NullUUID
+	ID            uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarMediaID,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -118,6 +300,13 @@ func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.State,
+		&i.StateUntil,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarMediaID,
 	)
 	return i, err
 }
@@ -0,0 +1,432 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+
+WITH due AS (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    WHERE webhook_deliveries.status = 'pending'
+    AND webhook_deliveries.next_attempt_at <= NOW() AT TIME ZONE 'utc'
+    ORDER BY webhook_deliveries.next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = $1
+FROM due
+WHERE webhook_deliveries.id = due.id
+RETURNING webhook_deliveries.id, webhook_deliveries.created_at, webhook_deliveries.webhook_id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	BatchSize  int32
+}
+
+// ClaimWebhookDeliveries leases the due deliveries until lease_until, so
+// that other server instances skip them while they are sent.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countWebhooksByUserID = `-- name: CountWebhooksByUserID :one
+
+SELECT COUNT(*) FROM webhooks
+WHERE user_id = $1
+`
+
+func (q *Queries) CountWebhooksByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countWebhooksByUserID, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createEventWebhookDeliveries = `-- name: CreateEventWebhookDeliveries :exec
+
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), NOW() AT TIME ZONE 'utc', webhooks.id, $1::uuid, $2::text, $3::jsonb,
+    NOW() AT TIME ZONE 'utc'
+FROM webhooks
+JOIN users ON users.id = webhooks.user_id
+WHERE ((webhooks.global AND users.is_admin) OR webhooks.user_id = $4::uuid)
+AND (cardinality(webhooks.event_types) = 0 OR $2::text = ANY(webhooks.event_types))
+ON CONFLICT (webhook_id, event_id) DO NOTHING
+`
+
+type CreateEventWebhookDeliveriesParams struct {
+	EventID   uuid.UUID
+	EventType string
+	Payload   json.RawMessage
+	SubjectID uuid.UUID
+}
+
+// CreateEventWebhookDeliveries creates a delivery of an outbox event for
+// every endpoint that subscribed to it. Global endpoints only get the events
+// of every user while their owner is still an admin. Running it again for
+// the same event is a no-op.
+func (q *Queries) CreateEventWebhookDeliveries(ctx context.Context, arg CreateEventWebhookDeliveriesParams) error {
+	_, err := q.db.ExecContext(ctx, createEventWebhookDeliveries,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.SubjectID,
+	)
+	return err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, url, secret, event_types, global)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, $5::text[], $4)
+RETURNING id, created_at, user_id, url, secret, event_types, global
+`
+
+type CreateWebhookParams struct {
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	Global     bool
+	EventTypes []string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.Global,
+		pq.Array(arg.EventTypes),
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Global,
+	)
+	return i, err
+}
+
+const createWebhookAttempt = `-- name: CreateWebhookAttempt :exec
+
+INSERT INTO webhook_attempts (id, created_at, delivery_id, status_code, error, response_body, duration_ms)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, $4, $5)
+`
+
+type CreateWebhookAttemptParams struct {
+	DeliveryID   uuid.UUID
+	StatusCode   sql.NullInt32
+	Error        sql.NullString
+	ResponseBody string
+	DurationMs   int32
+}
+
+func (q *Queries) CreateWebhookAttempt(ctx context.Context, arg CreateWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookAttempt,
+		arg.DeliveryID,
+		arg.StatusCode,
+		arg.Error,
+		arg.ResponseBody,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_type, payload, attempts, next_attempt_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, 1, $4)
+RETURNING id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID  uuid.UUID
+	EventType  string
+	Payload    json.RawMessage
+	LeaseUntil time.Time
+}
+
+// CreateWebhookDelivery creates a delivery claimed for its first attempt,
+// to send it right away.
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.EventType,
+		arg.Payload,
+		arg.LeaseUntil,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :exec
+
+DELETE FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	return err
+}
+
+const getWebhookAttempts = `-- name: GetWebhookAttempts :many
+
+SELECT id, created_at, delivery_id, status_code, error, response_body, duration_ms FROM webhook_attempts
+WHERE delivery_id = ANY($1::uuid[])
+ORDER BY created_at
+`
+
+func (q *Queries) GetWebhookAttempts(ctx context.Context, deliveryIds []uuid.UUID) ([]WebhookAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookAttempts, pq.Array(deliveryIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookAttempt
+	for rows.Next() {
+		var i WebhookAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.DeliveryID,
+			&i.StatusCode,
+			&i.Error,
+			&i.ResponseBody,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookByID = `-- name: GetWebhookByID :one
+
+SELECT id, created_at, user_id, url, secret, event_types, global FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookByID, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Global,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+
+SELECT id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Limit     int32
+	Offset    int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksByUserID = `-- name: GetWebhooksByUserID :many
+
+SELECT id, created_at, user_id, url, secret, event_types, global FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetWebhooksByUserID(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.EventTypes),
+			&i.Global,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :one
+
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND webhook_id = $2 AND status = 'failed'
+RETURNING id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at
+`
+
+type RetryWebhookDeliveryParams struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+}
+
+// RetryWebhookDelivery moves a dead letter back to the pending deliveries,
+// with a fresh set of attempts.
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, retryWebhookDelivery, arg.ID, arg.WebhookID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
+
+const setWebhookDeliveryStatus = `-- name: SetWebhookDeliveryStatus :one
+
+UPDATE webhook_deliveries SET status = $2, next_attempt_at = $3
+WHERE id = $1
+RETURNING id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at
+`
+
+type SetWebhookDeliveryStatusParams struct {
+	ID            uuid.UUID
+	Status        string
+	NextAttemptAt time.Time
+}
+
+func (q *Queries) SetWebhookDeliveryStatus(ctx context.Context, arg SetWebhookDeliveryStatusParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, setWebhookDeliveryStatus, arg.ID, arg.Status, arg.NextAttemptAt)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+	)
+	return i, err
+}
@@ -0,0 +1,185 @@
+// Package entitlements maps the tier of a user to the limits and features
+// they are entitled to.
+//
+// Tiers are defined in a Config, which starts from the defaults of Default
+// and can be tuned with a JSON document giving, for each tier, the limits
+// to override:
+//
+//	{
+//		"free": {"chirps_per_hour": 50},
+//		"red": {"chirp_max_length": 500, "undo_window": "1h"}
+//	}
+package entitlements
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Tier string
+
+const (
+	TierFree Tier = "free"
+	// TierRed is the tier of Chirpy Red subscribers.
+	TierRed Tier = "red"
+)
+
+// Tiers lists every tier, from the lowest.
+var Tiers = []Tier{TierFree, TierRed}
+
+// TierOf returns the tier of a user, given whether they have Chirpy Red.
+func TierOf(chirpyRed bool) Tier {
+	if chirpyRed {
+		return TierRed
+	}
+	return TierFree
+}
+
+// Limits are what a tier is entitled to. Zero rate limits mean unlimited.
+type Limits struct {
+	// ChirpMaxLength is the maximum length of chirps, in user-perceived
+	// characters.
+	ChirpMaxLength int `json:"chirp_max_length"`
+	// UndoWindow is how long a deleted chirp can be restored by its author.
+	UndoWindow Duration `json:"undo_window"`
+	// MaxMediaPerChirp is how many media can be attached to a chirp.
+	MaxMediaPerChirp int `json:"max_media_per_chirp"`
+	// MaxPins is how many chirps can be pinned to the profile.
+	MaxPins int `json:"max_pins"`
+	// ChirpsPerHour is how many chirps can be created in an hour, drafts
+	// and scheduled chirps included.
+	ChirpsPerHour int `json:"chirps_per_hour"`
+	// MaxSessions is how many refresh tokens can be active at once. Logging
+	// in again revokes the oldest ones.
+	MaxSessions int `json:"max_sessions"`
+	// MaxWebhooks is how many webhooks can be registered.
+	MaxWebhooks int `json:"max_webhooks"`
+}
+
+func (l Limits) validate() error {
+	switch {
+	case l.ChirpMaxLength < 1:
+		return errors.New("chirp_max_length must be positive")
+	case l.UndoWindow < 0:
+		return errors.New("undo_window can't be negative")
+	case l.MaxMediaPerChirp < 0, l.MaxPins < 0, l.MaxWebhooks < 0:
+		return errors.New("max_media_per_chirp, max_pins and max_webhooks can't be negative")
+	case l.ChirpsPerHour < 0:
+		return errors.New("chirps_per_hour can't be negative")
+	case l.MaxSessions < 1:
+		return errors.New("max_sessions must be positive")
+	}
+	return nil
+}
+
+// Config holds the limits of every tier.
+type Config struct {
+	tiers map[Tier]Limits
+}
+
+// Default returns the default tier definitions.
+func Default() *Config {
+	return &Config{tiers: map[Tier]Limits{
+		TierFree: {
+			ChirpMaxLength:   140,
+			UndoWindow:       Duration(5 * time.Minute),
+			MaxMediaPerChirp: 4,
+			MaxPins:          1,
+			ChirpsPerHour:    100,
+			MaxSessions:      5,
+			MaxWebhooks:      10,
+		},
+		TierRed: {
+			ChirpMaxLength:   1000,
+			UndoWindow:       Duration(time.Hour),
+			MaxMediaPerChirp: 10,
+			MaxPins:          3,
+			ChirpsPerHour:    500,
+			MaxSessions:      20,
+			MaxWebhooks:      50,
+		},
+	}}
+}
+
+// Load returns the default tier definitions overridden by the JSON document
+// read from r. Limits missing from the document keep their default.
+func Load(r io.Reader) (*Config, error) {
+	c := Default()
+	var doc map[Tier]json.RawMessage
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("unable to decode entitlements: %w", err)
+	}
+	for tier, raw := range doc {
+		limits, ok := c.tiers[tier]
+		if !ok {
+			return nil, fmt.Errorf("unknown tier '%s'", tier)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&limits); err != nil {
+			return nil, fmt.Errorf("invalid limits for tier '%s': %w", tier, err)
+		}
+		if err := limits.validate(); err != nil {
+			return nil, fmt.Errorf("invalid limits for tier '%s': %w", tier, err)
+		}
+		c.tiers[tier] = limits
+	}
+	return c, nil
+}
+
+// For returns the limits of tier. Unknown tiers get the limits of TierFree.
+func (c *Config) For(tier Tier) Limits {
+	if l, ok := c.tiers[tier]; ok {
+		return l
+	}
+	return c.tiers[TierFree]
+}
+
+// SetUndoWindow overrides the undo window of tier.
+func (c *Config) SetUndoWindow(tier Tier, d time.Duration) error {
+	limits, ok := c.tiers[tier]
+	if !ok {
+		return fmt.Errorf("unknown tier '%s'", tier)
+	}
+	limits.UndoWindow = Duration(d)
+	if err := limits.validate(); err != nil {
+		return fmt.Errorf("invalid limits for tier '%s': %w", tier, err)
+	}
+	c.tiers[tier] = limits
+	return nil
+}
+
+// MaxUndoWindow returns the longest undo window of all tiers.
+func (c *Config) MaxUndoWindow() time.Duration {
+	var d Duration
+	for _, l := range c.tiers {
+		d = max(d, l.UndoWindow)
+	}
+	return time.Duration(d)
+}
+
+// Duration is a time.Duration written as a string like "90s" or "1h" in
+// JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,119 @@
+package entitlements
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name    string
+		doc     string
+		tier    Tier
+		want    func(Limits) Limits
+		wantErr bool
+	}{
+		{
+			name: "empty document keeps defaults",
+			doc:  `{}`,
+			tier: TierRed,
+			want: func(l Limits) Limits { return l },
+		},
+		{
+			name: "overrides one limit",
+			doc:  `{"red": {"chirp_max_length": 500, "undo_window": "90s"}}`,
+			tier: TierRed,
+			want: func(l Limits) Limits {
+				l.ChirpMaxLength = 500
+				l.UndoWindow = Duration(90 * time.Second)
+				return l
+			},
+		},
+		{
+			name: "other tiers keep defaults",
+			doc:  `{"red": {"chirp_max_length": 500}}`,
+			tier: TierFree,
+			want: func(l Limits) Limits { return l },
+		},
+		{
+			name: "zero rate limit",
+			doc:  `{"free": {"chirps_per_hour": 0}}`,
+			tier: TierFree,
+			want: func(l Limits) Limits {
+				l.ChirpsPerHour = 0
+				return l
+			},
+		},
+		{name: "unknown tier", doc: `{"gold": {}}`, wantErr: true},
+		{name: "unknown limit", doc: `{"free": {"chirp_length": 10}}`, wantErr: true},
+		{name: "invalid duration", doc: `{"free": {"undo_window": 300}}`, wantErr: true},
+		{name: "invalid length", doc: `{"free": {"chirp_max_length": 0}}`, wantErr: true},
+		{name: "negative limit", doc: `{"red": {"max_pins": -1}}`, wantErr: true},
+		{name: "no session", doc: `{"red": {"max_sessions": 0}}`, wantErr: true},
+		{name: "not JSON", doc: `free: {}`, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Load(strings.NewReader(c.doc))
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := c.want(Default().For(c.tier))
+			if got.For(c.tier) != want {
+				t.Errorf("want %+v, got %+v", want, got.For(c.tier))
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	c := Default()
+	if c.For(TierOf(true)) != c.For(TierRed) {
+		t.Error("Chirpy Red users should get the red tier")
+	}
+	if c.For(TierOf(false)) != c.For(TierFree) {
+		t.Error("other users should get the free tier")
+	}
+	if c.For("gold") != c.For(TierFree) {
+		t.Error("unknown tiers should get the free tier")
+	}
+	if c.For(TierRed).ChirpMaxLength <= c.For(TierFree).ChirpMaxLength {
+		t.Error("red chirps should be longer")
+	}
+}
+
+func TestMaxUndoWindow(t *testing.T) {
+	c, err := Load(strings.NewReader(`{"free": {"undo_window": "2h"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.MaxUndoWindow(); got != 2*time.Hour {
+		t.Errorf("want 2h, got %v", got)
+	}
+}
+
+func TestSetUndoWindow(t *testing.T) {
+	c := Default()
+	if err := c.SetUndoWindow(TierFree, 3*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(c.For(TierFree).UndoWindow); got != 3*time.Hour {
+		t.Errorf("want 3h, got %v", got)
+	}
+	if got := c.MaxUndoWindow(); got != 3*time.Hour {
+		t.Errorf("want a longest undo window of 3h, got %v", got)
+	}
+	if err := c.SetUndoWindow(TierFree, -time.Minute); err == nil {
+		t.Error("want an error for a negative window")
+	}
+	if err := c.SetUndoWindow("gold", time.Minute); err == nil {
+		t.Error("want an error for an unknown tier")
+	}
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+)
+
+const (
+	// BatchSize bounds how many events or deliveries are read at once.
+	BatchSize = 100
+	// MaxAttempts is how many times a delivery is tried before it is marked
+	// as failed.
+	MaxAttempts = 8
+	// Lease is how long a claimed delivery is hidden from the other server
+	// instances. A delivery still unfinished by then is tried again.
+	Lease = 5 * time.Minute
+
+	baseBackoff = 5 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Handler handles an event for a subscriber. Returning an error schedules
+// another attempt.
+type Handler func(ctx context.Context, r Record) error
+
+type subscriber struct {
+	types   []string
+	handler Handler
+}
+
+// Bus dispatches the events of the outbox to subscribers. Subscribers must be
+// registered before the first call to Dispatch.
+type Bus struct {
+	subscribers map[string]subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[string]subscriber{}}
+}
+
+// Subscribe registers handler for the events of the given types. The name
+// identifies the subscriber in the outbox: it must be unique, and stay the
+// same across restarts for pending deliveries to be resumed.
+func (b *Bus) Subscribe(name string, handler Handler, types ...string) {
+	if _, ok := b.subscribers[name]; ok {
+		panic(fmt.Sprintf("events: duplicate subscriber '%s'", name))
+	}
+	b.subscribers[name] = subscriber{types: types, handler: handler}
+}
+
+// Backoff returns how long to wait before trying a delivery again after its
+// attempt-th attempt failed.
+func Backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// subscriptions returns the event types of the subscribers in the form
+// expected by ExpandOutboxEvents.
+func (b *Bus) subscriptions() (json.RawMessage, error) {
+	type subscription struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	subs := []subscription{}
+	for name, s := range b.subscribers {
+		for _, t := range s.types {
+			subs = append(subs, subscription{Type: t, Name: name})
+		}
+	}
+	return json.Marshal(subs)
+}
+
+// Dispatch creates the deliveries of the new events, then runs the due
+// ones. Rows are claimed with SKIP LOCKED and leased, so several server
+// instances can dispatch concurrently.
+func (b *Bus) Dispatch(ctx context.Context, q *database.Queries) error {
+	subs, err := b.subscriptions()
+	if err != nil {
+		return err
+	}
+	for {
+		expanded, err := q.ExpandOutboxEvents(ctx, database.ExpandOutboxEventsParams{
+			BatchSize:   BatchSize,
+			Subscribers: subs,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to expand events: %w", err)
+		}
+		if len(expanded) < BatchSize {
+			break
+		}
+	}
+	for {
+		deliveries, err := q.ClaimOutboxDeliveries(ctx, database.ClaimOutboxDeliveriesParams{
+			BatchSize:  BatchSize,
+			LeaseUntil: time.Now().UTC().Add(Lease),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to claim deliveries: %w", err)
+		}
+		for _, d := range deliveries {
+			if err := b.deliver(ctx, q, d); err != nil {
+				return err
+			}
+		}
+		if len(deliveries) < BatchSize {
+			return nil
+		}
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, q *database.Queries, d database.ClaimOutboxDeliveriesRow) error {
+	var err error
+	if s, ok := b.subscribers[d.Subscriber]; ok {
+		err = s.handler(ctx, Record{
+			ID:        d.EventID,
+			Type:      d.Type,
+			CreatedAt: d.CreatedAt,
+			Payload:   d.Payload,
+			Attempt:   int(d.Attempts),
+		})
+	} else {
+		err = fmt.Errorf("unknown subscriber '%s'", d.Subscriber)
+	}
+	if err == nil {
+		return q.MarkOutboxDelivered(ctx, database.MarkOutboxDeliveredParams{
+			EventID:    d.EventID,
+			Subscriber: d.Subscriber,
+		})
+	}
+	log.Printf("subscriber '%s' failed to handle event '%s' (attempt %d): %v", d.Subscriber, d.EventID, d.Attempts, err)
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	if d.Attempts >= MaxAttempts {
+		return q.FailOutboxDelivery(ctx, database.FailOutboxDeliveryParams{
+			EventID:    d.EventID,
+			Subscriber: d.Subscriber,
+			LastError:  lastError,
+		})
+	}
+	return q.RetryOutboxDelivery(ctx, database.RetryOutboxDeliveryParams{
+		EventID:       d.EventID,
+		Subscriber:    d.Subscriber,
+		NextAttemptAt: time.Now().UTC().Add(Backoff(int(d.Attempts))),
+		LastError:     lastError,
+	})
+}
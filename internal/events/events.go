@@ -0,0 +1,172 @@
+// Package events is the domain event bus. Events are written to an outbox
+// table in the transaction of the change they describe, so that they are
+// recorded if and only if the change is committed, then dispatched to the
+// in-process subscribers by a background job.
+//
+// Delivery is at least once: a subscriber whose handler fails is retried
+// with an exponential backoff, and may see an event again if the server
+// stops while handling it. Handlers must be idempotent.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	TypeChirpCreated   = "chirp.created"
+	TypeChirpDeleted   = "chirp.deleted"
+	TypeChirpRestored  = "chirp.restored"
+	TypeChirpHidden    = "chirp.hidden"
+	TypeChirpLiked     = "chirp.liked"
+	TypeUserCreated    = "user.created"
+	TypeUserFollowed   = "user.followed"
+	TypeUserUpgraded   = "user.upgraded"
+	TypeUserDowngraded = "user.downgraded"
+)
+
+// Types lists every event type, for subscribers interested in all of them.
+var Types = []string{
+	TypeChirpCreated, TypeChirpDeleted, TypeChirpRestored, TypeChirpHidden, TypeChirpLiked,
+	TypeUserCreated, TypeUserFollowed, TypeUserUpgraded, TypeUserDowngraded,
+}
+
+// Event is a domain event. Its JSON encoding is the payload stored in the
+// outbox.
+type Event interface {
+	EventType() string
+}
+
+// ChirpCreated is published when a chirp is published, either right away or
+// when its scheduled time comes.
+type ChirpCreated struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpCreated) EventType() string { return TypeChirpCreated }
+
+// ChirpDeleted is published when a published chirp is deleted, by its author
+// or a moderator.
+type ChirpDeleted struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpDeleted) EventType() string { return TypeChirpDeleted }
+
+// ChirpRestored is published when the author of a deleted chirp restores it,
+// within their undo window.
+type ChirpRestored struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpRestored) EventType() string { return TypeChirpRestored }
+
+// ChirpHidden is published when a moderator hides a chirp, or when it is
+// hidden after being reported too many times.
+type ChirpHidden struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpHidden) EventType() string { return TypeChirpHidden }
+
+// ChirpLiked is published when a user likes a chirp, the first time only.
+type ChirpLiked struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (ChirpLiked) EventType() string { return TypeChirpLiked }
+
+// UserCreated is published when a user signs up.
+type UserCreated struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (UserCreated) EventType() string { return TypeUserCreated }
+
+// UserFollowed is published when a user starts following another.
+type UserFollowed struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+func (UserFollowed) EventType() string { return TypeUserFollowed }
+
+// UserUpgraded is published when a user gets Chirpy Red.
+type UserUpgraded struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (UserUpgraded) EventType() string { return TypeUserUpgraded }
+
+// UserDowngraded is published when a user loses Chirpy Red, once their
+// subscription expired or was refunded.
+type UserDowngraded struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (UserDowngraded) EventType() string { return TypeUserDowngraded }
+
+// Record is an event read back from the outbox, for a given subscriber.
+type Record struct {
+	ID        uuid.UUID
+	Type      string
+	CreatedAt time.Time
+	Payload   json.RawMessage
+	// Attempt is 1 the first time the record is handled by a subscriber.
+	Attempt int
+}
+
+// Decode returns the typed event of r.
+func (r Record) Decode() (Event, error) {
+	var e Event
+	switch r.Type {
+	case TypeChirpCreated:
+		e = &ChirpCreated{}
+	case TypeChirpDeleted:
+		e = &ChirpDeleted{}
+	case TypeChirpRestored:
+		e = &ChirpRestored{}
+	case TypeChirpHidden:
+		e = &ChirpHidden{}
+	case TypeChirpLiked:
+		e = &ChirpLiked{}
+	case TypeUserCreated:
+		e = &UserCreated{}
+	case TypeUserFollowed:
+		e = &UserFollowed{}
+	case TypeUserUpgraded:
+		e = &UserUpgraded{}
+	case TypeUserDowngraded:
+		e = &UserDowngraded{}
+	default:
+		return nil, fmt.Errorf("unknown event type '%s'", r.Type)
+	}
+	if err := json.Unmarshal(r.Payload, e); err != nil {
+		return nil, fmt.Errorf("unable to decode %s event: %w", r.Type, err)
+	}
+	return e, nil
+}
+
+// Publish writes e to the outbox with q. Pass the queries of the transaction
+// making the change e describes.
+func Publish(ctx context.Context, q *database.Queries, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		Type:    e.EventType(),
+		Payload: payload,
+	})
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 5 * time.Second},
+		{attempt: 2, want: 10 * time.Second},
+		{attempt: 4, want: 40 * time.Second},
+		{attempt: 10, want: 2560 * time.Second},
+		{attempt: 11, want: time.Hour},
+		{attempt: 100, want: time.Hour},
+	}
+	for _, c := range cases {
+		if got := Backoff(c.attempt); got != c.want {
+			t.Errorf("Backoff(%d): want %v, got %v", c.attempt, c.want, got)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	events := []Event{
+		&ChirpCreated{ChirpID: uuid.New(), AuthorID: uuid.New()},
+		&ChirpDeleted{ChirpID: uuid.New(), AuthorID: uuid.New()},
+		&ChirpRestored{ChirpID: uuid.New(), AuthorID: uuid.New()},
+		&ChirpHidden{ChirpID: uuid.New(), AuthorID: uuid.New()},
+		&ChirpLiked{ChirpID: uuid.New(), AuthorID: uuid.New(), UserID: uuid.New()},
+		&UserCreated{UserID: uuid.New()},
+		&UserFollowed{FollowerID: uuid.New(), FolloweeID: uuid.New()},
+		&UserUpgraded{UserID: uuid.New()},
+		&UserDowngraded{UserID: uuid.New()},
+	}
+	for _, e := range events {
+		t.Run(e.EventType(), func(t *testing.T) {
+			payload, err := json.Marshal(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Record{Type: e.EventType(), Payload: payload}.Decode()
+			if err != nil {
+				t.Fatalf("unable to decode: %v", err)
+			}
+			if gotJSON, _ := json.Marshal(got); string(gotJSON) != string(payload) {
+				t.Errorf("want %s, got %s", payload, gotJSON)
+			}
+		})
+	}
+	if _, err := (Record{Type: "chirp.exploded", Payload: []byte("{}")}).Decode(); err == nil {
+		t.Error("want error for unknown event type")
+	}
+	if _, err := (Record{Type: TypeUserCreated, Payload: []byte("[")}).Decode(); err == nil {
+		t.Error("want error for invalid payload")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	b := NewBus()
+	noop := func(context.Context, Record) error { return nil }
+	b.Subscribe("audit", noop, Types...)
+	b.Subscribe("mentions", noop, TypeChirpCreated)
+	subs, err := b.subscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []struct{ Type, Name string }
+	if err := json.Unmarshal(subs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(Types)+1 {
+		t.Errorf("want %d subscriptions, got %v", len(Types)+1, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic on duplicate subscriber")
+		}
+	}()
+	b.Subscribe("audit", noop, TypeUserCreated)
+}
+
+// TestDispatch checks that failed deliveries are retried and that other
+// subscribers aren't affected. It needs a migrated database, given by
+// CHIRPY_TEST_DB_URL.
+func TestDispatch(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("unable to open the database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	q := database.New(db)
+
+	userID := uuid.New()
+	calls := map[string]int{}
+	handler := func(name string, fail bool) Handler {
+		return func(ctx context.Context, r Record) error {
+			e, err := r.Decode()
+			if err != nil {
+				return err
+			}
+			if e.(*UserCreated).UserID != userID {
+				return nil
+			}
+			calls[name]++
+			if fail && r.Attempt == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		}
+	}
+	suffix := uuid.NewString()
+	b := NewBus()
+	b.Subscribe("steady-"+suffix, handler("steady", false), TypeUserCreated)
+	b.Subscribe("flaky-"+suffix, handler("flaky", true), TypeUserCreated)
+	defer func() {
+		if _, err := db.ExecContext(ctx, "DELETE FROM outbox_events WHERE payload->>'user_id' = $1", userID.String()); err != nil {
+			t.Errorf("unable to clean up events: %v", err)
+		}
+	}()
+
+	if err := Publish(ctx, q, UserCreated{UserID: userID}); err != nil {
+		t.Fatalf("unable to publish: %v", err)
+	}
+	if err := b.Dispatch(ctx, q); err != nil {
+		t.Fatalf("unable to dispatch: %v", err)
+	}
+	if calls["steady"] != 1 || calls["flaky"] != 1 {
+		t.Fatalf("want one call each, got %v", calls)
+	}
+	// Make the retry due now rather than after the backoff
+	if _, err := db.ExecContext(ctx, "UPDATE outbox_deliveries SET next_attempt_at = NOW() AT TIME ZONE 'utc' WHERE subscriber = $1", "flaky-"+suffix); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Dispatch(ctx, q); err != nil {
+		t.Fatalf("unable to dispatch: %v", err)
+	}
+	if calls["steady"] != 1 || calls["flaky"] != 2 {
+		t.Fatalf("want the flaky subscriber retried once, got %v", calls)
+	}
+}
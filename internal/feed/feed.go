@@ -0,0 +1,315 @@
+// Package feed renders syndication feeds of chirps in the Atom, RSS 2.0 and
+// JSON Feed 1.1 formats, and answers the conditional requests feed readers
+// poll them with.
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+)
+
+type Format string
+
+const (
+	Atom Format = "atom"
+	RSS  Format = "rss"
+	JSON Format = "json"
+)
+
+const (
+	jsonVersion = "https://jsonfeed.org/version/1.1"
+
+	// maxTitleLen is the length in runes of the titles made from the text
+	// of items, which chirps don't have.
+	maxTitleLen = 80
+)
+
+// Feed is a feed, whatever its format.
+type Feed struct {
+	// ID is the permanent URI of the feed, whatever its format.
+	ID          string
+	Title       string
+	Description string
+	// Link is the URL of the page the feed follows, and FeedURL the URL of
+	// the feed itself.
+	Link    string
+	FeedURL string
+	Author  string
+	Updated time.Time
+	// Items are listed newest first.
+	Items []Item
+}
+
+// Item is an entry of a feed.
+type Item struct {
+	ID  string
+	URL string
+	// Title is made from Text when empty.
+	Title string
+	// Text is the plain text of the item, which is escaped to render its
+	// HTML content.
+	Text      string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+// Render returns the feed in a format, and its content type.
+func (f *Feed) Render(format Format) ([]byte, string, error) {
+	switch format {
+	case Atom:
+		body, err := f.atom()
+		return body, "application/atom+xml; charset=utf-8", err
+	case RSS:
+		body, err := f.rss()
+		return body, "application/rss+xml; charset=utf-8", err
+	case JSON:
+		body, err := f.json()
+		return body, "application/feed+json; charset=utf-8", err
+	default:
+		return nil, "", fmt.Errorf("unknown feed format '%s'", format)
+	}
+}
+
+func (it Item) title() string {
+	if it.Title != "" {
+		return it.Title
+	}
+	title, _, _ := strings.Cut(strings.TrimSpace(it.Text), "\n")
+	if utf8.RuneCountInString(title) <= maxTitleLen {
+		return title
+	}
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:maxTitleLen-1])) + "…"
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomPerson `xml:"author"`
+	Content   atomText    `xml:"content"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (f *Feed) atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.FeedURL},
+			{Rel: "alternate", Href: f.Link},
+		},
+	}
+	if f.Author != "" {
+		doc.Author = &atomPerson{Name: f.Author}
+	}
+	for _, it := range f.Items {
+		entry := atomEntry{
+			ID:        it.ID,
+			Title:     it.title(),
+			Updated:   it.Updated.UTC().Format(time.RFC3339),
+			Published: it.Published.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: it.URL}},
+			Content:   atomText{Type: "html", Body: chirptext.HTML(it.Text)},
+		}
+		if it.Author != "" {
+			entry.Author = &atomPerson{Name: it.Author}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXML(doc)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Description string  `xml:"description"`
+	Creator     string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (f *Feed) rss() ([]byte, error) {
+	description := f.Description
+	if description == "" {
+		description = f.Title
+	}
+	doc := rssDoc{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.FeedURL},
+		},
+	}
+	for _, it := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       it.title(),
+			Link:        it.URL,
+			GUID:        rssGUID{IsPermaLink: it.ID == it.URL, Value: it.ID},
+			Description: chirptext.HTML(it.Text),
+			Creator:     it.Author,
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url"`
+	FeedURL     string       `json:"feed_url"`
+	Description string       `json:"description,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonItem has no title, as recommended for microblogs.
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	ContentHTML   string       `json:"content_html"`
+	ContentText   string       `json:"content_text"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+}
+
+func (f *Feed) json() ([]byte, error) {
+	doc := jsonFeed{
+		Version:     jsonVersion,
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	if f.Author != "" {
+		doc.Authors = []jsonAuthor{{Name: f.Author}}
+	}
+	for _, it := range f.Items {
+		item := jsonItem{
+			ID:            it.ID,
+			URL:           it.URL,
+			ContentHTML:   chirptext.HTML(it.Text),
+			ContentText:   it.Text,
+			DatePublished: it.Published.UTC().Format(time.RFC3339),
+			DateModified:  it.Updated.UTC().Format(time.RFC3339),
+		}
+		if it.Author != "" {
+			item.Authors = []jsonAuthor{{Name: it.Author}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// ETag returns a strong entity tag for a rendered feed.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified reports whether a GET request can be answered with 304 Not
+// Modified, given the entity tag of the feed and when it last changed. As in
+// RFC 9110, If-None-Match takes precedence over If-Modified-Since, which is
+// only compared with a precision of a second.
+func NotModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	return NotModifiedSince(r, modified)
+}
+
+// NotModifiedSince is NotModified for requests without If-None-Match, which
+// can be answered before the feed is rendered, from when it last changed.
+func NotModifiedSince(r *http.Request, modified time.Time) bool {
+	if r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
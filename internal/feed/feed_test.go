@@ -0,0 +1,181 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	published := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	return &Feed{
+		ID:      "https://chirpy.example/users/1/feed",
+		Title:   "Alice on Chirpy",
+		Link:    "https://chirpy.example/users/1",
+		FeedURL: "https://chirpy.example/users/1/feed.atom",
+		Author:  "Alice",
+		Updated: published.Add(time.Hour),
+		Items: []Item{
+			{
+				ID:        "https://chirpy.example/chirps/2",
+				URL:       "https://chirpy.example/chirps/2",
+				Text:      "<script>alert(\"pwned\")</script> & ]]> friends\nsecond line",
+				Author:    "Alice",
+				Published: published,
+				Updated:   published.Add(time.Hour),
+			},
+			{
+				ID:        "https://chirpy.example/chirps/1",
+				URL:       "https://chirpy.example/chirps/1",
+				Text:      "hello",
+				Published: published.Add(-time.Hour),
+				Updated:   published.Add(-time.Hour),
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	f := testFeed()
+	wantHTML := "<p>&lt;script&gt;alert(&#34;pwned&#34;)&lt;/script&gt; &amp; ]]&gt; friends<br>second line</p>"
+
+	cases := []struct {
+		format      Format
+		contentType string
+		// content decodes the feed and returns the HTML content of its items.
+		content func(body []byte) ([]string, error)
+	}{
+		{
+			format:      Atom,
+			contentType: "application/atom+xml; charset=utf-8",
+			content: func(body []byte) ([]string, error) {
+				var doc atomFeed
+				err := xml.Unmarshal(body, &doc)
+				var content []string
+				for _, e := range doc.Entries {
+					content = append(content, e.Content.Body)
+				}
+				return content, err
+			},
+		},
+		{
+			format:      RSS,
+			contentType: "application/rss+xml; charset=utf-8",
+			content: func(body []byte) ([]string, error) {
+				var doc rssDoc
+				err := xml.Unmarshal(body, &doc)
+				var content []string
+				for _, it := range doc.Channel.Items {
+					content = append(content, it.Description)
+				}
+				return content, err
+			},
+		},
+		{
+			format:      JSON,
+			contentType: "application/feed+json; charset=utf-8",
+			content: func(body []byte) ([]string, error) {
+				var doc jsonFeed
+				err := json.Unmarshal(body, &doc)
+				var content []string
+				for _, it := range doc.Items {
+					content = append(content, it.ContentHTML)
+				}
+				return content, err
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(string(c.format), func(t *testing.T) {
+			body, contentType, err := f.Render(c.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != c.contentType {
+				t.Errorf("want content type %s, got %s", c.contentType, contentType)
+			}
+			if strings.Contains(string(body), "<script>") {
+				t.Error("the text of items should be escaped")
+			}
+			content, err := c.content(body)
+			if err != nil {
+				t.Fatalf("invalid feed: %v", err)
+			}
+			if len(content) != 2 || content[0] != wantHTML || content[1] != "<p>hello</p>" {
+				t.Errorf("unexpected content %q", content)
+			}
+		})
+	}
+
+	if _, _, err := f.Render("opml"); err == nil {
+		t.Error("want an error for an unknown format")
+	}
+}
+
+func TestItemTitle(t *testing.T) {
+	cases := []struct {
+		item Item
+		want string
+	}{
+		{item: Item{Title: "title", Text: "text"}, want: "title"},
+		{item: Item{Text: "  first line\nsecond line"}, want: "first line"},
+		{item: Item{Text: strings.Repeat("é", 80)}, want: strings.Repeat("é", 80)},
+		{item: Item{Text: strings.Repeat("é", 81)}, want: strings.Repeat("é", 79) + "…"},
+	}
+	for _, c := range cases {
+		if got := c.item.title(); got != c.want {
+			t.Errorf("title of %q: want %q, got %q", c.item.Text, c.want, got)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2025, 6, 1, 12, 0, 0, 500_000_000, time.UTC)
+	etag := ETag([]byte("feed"))
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "unconditional"},
+		{name: "same etag", headers: map[string]string{"If-None-Match": etag}, want: true},
+		{name: "weak etag", headers: map[string]string{"If-None-Match": "W/" + etag}, want: true},
+		{name: "etag in list", headers: map[string]string{"If-None-Match": `"other", ` + etag}, want: true},
+		{name: "any etag", headers: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "other etag", headers: map[string]string{"If-None-Match": `"other"`}},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": "Sun, 01 Jun 2025 12:00:00 GMT"}, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": "Sun, 01 Jun 2025 11:59:59 GMT"}},
+		{name: "invalid date", headers: map[string]string{"If-Modified-Since": "yesterday"}},
+		{
+			name: "etag takes precedence",
+			headers: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": "Sun, 01 Jun 2025 12:00:00 GMT",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users/1/feed.atom", nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+			if got := NotModified(r, etag, modified); got != c.want {
+				t.Errorf("want %t, got %t", c.want, got)
+			}
+			// Without the ETag, only If-Modified-Since can be answered
+			wantSince := c.want && r.Header.Get("If-None-Match") == ""
+			if got := NotModifiedSince(r, modified); got != wantSince {
+				t.Errorf("NotModifiedSince: want %t, got %t", wantSince, got)
+			}
+		})
+	}
+	if ETag([]byte("other feed")) == etag {
+		t.Error("feeds that differ should have different ETags")
+	}
+}
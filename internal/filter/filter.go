@@ -0,0 +1,240 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the term with a mask and lets the text through.
+	ActionMask Action = "mask"
+	// ActionReject refuses the whole text.
+	ActionReject Action = "reject"
+	// ActionFlag lets the text through unchanged but reports it for review.
+	ActionFlag Action = "flag"
+)
+
+const Mask = "****"
+
+func (a Action) Valid() bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
+
+type Term struct {
+	Term   string
+	Action Action
+}
+
+// Match is an occurrence of a term in a text, as a byte range of the
+// original text.
+type Match struct {
+	Term   string
+	Action Action
+	Start  int
+	End    int
+}
+
+type Result struct {
+	// Text is the input with every masked term replaced, and everything else,
+	// whitespace included, left untouched.
+	Text     string
+	Rejected bool
+	Matches  []Match
+}
+
+// Flagged returns the distinct terms of r that call for a review.
+func (r Result) Flagged() []string {
+	var terms []string
+	for _, m := range r.Matches {
+		if m.Action == ActionFlag && !slices.Contains(terms, m.Term) {
+			terms = append(terms, m.Term)
+		}
+	}
+	return terms
+}
+
+// Engine matches a list of terms against texts. Terms and texts are compared
+// word by word, on Unicode word boundaries, after lowercasing and undoing
+// common leetspeak substitutions, so "K3rfuffle!" matches "kerfuffle".
+// An Engine is immutable and safe for concurrent use.
+type Engine struct {
+	terms    map[string]Action
+	maxWords int
+}
+
+func New(terms []Term) (*Engine, error) {
+	e := &Engine{terms: map[string]Action{}}
+	for _, t := range terms {
+		if !t.Action.Valid() {
+			return nil, fmt.Errorf("invalid action '%s' for term '%s'", t.Action, t.Term)
+		}
+		words := tokenize(t.Term)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("term '%s' has no words", t.Term)
+		}
+		key := joinTokens(words)
+		// When a term is listed twice, the strictest action wins.
+		if prev, ok := e.terms[key]; !ok || severity(t.Action) > severity(prev) {
+			e.terms[key] = t.Action
+		}
+		e.maxWords = max(e.maxWords, len(words))
+	}
+	return e, nil
+}
+
+// NormalizeTerm returns the canonical form terms are matched with.
+func NormalizeTerm(term string) string {
+	return joinTokens(tokenize(term))
+}
+
+func (e *Engine) Apply(text string) Result {
+	tokens := tokenize(text)
+	var matches []Match
+	for i := 0; i < len(tokens); {
+		n, action := e.longestMatch(tokens[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		matches = append(matches, Match{
+			Term:   joinTokens(tokens[i : i+n]),
+			Action: action,
+			Start:  tokens[i].start,
+			End:    tokens[i+n-1].end,
+		})
+		i += n
+	}
+
+	res := Result{Matches: matches}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		switch m.Action {
+		case ActionReject:
+			res.Rejected = true
+		case ActionMask:
+			b.WriteString(text[last:m.Start])
+			b.WriteString(Mask)
+			last = m.End
+		}
+	}
+	b.WriteString(text[last:])
+	res.Text = b.String()
+	return res
+}
+
+// longestMatch returns the number of tokens of the longest term starting at
+// tokens[0], and its action.
+func (e *Engine) longestMatch(tokens []token) (int, Action) {
+	for n := min(e.maxWords, len(tokens)); n > 0; n-- {
+		if action, ok := e.terms[joinTokens(tokens[:n])]; ok {
+			return n, action
+		}
+	}
+	return 0, ""
+}
+
+type token struct {
+	norm       string
+	start, end int
+}
+
+// tokenize splits s into normalized words. Leetspeak symbols that UAX #29
+// treats as punctuation ("$" in "$harbert") are glued back to the letters
+// they touch, except for a leading "@", which is a mention sigil, and
+// trailing ones, which are punctuation.
+func tokenize(s string) []token {
+	var tokens []token
+	start, end := -1, -1
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		for start < end && s[start] == '@' {
+			start++
+		}
+		for end > start && strings.IndexByte(leetSymbols, s[end-1]) >= 0 {
+			end--
+		}
+		if hasWordRune(s[start:end]) {
+			tokens = append(tokens, token{norm: normalize(s[start:end]), start: start, end: end})
+		}
+		start = -1
+	}
+	state := -1
+	pos := 0
+	for rest := s; len(rest) > 0; {
+		var seg string
+		seg, rest, state = uniseg.FirstWordInString(rest, state)
+		if hasWordRune(seg) || isLeet(seg) {
+			if start < 0 {
+				start = pos
+			}
+			end = pos + len(seg)
+		} else {
+			flush()
+		}
+		pos += len(seg)
+	}
+	flush()
+	return tokens
+}
+
+func joinTokens(tokens []token) string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.norm
+	}
+	return strings.Join(words, " ")
+}
+
+const leetSymbols = "@$"
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'@': 'a',
+	'$': 's',
+}
+
+func normalize(word string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if l, ok := leet[r]; ok {
+			return l
+		}
+		return r
+	}, word)
+}
+
+func hasWordRune(s string) bool {
+	return strings.IndexFunc(s, isWordRune) >= 0
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isLeet(seg string) bool {
+	return seg != "" && strings.Trim(seg, leetSymbols) == ""
+}
+
+func severity(a Action) int {
+	switch a {
+	case ActionReject:
+		return 2
+	case ActionFlag:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	engine, err := New([]Term{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+		{Term: "fornax", Action: ActionMask},
+		{Term: "spoiler alert", Action: ActionFlag},
+		{Term: "forbidden", Action: ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("unable to create engine: %v", err)
+	}
+
+	cases := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantRejected bool
+		wantFlagged  []string
+	}{
+		{
+			name:     "clean text",
+			text:     "I had something interesting for breakfast",
+			wantText: "I had something interesting for breakfast",
+		},
+		{
+			name:     "punctuation around terms",
+			text:     "What a Kerfuffle! Sure, fornax, sure",
+			wantText: "What a ****! Sure, ****, sure",
+		},
+		{
+			name:     "formatting is kept",
+			text:     "line one  kerfuffle\n\tline two",
+			wantText: "line one  ****\n\tline two",
+		},
+		{
+			name:     "leetspeak",
+			text:     "k3rfuffl3 and $h4rb3rt and @fornax",
+			wantText: "**** and **** and @****",
+		},
+		{
+			name:     "substrings are not matched",
+			text:     "kerfuffles and sharberts",
+			wantText: "kerfuffles and sharberts",
+		},
+		{
+			name:        "flagged phrase",
+			text:        "Spoiler  alert: it ends well",
+			wantText:    "Spoiler  alert: it ends well",
+			wantFlagged: []string{"spoiler alert"},
+		},
+		{
+			name:         "rejected term",
+			text:         "this is F0RBIDDEN.",
+			wantText:     "this is F0RBIDDEN.",
+			wantRejected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := engine.Apply(c.text)
+			if res.Text != c.wantText {
+				t.Errorf("want text '%s', got '%s'", c.wantText, res.Text)
+			}
+			if res.Rejected != c.wantRejected {
+				t.Errorf("want rejected %v, got %v", c.wantRejected, res.Rejected)
+			}
+			if !reflect.DeepEqual(res.Flagged(), c.wantFlagged) {
+				t.Errorf("want flagged %v, got %v", c.wantFlagged, res.Flagged())
+			}
+		})
+	}
+}
+
+func TestNewRejectsInvalidTerms(t *testing.T) {
+	if _, err := New([]Term{{Term: "ok", Action: "ban"}}); err == nil {
+		t.Errorf("want error for unknown action")
+	}
+	if _, err := New([]Term{{Term: " !? ", Action: ActionMask}}); err == nil {
+		t.Errorf("want error for term without words")
+	}
+}
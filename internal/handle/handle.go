@@ -0,0 +1,64 @@
+// Package handle validates the public @handles of users.
+package handle
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	MinLen = 3
+	MaxLen = 15
+)
+
+var (
+	ErrLength   = fmt.Errorf("a handle must be between %d and %d characters", MinLen, MaxLen)
+	ErrCharset  = errors.New("a handle can only contain ASCII letters, digits and underscores")
+	ErrNumeric  = errors.New("a handle must contain at least one letter")
+	ErrReserved = errors.New("this handle is reserved")
+)
+
+// reserved lists the handles that could be mistaken for the service itself or
+// collide with routes. Handles are compared case-insensitively, and also
+// match with trailing digits and underscores, so that "admin_1" is reserved
+// too.
+var reserved = []string{
+	"about", "abuse", "admin", "administrator", "api", "app", "chirpy",
+	"help", "login", "logout", "me", "moderator", "null", "official",
+	"postmaster", "root", "security", "settings", "signup", "staff",
+	"support", "system", "undefined", "webmaster",
+}
+
+// Normalize returns the canonical form of a handle, used to compare handles:
+// lowercased and without a leading "@".
+func Normalize(h string) string {
+	return strings.ToLower(strings.TrimPrefix(h, "@"))
+}
+
+// Validate returns an error if h, with or without its leading "@", can't be
+// used as a handle.
+func Validate(h string) error {
+	h = strings.TrimPrefix(h, "@")
+	if len(h) < MinLen || len(h) > MaxLen {
+		return ErrLength
+	}
+	letters := 0
+	for _, c := range h {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letters++
+		case c >= '0' && c <= '9', c == '_':
+		default:
+			return ErrCharset
+		}
+	}
+	if letters == 0 {
+		return ErrNumeric
+	}
+	if slices.Contains(reserved, strings.TrimRight(Normalize(h), "0123456789_")) {
+		return ErrReserved
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package handle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		handle  string
+		wantErr error
+	}{
+		{name: "valid", handle: "alice", wantErr: nil},
+		{name: "leading at", handle: "@Bob_42", wantErr: nil},
+		{name: "too short", handle: "al", wantErr: ErrLength},
+		{name: "too long", handle: "abcdefghijklmnop", wantErr: ErrLength},
+		{name: "dash", handle: "al-ice", wantErr: ErrCharset},
+		{name: "non ascii", handle: "alicé", wantErr: ErrCharset},
+		{name: "digits only", handle: "12345", wantErr: ErrNumeric},
+		{name: "reserved", handle: "Admin", wantErr: ErrReserved},
+		{name: "reserved with suffix", handle: "support_01", wantErr: ErrReserved},
+		{name: "reserved as prefix", handle: "admiral", wantErr: nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Validate(c.handle)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("want err: %v, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("@Alice_B"); got != "alice_b" {
+		t.Fatalf("want 'alice_b', got '%s'", got)
+	}
+}
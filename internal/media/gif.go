@@ -0,0 +1,97 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxFrames is the largest number of frames accepted in a GIF.
+	MaxFrames = 1000
+	// MaxFramePixels is the largest number of pixels accepted across the
+	// frames of a GIF. Frames are decoded with a byte per pixel, against
+	// four for still images, so it bounds memory like MaxPixels.
+	MaxFramePixels = 4 * MaxPixels
+)
+
+var errTruncatedGIF = errors.New("invalid gif: truncated")
+
+// checkGIFFrames walks the blocks of a GIF without decoding them, and
+// returns ErrTooManyPixels if it has more frames, or more pixels across its
+// frames, than accepted. A small file can hold many frames, each decoded to
+// a full image.
+func checkGIFFrames(data []byte) error {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return errTruncatedGIF
+	}
+	i := 13 + colorTableSize(data[10])
+	frames, pixels := 0, int64(0)
+	for {
+		if i >= len(data) {
+			return errTruncatedGIF
+		}
+		switch data[i] {
+		case 0x21: // extension: label and sub-blocks
+			if i+2 > len(data) {
+				return errTruncatedGIF
+			}
+			n, err := skipSubBlocks(data[i+2:])
+			if err != nil {
+				return err
+			}
+			i += 2 + n
+		case 0x2C: // image descriptor, color table, LZW code size and sub-blocks
+			if i+11 > len(data) {
+				return errTruncatedGIF
+			}
+			w := binary.LittleEndian.Uint16(data[i+5:])
+			h := binary.LittleEndian.Uint16(data[i+7:])
+			frames++
+			pixels += int64(w) * int64(h)
+			if frames > MaxFrames || pixels > MaxFramePixels {
+				return fmt.Errorf("%w: %d frames of %d pixels", ErrTooManyPixels, frames, pixels)
+			}
+			i += 10 + colorTableSize(data[i+9]) + 1
+			if i > len(data) {
+				return errTruncatedGIF
+			}
+			n, err := skipSubBlocks(data[i:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case 0x3B: // trailer
+			return nil
+		default:
+			return fmt.Errorf("invalid gif: unknown block 0x%02x", data[i])
+		}
+	}
+}
+
+// colorTableSize returns the size of the color table described by the packed
+// field of a logical screen or image descriptor.
+func colorTableSize(packed byte) int {
+	if packed&0x80 == 0 {
+		return 0
+	}
+	return 3 << (packed&0x07 + 1)
+}
+
+// skipSubBlocks returns the length of the sub-blocks at the start of data,
+// up to and including their terminator.
+func skipSubBlocks(data []byte) (int, error) {
+	i := 0
+	for {
+		if i >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
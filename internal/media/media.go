@@ -0,0 +1,148 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	ThumbnailSize = 320
+	jpegQuality   = 90
+	// MaxPixels is the largest image accepted, in pixels. Its dimensions are
+	// checked before decoding, as a small file can declare an image that
+	// takes gigabytes once decoded.
+	MaxPixels = 40_000_000
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooManyPixels   = errors.New("image is too large")
+)
+
+// allowedTypes maps the sniffed content types we accept to the file extension
+// used when storing them.
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"video/mp4":  ".mp4",
+}
+
+// Processed is an upload ready to be stored: metadata has been stripped from
+// images, and Thumbnail is set for images only.
+type Processed struct {
+	ContentType   string
+	Ext           string
+	Data          []byte
+	Width, Height int
+	Thumbnail     []byte
+}
+
+// Process sniffs the real content type of data, ignoring whatever the client
+// claimed, and prepares it for storage.
+func Process(data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return Processed{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	p := Processed{ContentType: contentType, Ext: ext}
+	if strings.HasPrefix(contentType, "image/") {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("invalid image: %w", err)
+		}
+		if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxPixels/cfg.Height {
+			return Processed{}, fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+		}
+	}
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("invalid jpeg: %w", err)
+		}
+		// Re-encoding drops the EXIF segment, so the orientation it carries
+		// must be applied to the pixels first.
+		img = applyOrientation(img, exifOrientation(data))
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Processed{}, err
+		}
+		p.Data = buf.Bytes()
+		return withThumbnail(p, img)
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("invalid png: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return Processed{}, err
+		}
+		p.Data = buf.Bytes()
+		return withThumbnail(p, img)
+	case "image/gif":
+		if err := checkGIFFrames(data); err != nil {
+			return Processed{}, err
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Processed{}, fmt.Errorf("invalid gif: %w", err)
+		}
+		// EncodeAll only writes the frames and the loop count, dropping
+		// comments and application extensions.
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return Processed{}, err
+		}
+		p.Data = buf.Bytes()
+		return withThumbnail(p, g.Image[0])
+	default:
+		p.Data = data
+		return p, nil
+	}
+}
+
+func withThumbnail(p Processed, img image.Image) (Processed, error) {
+	b := img.Bounds()
+	p.Width, p.Height = b.Dx(), b.Dy()
+	thumb, err := Thumbnail(img, ThumbnailSize)
+	if err != nil {
+		return Processed{}, err
+	}
+	p.Thumbnail = thumb
+	return p, nil
+}
+
+// Thumbnail scales img down to fit in a size x size square, keeping its aspect
+// ratio, and encodes it as JPEG.
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// JPEG has no alpha channel: paint transparent areas white, not black.
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withExifOrientation inserts a minimal big-endian EXIF APP1 segment carrying
+// the given orientation right after the SOI marker of a JPEG file.
+func withExifOrientation(t *testing.T, data []byte, orientation byte) []byte {
+	t.Helper()
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // header, IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // orientation, SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segLen := len(payload) + 2
+	app1 := append([]byte{0xFF, 0xE1, byte(segLen >> 8), byte(segLen)}, payload...)
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessJPEGStripsExif(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withExifOrientation(t, buf.Bytes(), 6)
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("want orientation 6, got %d", got)
+	}
+
+	p, err := Process(data)
+	if err != nil {
+		t.Fatalf("unable to process jpeg: %v", err)
+	}
+	if p.ContentType != "image/jpeg" || p.Ext != ".jpg" {
+		t.Errorf("unexpected type %s / %s", p.ContentType, p.Ext)
+	}
+	if bytes.Contains(p.Data, []byte("Exif")) {
+		t.Errorf("EXIF data was not stripped")
+	}
+	// Orientation 6 is a 90° rotation: the stored image must be upright.
+	if p.Width != 20 || p.Height != 40 {
+		t.Errorf("want 20x40 after rotation, got %dx%d", p.Width, p.Height)
+	}
+	if len(p.Thumbnail) == 0 {
+		t.Errorf("want a thumbnail")
+	}
+}
+
+func TestProcessPNGThumbnail(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 500))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("unable to process png: %v", err)
+	}
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(p.Thumbnail))
+	if err != nil {
+		t.Fatalf("invalid thumbnail: %v", err)
+	}
+	if thumb.Width != ThumbnailSize || thumb.Height != ThumbnailSize/2 {
+		t.Errorf("want %dx%d thumbnail, got %dx%d", ThumbnailSize, ThumbnailSize/2, thumb.Width, thumb.Height)
+	}
+}
+
+func TestProcessRejectsUnsupportedType(t *testing.T) {
+	_, err := Process([]byte("<html><body>not an image</body></html>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("want ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestProcessRejectsDecompressionBombs(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Declare a 60000x60000 image in the IHDR chunk, and fix its CRC.
+	data := buf.Bytes()
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:], 60000)
+	binary.BigEndian.PutUint32(ihdr[8:], 60000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+
+	_, err := Process(data)
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("want ErrTooManyPixels, got %v", err)
+	}
+}
+
+// animatedGIF encodes a GIF of n frames of w x h pixels.
+func animatedGIF(t *testing.T, n, w, h int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for range n {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// largeFramesGIF declares n frames of 6000x6000 pixels without their image
+// data, which is never decoded when the frames are rejected.
+func largeFramesGIF(n int) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, 6000)
+	data = binary.LittleEndian.AppendUint16(data, 6000)
+	data = append(data, 0x80, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF) // two colors
+	for range n {
+		data = append(data, 0x2C, 0, 0, 0, 0)
+		data = binary.LittleEndian.AppendUint16(data, 6000)
+		data = binary.LittleEndian.AppendUint16(data, 6000)
+		data = append(data, 0, 2, 0) // no color table, code size, no data
+	}
+	return append(data, 0x3B)
+}
+
+func TestProcessGIFFrames(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"animation", animatedGIF(t, 10, 32, 32), nil},
+		{"too many frames", animatedGIF(t, MaxFrames+1, 1, 1), ErrTooManyPixels},
+		{"too many pixels across frames", largeFramesGIF(5), ErrTooManyPixels},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Process(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (p.Width != 32 || p.Height != 32) {
+				t.Errorf("want 32x32, got %dx%d", p.Width, p.Height)
+			}
+		})
+	}
+}
+
+func TestProcessRejectsTruncatedGIF(t *testing.T) {
+	data := animatedGIF(t, 2, 8, 8)
+	if _, err := Process(data[:len(data)-8]); err == nil {
+		t.Error("want an error for a truncated gif")
+	}
+}
@@ -0,0 +1,200 @@
+// Package realtime routes messages to the live connections of users, by
+// topic. Connections subscribe to the topics they are interested in, and the
+// hub delivers each message published on a topic to its subscribers without
+// ever blocking the publisher.
+package realtime
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTooManyConnections = errors.New("too many connections")
+	ErrTooSlow            = errors.New("connection too slow")
+	ErrHubClosed          = errors.New("hub closed")
+)
+
+// Message is delivered to the connections subscribed to a topic. Topic is the
+// name clients know the topic by, which can differ from the key it is routed
+// with: every user has their own "notifications" topic.
+type Message struct {
+	Topic string
+	Type  string
+	// AuthorID is the user the message comes from, if any, for connections
+	// to leave out the users they don't want to hear from.
+	AuthorID uuid.UUID
+	Data     []byte
+}
+
+// Conn is the registration of a live connection in a hub. Its messages are
+// read from C, until Done is closed.
+type Conn struct {
+	UserID uuid.UUID
+	C      <-chan Message
+
+	c      chan Message
+	done   chan struct{}
+	err    error
+	topics map[string]struct{}
+	hub    *Hub
+}
+
+// Done is closed when the hub drops the connection, either because it is
+// closing or because the connection fell behind. Err tells which.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection was dropped, once Done is closed.
+func (c *Conn) Err() error {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return c.err
+}
+
+// Subscribe adds the topic routed with key to the subscriptions of c.
+func (c *Conn) Subscribe(key string) {
+	h := c.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.users[c.UserID][c]; !ok {
+		return
+	}
+	c.topics[key] = struct{}{}
+	if h.topics[key] == nil {
+		h.topics[key] = map[*Conn]struct{}{}
+	}
+	h.topics[key][c] = struct{}{}
+}
+
+// Unsubscribe removes the topic routed with key from the subscriptions of c.
+func (c *Conn) Unsubscribe(key string) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.unsubscribe(c, key)
+}
+
+// Topics returns the number of topics c is subscribed to.
+func (c *Conn) Topics() int {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return len(c.topics)
+}
+
+// Close unregisters c. It is safe to call more than once.
+func (c *Conn) Close() {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.drop(c, nil)
+}
+
+// Hub tracks connections and their subscriptions. It is safe for concurrent
+// use.
+type Hub struct {
+	mu         sync.Mutex
+	users      map[uuid.UUID]map[*Conn]struct{}
+	topics     map[string]map[*Conn]struct{}
+	maxPerUser int
+	backlog    int
+	closed     bool
+}
+
+// NewHub returns a hub accepting up to maxPerUser connections per user, and
+// buffering up to backlog messages per connection. A connection more than
+// backlog messages behind is dropped, so that a slow client never holds
+// back the others.
+func NewHub(maxPerUser, backlog int) *Hub {
+	return &Hub{
+		users:      map[uuid.UUID]map[*Conn]struct{}{},
+		topics:     map[string]map[*Conn]struct{}{},
+		maxPerUser: maxPerUser,
+		backlog:    backlog,
+	}
+}
+
+// Register adds a connection of userID, or returns ErrTooManyConnections if
+// the user already has as many as allowed.
+func (h *Hub) Register(userID uuid.UUID) (*Conn, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	if len(h.users[userID]) >= h.maxPerUser {
+		return nil, ErrTooManyConnections
+	}
+	c := &Conn{
+		UserID: userID,
+		c:      make(chan Message, h.backlog),
+		done:   make(chan struct{}),
+		topics: map[string]struct{}{},
+		hub:    h,
+	}
+	c.C = c.c
+	if h.users[userID] == nil {
+		h.users[userID] = map[*Conn]struct{}{}
+	}
+	h.users[userID][c] = struct{}{}
+	return c, nil
+}
+
+// Publish delivers m to the connections subscribed to key.
+func (h *Hub) Publish(key string, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.topics[key] {
+		select {
+		case c.c <- m:
+		default:
+			h.drop(c, ErrTooSlow)
+		}
+	}
+}
+
+// Subscribed reports whether any connection is subscribed to key, for
+// publishers to skip the work of building messages nobody gets.
+func (h *Hub) Subscribed(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.topics[key]) > 0
+}
+
+// Close drops every connection and refuses new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, conns := range h.users {
+		for c := range conns {
+			h.drop(c, ErrHubClosed)
+		}
+	}
+}
+
+func (h *Hub) unsubscribe(c *Conn, key string) {
+	delete(c.topics, key)
+	delete(h.topics[key], c)
+	if len(h.topics[key]) == 0 {
+		delete(h.topics, key)
+	}
+}
+
+// drop unregisters c and closes its Done channel. err is nil when the owner
+// of the connection closes it. Must be called with h.mu held.
+func (h *Hub) drop(c *Conn, err error) {
+	if _, ok := h.users[c.UserID][c]; !ok {
+		return
+	}
+	for key := range c.topics {
+		h.unsubscribe(c, key)
+	}
+	delete(h.users[c.UserID], c)
+	if len(h.users[c.UserID]) == 0 {
+		delete(h.users, c.UserID)
+	}
+	c.err = err
+	close(c.done)
+}
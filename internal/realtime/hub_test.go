@@ -0,0 +1,111 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func register(t *testing.T, h *Hub, userID uuid.UUID) *Conn {
+	t.Helper()
+	c, err := h.Register(userID)
+	if err != nil {
+		t.Fatalf("unable to register connection: %v", err)
+	}
+	return c
+}
+
+func closed(c *Conn) bool {
+	select {
+	case <-c.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHubRouting(t *testing.T) {
+	h := NewHub(2, 10)
+	alice := register(t, h, uuid.New())
+	bob := register(t, h, uuid.New())
+	alice.Subscribe("tag:go")
+	bob.Subscribe("tag:go")
+	bob.Subscribe("tag:rust")
+
+	h.Publish("tag:go", Message{Topic: "tag:go", Type: "chirp_created"})
+	h.Publish("tag:rust", Message{Topic: "tag:rust", Type: "chirp_created"})
+	h.Publish("tag:zig", Message{Topic: "tag:zig", Type: "chirp_created"})
+	if got := len(alice.C); got != 1 {
+		t.Errorf("want 1 message for alice, got %d", got)
+	}
+	if got := len(bob.C); got != 2 {
+		t.Errorf("want 2 messages for bob, got %d", got)
+	}
+
+	bob.Unsubscribe("tag:go")
+	h.Publish("tag:go", Message{Topic: "tag:go"})
+	if got := len(bob.C); got != 2 {
+		t.Errorf("want no message for bob after unsubscribing, got %d", got-2)
+	}
+	if h.Subscribed("tag:zig") {
+		t.Error("want no subscriber to tag:zig")
+	}
+	bob.Close()
+	if h.Subscribed("tag:rust") {
+		t.Error("want subscriptions removed with the connection")
+	}
+}
+
+func TestHubConnectionLimit(t *testing.T) {
+	h := NewHub(2, 10)
+	userID := uuid.New()
+	first := register(t, h, userID)
+	register(t, h, userID)
+	if _, err := h.Register(userID); !errors.Is(err, ErrTooManyConnections) {
+		t.Fatalf("want ErrTooManyConnections, got %v", err)
+	}
+	register(t, h, uuid.New())
+
+	first.Close()
+	first.Close()
+	if err := first.Err(); err != nil {
+		t.Errorf("want nil error after Close, got %v", err)
+	}
+	register(t, h, userID)
+}
+
+func TestHubDropsSlowConnections(t *testing.T) {
+	h := NewHub(1, 2)
+	slow := register(t, h, uuid.New())
+	slow.Subscribe("timeline")
+	for range 3 {
+		h.Publish("timeline", Message{Topic: "timeline"})
+	}
+	if !closed(slow) {
+		t.Fatal("want slow connection dropped")
+	}
+	if err := slow.Err(); !errors.Is(err, ErrTooSlow) {
+		t.Errorf("want ErrTooSlow, got %v", err)
+	}
+	if got := len(slow.C); got != 2 {
+		t.Errorf("want the 2 buffered messages kept, got %d", got)
+	}
+	// Subscribing a dropped connection is a no-op
+	slow.Subscribe("timeline")
+	if h.Subscribed("timeline") {
+		t.Error("want no subscriber after drop")
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub(1, 2)
+	c := register(t, h, uuid.New())
+	h.Close()
+	if !closed(c) || !errors.Is(c.Err(), ErrHubClosed) {
+		t.Fatal("want connection dropped with ErrHubClosed")
+	}
+	if _, err := h.Register(uuid.New()); !errors.Is(err, ErrHubClosed) {
+		t.Fatalf("want ErrHubClosed, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package stream fans out events to live subscribers, like the clients of a
+// Server-Sent Events endpoint, and keeps the latest ones so that clients can
+// resume after a disconnection.
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is a message pushed to subscribers. IDs are assigned by the
+// publisher and must be unique, but need not be ordered.
+type Event struct {
+	ID       string
+	Type     string
+	AuthorID uuid.UUID
+	Data     []byte
+}
+
+// Subscription receives the events published after it was created, until it
+// is closed by Unsubscribe, by the hub or because it fell behind.
+type Subscription struct {
+	C <-chan Event
+
+	c   chan Event
+	hub *Hub
+}
+
+// Hub dispatches events to subscriptions. It is safe for concurrent use.
+type Hub struct {
+	mu      sync.Mutex
+	replay  []Event
+	size    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+	backlog int
+}
+
+// NewHub returns a hub keeping the last replaySize events for resumption,
+// and buffering up to backlog events per subscription. A subscription more
+// than backlog events behind is dropped rather than slowing everyone down.
+func NewHub(replaySize, backlog int) *Hub {
+	return &Hub{size: replaySize, backlog: backlog, subs: map[*Subscription]struct{}{}}
+}
+
+// Publish sends e to every subscription and adds it to the replay buffer.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.replay = append(h.replay, e)
+	if len(h.replay) > h.size {
+		h.replay = h.replay[len(h.replay)-h.size:]
+	}
+	for sub := range h.subs {
+		select {
+		case sub.c <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// Subscribe returns a new subscription, along with the events to replay to
+// a client that last saw the event lastID. Events are replayed in the order
+// they were published. If lastID is empty nothing is replayed, and if it is
+// no longer in the buffer the whole buffer is.
+func (h *Hub) Subscribe(lastID string) ([]Event, *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := make(chan Event, h.backlog)
+	sub := &Subscription{C: c, c: c, hub: h}
+	if h.closed {
+		close(c)
+		return nil, sub
+	}
+	h.subs[sub] = struct{}{}
+	if lastID == "" {
+		return nil, sub
+	}
+	start := 0
+	for i, e := range h.replay {
+		if e.ID == lastID {
+			start = i + 1
+			break
+		}
+	}
+	return append([]Event(nil), h.replay[start:]...), sub
+}
+
+// Unsubscribe stops the subscription and closes its channel. It is safe to
+// call more than once.
+func (s *Subscription) Unsubscribe() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Close closes every subscription, and makes later ones closed from the
+// start, so that streaming handlers return on shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
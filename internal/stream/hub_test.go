@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func ids(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func publish(h *Hub, from, to int) {
+	for i := from; i <= to; i++ {
+		h.Publish(Event{ID: strconv.Itoa(i)})
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	h := NewHub(3, 10)
+	publish(h, 1, 5)
+	cases := []struct {
+		name   string
+		lastID string
+		want   []string
+	}{
+		{name: "no last ID", lastID: "", want: nil},
+		{name: "up to date", lastID: "5", want: nil},
+		{name: "in buffer", lastID: "3", want: []string{"4", "5"}},
+		{name: "out of buffer", lastID: "1", want: []string{"3", "4", "5"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			replay, sub := h.Subscribe(c.lastID)
+			defer sub.Unsubscribe()
+			if got := ids(replay); !slices.Equal(got, c.want) {
+				t.Fatalf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestHubLive(t *testing.T) {
+	h := NewHub(3, 10)
+	_, sub := h.Subscribe("")
+	publish(h, 1, 2)
+	for _, want := range []string{"1", "2"} {
+		if got := (<-sub.C).ID; got != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	}
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("want closed channel after Unsubscribe")
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	h := NewHub(3, 2)
+	_, slow := h.Subscribe("")
+	publish(h, 1, 3)
+	var got []string
+	for e := range slow.C {
+		got = append(got, e.ID)
+	}
+	if want := []string{"1", "2"}; !slices.Equal(got, want) {
+		t.Fatalf("want %v then closed, got %v", want, got)
+	}
+	// Unsubscribing a dropped subscription is a no-op
+	slow.Unsubscribe()
+}
+
+func TestHubClose(t *testing.T) {
+	h := NewHub(3, 10)
+	_, before := h.Subscribe("")
+	h.Close()
+	if _, ok := <-before.C; ok {
+		t.Fatal("want closed channel after Close")
+	}
+	_, after := h.Subscribe("")
+	if _, ok := <-after.C; ok {
+		t.Fatal("want closed channel when subscribing after Close")
+	}
+	h.Publish(Event{ID: "1"})
+	after.Unsubscribe()
+}
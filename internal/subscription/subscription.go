@@ -0,0 +1,152 @@
+// Package subscription implements the lifecycle of Chirpy Red subscriptions,
+// as a state machine driven by the events of our payment provider.
+//
+// A subscription is paid for a period at a time. Until the end of the
+// current period, it grants Chirpy Red even if a payment failed or the user
+// canceled it, and it expires once the period is over unless it was
+// renewed. A refund ends it right away.
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Status string
+
+const (
+	// StatusNone is the status of users who never subscribed.
+	StatusNone     Status = ""
+	StatusActive   Status = "active"
+	StatusPastDue  Status = "past_due"
+	StatusCanceled Status = "canceled"
+	StatusExpired  Status = "expired"
+	StatusRefunded Status = "refunded"
+)
+
+type Event string
+
+const (
+	EventUpgraded      Event = "user.upgraded"
+	EventDowngraded    Event = "user.downgraded"
+	EventRenewed       Event = "subscription.renewed"
+	EventPaymentFailed Event = "payment.failed"
+	EventRefunded      Event = "payment.refunded"
+)
+
+// Events lists the events of the payment provider driving subscriptions.
+var Events = []Event{EventUpgraded, EventDowngraded, EventRenewed, EventPaymentFailed, EventRefunded}
+
+// PlanRed is the only plan for now.
+const PlanRed = "red"
+
+// DefaultPeriod is the length of a period when the payment provider doesn't
+// give its end.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidTransition = errors.New("invalid subscription transition")
+	// ErrInvalidPeriod is returned for the periods ending before they start.
+	ErrInvalidPeriod = errors.New("invalid subscription period")
+)
+
+// State is the state of the subscription of a user.
+type State struct {
+	Status      Status
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// CanceledAt is set when the user canceled, or was refunded.
+	CanceledAt time.Time
+}
+
+// Active reports whether s grants Chirpy Red at now.
+func (s State) Active(now time.Time) bool {
+	switch s.Status {
+	case StatusActive, StatusPastDue, StatusCanceled:
+		return now.Before(s.PeriodEnd)
+	default:
+		return false
+	}
+}
+
+// Apply returns the state following s after e happened at now. periodEnd is
+// the end of the period paid for by upgrades and renewals; if zero, the new
+// period lasts DefaultPeriod.
+func Apply(s State, e Event, now, periodEnd time.Time) (State, error) {
+	invalid := fmt.Errorf("%w: %s on %s subscription", ErrInvalidTransition, e, statusName(s.Status))
+	switch e {
+	case EventUpgraded:
+		// Subscribing again before the end of a canceled subscription
+		// resumes it.
+		if s.Status == StatusCanceled && s.Active(now) {
+			s.Status, s.CanceledAt = StatusActive, time.Time{}
+			return s, nil
+		}
+		if s.Active(now) {
+			return State{}, invalid
+		}
+		return newPeriod(now, periodEnd)
+	case EventRenewed:
+		if s.Status != StatusActive && s.Status != StatusPastDue {
+			return State{}, invalid
+		}
+		// Early renewals start when the current period ends
+		start := now
+		if s.PeriodEnd.After(now) {
+			start = s.PeriodEnd
+		}
+		return newPeriod(start, periodEnd)
+	case EventPaymentFailed:
+		if s.Status != StatusActive && s.Status != StatusPastDue {
+			return State{}, invalid
+		}
+		s.Status = StatusPastDue
+		return s, nil
+	case EventDowngraded:
+		if s.Status != StatusActive && s.Status != StatusPastDue {
+			return State{}, invalid
+		}
+		s.Status, s.CanceledAt = StatusCanceled, now
+		return s, nil
+	case EventRefunded:
+		if s.Status == StatusNone || s.Status == StatusRefunded {
+			return State{}, invalid
+		}
+		s.Status, s.CanceledAt, s.PeriodEnd = StatusRefunded, now, now
+		return s, nil
+	default:
+		return State{}, fmt.Errorf("unknown subscription event '%s'", e)
+	}
+}
+
+// Expire returns the state of s at now, once the period it was paid for is
+// over, and whether it changed.
+func Expire(s State, now time.Time) (State, bool) {
+	switch s.Status {
+	case StatusActive, StatusPastDue, StatusCanceled:
+		if !now.Before(s.PeriodEnd) {
+			s.Status = StatusExpired
+			return s, true
+		}
+	}
+	return s, false
+}
+
+func newPeriod(start, end time.Time) (State, error) {
+	if end.IsZero() {
+		end = start.Add(DefaultPeriod)
+	}
+	if !end.After(start) {
+		return State{}, fmt.Errorf("%w: ends at %s, before its start at %s", ErrInvalidPeriod,
+			end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	return State{Status: StatusActive, PeriodStart: start, PeriodEnd: end}, nil
+}
+
+func statusName(s Status) string {
+	if s == StatusNone {
+		return "no"
+	}
+	return string(s)
+}
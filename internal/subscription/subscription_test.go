@@ -0,0 +1,142 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	active := State{Status: StatusActive, PeriodStart: now.Add(-10 * day), PeriodEnd: now.Add(20 * day)}
+	lapsed := State{Status: StatusPastDue, PeriodStart: now.Add(-40 * day), PeriodEnd: now.Add(-10 * day)}
+	canceled := State{Status: StatusCanceled, PeriodStart: now.Add(-10 * day), PeriodEnd: now.Add(20 * day), CanceledAt: now.Add(-day)}
+	expired := State{Status: StatusExpired, PeriodStart: now.Add(-40 * day), PeriodEnd: now.Add(-10 * day)}
+
+	cases := []struct {
+		name       string
+		state      State
+		event      Event
+		periodEnd  time.Time
+		want       State
+		wantActive bool
+		wantErr    bool
+	}{
+		{
+			name:       "first upgrade",
+			state:      State{},
+			event:      EventUpgraded,
+			want:       State{Status: StatusActive, PeriodStart: now, PeriodEnd: now.Add(DefaultPeriod)},
+			wantActive: true,
+		},
+		{
+			name:       "upgrade with period end",
+			state:      expired,
+			event:      EventUpgraded,
+			periodEnd:  now.Add(365 * day),
+			want:       State{Status: StatusActive, PeriodStart: now, PeriodEnd: now.Add(365 * day)},
+			wantActive: true,
+		},
+		{
+			name:       "resubscribe after cancel",
+			state:      canceled,
+			event:      EventUpgraded,
+			want:       State{Status: StatusActive, PeriodStart: canceled.PeriodStart, PeriodEnd: canceled.PeriodEnd},
+			wantActive: true,
+		},
+		{name: "upgrade while active", state: active, event: EventUpgraded, wantErr: true},
+		{
+			name:       "early renewal",
+			state:      active,
+			event:      EventRenewed,
+			want:       State{Status: StatusActive, PeriodStart: now.Add(20 * day), PeriodEnd: now.Add(20*day + DefaultPeriod)},
+			wantActive: true,
+		},
+		{
+			name:       "renewal after failed payment",
+			state:      lapsed,
+			event:      EventRenewed,
+			want:       State{Status: StatusActive, PeriodStart: now, PeriodEnd: now.Add(DefaultPeriod)},
+			wantActive: true,
+		},
+		{name: "renewal of expired", state: expired, event: EventRenewed, wantErr: true},
+		{name: "upgrade ending in the past", state: State{}, event: EventUpgraded, periodEnd: now.Add(-day), wantErr: true},
+		{name: "early renewal ending before the current end", state: active, event: EventRenewed, periodEnd: active.PeriodEnd, wantErr: true},
+		{
+			name:       "failed payment keeps the period",
+			state:      active,
+			event:      EventPaymentFailed,
+			want:       State{Status: StatusPastDue, PeriodStart: active.PeriodStart, PeriodEnd: active.PeriodEnd},
+			wantActive: true,
+		},
+		{name: "failed payment without subscription", state: State{}, event: EventPaymentFailed, wantErr: true},
+		{
+			name:       "downgrade at period end",
+			state:      active,
+			event:      EventDowngraded,
+			want:       State{Status: StatusCanceled, PeriodStart: active.PeriodStart, PeriodEnd: active.PeriodEnd, CanceledAt: now},
+			wantActive: true,
+		},
+		{name: "downgrade twice", state: canceled, event: EventDowngraded, wantErr: true},
+		{
+			name:       "refund ends right away",
+			state:      active,
+			event:      EventRefunded,
+			want:       State{Status: StatusRefunded, PeriodStart: active.PeriodStart, PeriodEnd: now, CanceledAt: now},
+			wantActive: false,
+		},
+		{name: "refund twice", state: State{Status: StatusRefunded}, event: EventRefunded, wantErr: true},
+		{name: "unknown event", state: active, event: "user.exploded", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Apply(c.state, c.event, now, c.periodEnd)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("want %+v, got %+v", c.want, got)
+			}
+			if got.Active(now) != c.wantActive {
+				t.Errorf("want active %v, got %v", c.wantActive, got.Active(now))
+			}
+		})
+	}
+	if _, err := Apply(active, EventUpgraded, now, time.Time{}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("want ErrInvalidTransition, got %v", err)
+	}
+	if _, err := Apply(State{}, EventUpgraded, now, now); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("want ErrInvalidPeriod, got %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name        string
+		state       State
+		wantStatus  Status
+		wantChanged bool
+	}{
+		{name: "running", state: State{Status: StatusActive, PeriodEnd: now.Add(time.Hour)}, wantStatus: StatusActive},
+		{name: "lapsed", state: State{Status: StatusActive, PeriodEnd: now}, wantStatus: StatusExpired, wantChanged: true},
+		{name: "past due", state: State{Status: StatusPastDue, PeriodEnd: now.Add(-time.Hour)}, wantStatus: StatusExpired, wantChanged: true},
+		{name: "canceled", state: State{Status: StatusCanceled, PeriodEnd: now.Add(-time.Hour)}, wantStatus: StatusExpired, wantChanged: true},
+		{name: "refunded", state: State{Status: StatusRefunded, PeriodEnd: now.Add(-time.Hour)}, wantStatus: StatusRefunded},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, changed := Expire(c.state, now)
+			if got.Status != c.wantStatus || changed != c.wantChanged {
+				t.Errorf("want %s %v, got %s %v", c.wantStatus, c.wantChanged, got.Status, changed)
+			}
+		})
+	}
+}
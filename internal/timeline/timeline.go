@@ -0,0 +1,107 @@
+// Package timeline builds the home timelines of users: the chirps of the
+// users they follow, and their own, newest first.
+//
+// Two strategies are available. Fan-out-on-read joins the follow graph with
+// the chirps every time a page is requested, so writes are cheap and reads
+// get slower as users follow more people. Fan-out-on-write copies every
+// published chirp into a materialized timeline per follower, so reads are a
+// single index scan and the cost moves to publication and follow changes.
+package timeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	FanOutOnRead  = "read"
+	FanOutOnWrite = "write"
+)
+
+// BackfillSize is how many of the latest chirps of a user are copied into the
+// timeline of a new follower with fan-out-on-write.
+const BackfillSize = 100
+
+// Strategy reads and maintains home timelines. The maintenance methods take
+// the queries to run, so that they can share the transaction of the change
+// that triggers them.
+type Strategy interface {
+	Name() string
+	// Page returns at most limit chirps of the timeline of userID, older
+	// than before if it isn't nil.
+	Page(ctx context.Context, q *database.Queries, userID uuid.UUID, before *Cursor, limit int32) ([]database.Chirp, error)
+	// Published is called when a chirp is published.
+	Published(ctx context.Context, q *database.Queries, chirp database.Chirp) error
+	// Followed is called when follower starts following followee.
+	Followed(ctx context.Context, q *database.Queries, follower, followee uuid.UUID) error
+	// Unfollowed is called when follower stops following followee.
+	Unfollowed(ctx context.Context, q *database.Queries, follower, followee uuid.UUID) error
+}
+
+// New returns the strategy with the given name.
+func New(name string) (Strategy, error) {
+	switch name {
+	case FanOutOnRead:
+		return readStrategy{}, nil
+	case FanOutOnWrite:
+		return writeStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown timeline strategy '%s'", name)
+	}
+}
+
+type readStrategy struct{}
+
+func (readStrategy) Name() string { return FanOutOnRead }
+
+func (readStrategy) Page(ctx context.Context, q *database.Queries, userID uuid.UUID, before *Cursor, limit int32) ([]database.Chirp, error) {
+	params := database.GetTimelineFanOutOnReadParams{UserID: userID, PageSize: limit}
+	params.BeforeCreatedAt, params.BeforeID = before.params()
+	return q.GetTimelineFanOutOnRead(ctx, params)
+}
+
+func (readStrategy) Published(context.Context, *database.Queries, database.Chirp) error { return nil }
+
+func (readStrategy) Followed(context.Context, *database.Queries, uuid.UUID, uuid.UUID) error {
+	return nil
+}
+
+func (readStrategy) Unfollowed(context.Context, *database.Queries, uuid.UUID, uuid.UUID) error {
+	return nil
+}
+
+type writeStrategy struct{}
+
+func (writeStrategy) Name() string { return FanOutOnWrite }
+
+func (writeStrategy) Page(ctx context.Context, q *database.Queries, userID uuid.UUID, before *Cursor, limit int32) ([]database.Chirp, error) {
+	params := database.GetTimelineFanOutOnWriteParams{UserID: userID, PageSize: limit}
+	params.BeforeCreatedAt, params.BeforeID = before.params()
+	return q.GetTimelineFanOutOnWrite(ctx, params)
+}
+
+func (writeStrategy) Published(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	return q.FanOutChirp(ctx, database.FanOutChirpParams{
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		AuthorID:  chirp.UserID,
+	})
+}
+
+func (writeStrategy) Followed(ctx context.Context, q *database.Queries, follower, followee uuid.UUID) error {
+	return q.BackfillTimeline(ctx, database.BackfillTimelineParams{
+		FollowerID: follower,
+		FolloweeID: followee,
+		MaxChirps:  BackfillSize,
+	})
+}
+
+func (writeStrategy) Unfollowed(ctx context.Context, q *database.Queries, follower, followee uuid.UUID) error {
+	return q.RemoveFromTimeline(ctx, database.RemoveFromTimelineParams{
+		FollowerID: follower,
+		FolloweeID: followee,
+	})
+}
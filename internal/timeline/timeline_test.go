@@ -0,0 +1,196 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestCursor(t *testing.T) {
+	c := Cursor{
+		CreatedAt: time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC),
+		ID:        uuid.New(),
+	}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("unable to decode cursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID {
+		t.Fatalf("want %v, got %v", c, got)
+	}
+
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	cases := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "%%%"},
+		{name: "no separator", cursor: encode("12345")},
+		{name: "bad time", cursor: encode("x_" + uuid.NewString())},
+		{name: "bad id", cursor: encode("123_456")},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := DecodeCursor(c.cursor); err == nil {
+				t.Fatalf("want error for cursor '%s'", c.cursor)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	chirps := []database.Chirp{
+		{ID: uuid.New(), CreatedAt: time.Now()},
+		{ID: uuid.New(), CreatedAt: time.Now().Add(-time.Minute)},
+	}
+	cases := []struct {
+		name   string
+		chirps []database.Chirp
+		limit  int32
+		want   *Cursor
+	}{
+		{name: "empty page", chirps: nil, limit: 2, want: nil},
+		{name: "short page", chirps: chirps, limit: 3, want: nil},
+		{name: "full page", chirps: chirps, limit: 2, want: &Cursor{CreatedAt: chirps[1].CreatedAt, ID: chirps[1].ID}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Next(c.chirps, c.limit)
+			if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+				t.Fatalf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+// openBenchDB opens the migrated database given by CHIRPY_TEST_DB_URL, or
+// skips the benchmark if it isn't set.
+func openBenchDB(b *testing.B) *sql.DB {
+	b.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		b.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		b.Fatalf("unable to open the database: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createBenchUsers creates n users, removed with their chirps and follows
+// at the end of the benchmark.
+func createBenchUsers(ctx context.Context, b *testing.B, db *sql.DB, n int) []uuid.UUID {
+	b.Helper()
+	q := database.New(db)
+	var ids []uuid.UUID
+	b.Cleanup(func() {
+		if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+			b.Errorf("unable to clean up users: %v", err)
+		}
+	})
+	for i := range n {
+		user, err := q.CreateUser(ctx, database.CreateUserParams{
+			Email:          fmt.Sprintf("bench-%d-%s@example.com", i, uuid.NewString()),
+			HashedPassword: "unused",
+		})
+		if err != nil {
+			b.Fatalf("unable to create user: %v", err)
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+// BenchmarkPage compares the read path of both strategies. It needs a
+// migrated database, given by CHIRPY_TEST_DB_URL, in which it creates and
+// then removes its own users.
+func BenchmarkPage(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	q := database.New(db)
+
+	const (
+		users         = 200
+		followsByUser = 50
+		chirpsByUser  = 20
+	)
+	write, _ := New(FanOutOnWrite)
+	ids := createBenchUsers(ctx, b, db, users)
+	for i, id := range ids {
+		for j := 1; j <= followsByUser; j++ {
+			if _, err := q.FollowUser(ctx, database.FollowUserParams{FollowerID: id, FolloweeID: ids[(i+j)%users]}); err != nil {
+				b.Fatalf("unable to follow user: %v", err)
+			}
+		}
+	}
+	for range chirpsByUser {
+		for _, id := range ids {
+			chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{Body: "benchmark", UserID: id, Status: "published"})
+			if err != nil {
+				b.Fatalf("unable to create chirp: %v", err)
+			}
+			if err := write.Published(ctx, q, chirp); err != nil {
+				b.Fatalf("unable to fan out chirp: %v", err)
+			}
+		}
+	}
+
+	for _, name := range []string{FanOutOnRead, FanOutOnWrite} {
+		strategy, _ := New(name)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				if _, err := strategy.Page(ctx, q, ids[i%users], nil, 50); err != nil {
+					b.Fatalf("unable to read timeline: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPublish compares the write path of both strategies: publishing a
+// chirp of an author with more and more followers. Like BenchmarkPage, it
+// needs CHIRPY_TEST_DB_URL.
+func BenchmarkPublish(b *testing.B) {
+	db := openBenchDB(b)
+	ctx := context.Background()
+	q := database.New(db)
+
+	followerCounts := []int{10, 100, 1000}
+	followers := createBenchUsers(ctx, b, db, followerCounts[len(followerCounts)-1])
+	authors := createBenchUsers(ctx, b, db, len(followerCounts))
+	for i, n := range followerCounts {
+		for _, follower := range followers[:n] {
+			if _, err := q.FollowUser(ctx, database.FollowUserParams{FollowerID: follower, FolloweeID: authors[i]}); err != nil {
+				b.Fatalf("unable to follow user: %v", err)
+			}
+		}
+	}
+
+	for _, name := range []string{FanOutOnRead, FanOutOnWrite} {
+		strategy, _ := New(name)
+		for i, n := range followerCounts {
+			b.Run(fmt.Sprintf("%s/followers=%d", name, n), func(b *testing.B) {
+				for b.Loop() {
+					chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{Body: "benchmark", UserID: authors[i], Status: "published"})
+					if err != nil {
+						b.Fatalf("unable to create chirp: %v", err)
+					}
+					if err := strategy.Published(ctx, q, chirp); err != nil {
+						b.Fatalf("unable to fan out chirp: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package trending ranks items, like hashtags or chirps, by their recent
+// engagement over sliding windows.
+//
+// A Tracker is fed engagement events incrementally and keeps those still in
+// its longest window. Rankings are computed on demand by a Scorer, which is
+// pluggable so that the formula can change without touching the tracking.
+package trending
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock returns the current time. It is swapped for a fake in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now().UTC() }
+
+// SystemClock is the Clock that returns the actual time.
+var SystemClock Clock = systemClock{}
+
+// Event is a unit of engagement on an item, like a like on a chirp or a chirp
+// using a hashtag.
+type Event struct {
+	// Key identifies the ranked item.
+	Key string
+	// ChirpID is the chirp the event comes from, so that events can be
+	// dropped when their chirp stops being eligible.
+	ChirpID uuid.UUID
+	At      time.Time
+	Weight  float64
+}
+
+// Scorer computes the score of an item from its events in a window ending at
+// now. Events are in chronological order and all within the window.
+type Scorer interface {
+	Score(events []Event, now time.Time) float64
+}
+
+// ExponentialDecay scores items by the sum of the weights of their events,
+// each halved for every HalfLife elapsed since it happened, so that recent
+// engagement counts more.
+type ExponentialDecay struct {
+	HalfLife time.Duration
+}
+
+func (d ExponentialDecay) Score(events []Event, now time.Time) float64 {
+	score := 0.0
+	for _, e := range events {
+		age := now.Sub(e.At)
+		if age < 0 {
+			age = 0
+		}
+		score += e.Weight * math.Exp2(-float64(age)/float64(d.HalfLife))
+	}
+	return score
+}
+
+// Window is a period over which items are ranked.
+type Window struct {
+	Name   string
+	Length time.Duration
+	Scorer Scorer
+}
+
+// DefaultWindows are the 1h, 24h and 7d windows, each decaying with a half
+// life of a quarter of its length.
+var DefaultWindows = []Window{
+	{Name: "1h", Length: time.Hour, Scorer: ExponentialDecay{HalfLife: 15 * time.Minute}},
+	{Name: "24h", Length: 24 * time.Hour, Scorer: ExponentialDecay{HalfLife: 6 * time.Hour}},
+	{Name: "7d", Length: 7 * 24 * time.Hour, Scorer: ExponentialDecay{HalfLife: 42 * time.Hour}},
+}
+
+// Ranked is an item and its score in a window.
+type Ranked struct {
+	Key   string
+	Score float64
+}
+
+// Tracker keeps the recent events of items. It is safe for concurrent use.
+type Tracker struct {
+	clock   Clock
+	windows []Window
+	span    time.Duration
+
+	mu     sync.Mutex
+	events map[string][]Event
+}
+
+func NewTracker(clock Clock, windows []Window) *Tracker {
+	t := &Tracker{clock: clock, windows: windows, events: map[string][]Event{}}
+	for _, w := range windows {
+		t.span = max(t.span, w.Length)
+	}
+	return t
+}
+
+// Add records events, which may be given in any order. Events older than the
+// longest window are ignored.
+func (t *Tracker) Add(events ...Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	oldest := t.clock.Now().Add(-t.span)
+	for _, e := range events {
+		if e.At.Before(oldest) {
+			continue
+		}
+		evs := append(t.events[e.Key], e)
+		// Events mostly arrive in order, so this is usually a no-op
+		slices.SortStableFunc(evs, func(a, b Event) int { return a.At.Compare(b.At) })
+		t.events[e.Key] = evs
+	}
+}
+
+// Evict drops the events that are out of every window.
+func (t *Tracker) Evict() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	oldest := t.clock.Now().Add(-t.span)
+	for key, evs := range t.events {
+		i, _ := slices.BinarySearchFunc(evs, oldest, func(e Event, at time.Time) int { return e.At.Compare(at) })
+		if i == len(evs) {
+			delete(t.events, key)
+		} else if i > 0 {
+			t.events[key] = slices.Clone(evs[i:])
+		}
+	}
+}
+
+// ChirpIDs returns the distinct chirps the tracked events come from.
+func (t *Tracker) ChirpIDs() []uuid.UUID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seen := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, evs := range t.events {
+		for _, e := range evs {
+			if !seen[e.ChirpID] {
+				seen[e.ChirpID] = true
+				ids = append(ids, e.ChirpID)
+			}
+		}
+	}
+	return ids
+}
+
+// Retain drops the events of the chirps for which keep returns false.
+func (t *Tracker) Retain(keep func(chirpID uuid.UUID) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, evs := range t.events {
+		evs = slices.DeleteFunc(evs, func(e Event) bool { return !keep(e.ChirpID) })
+		if len(evs) == 0 {
+			delete(t.events, key)
+		} else {
+			t.events[key] = evs
+		}
+	}
+}
+
+// Top returns at most n items with the highest scores in window, highest
+// first, ties broken by key. Items without events in the window are left out.
+func (t *Tracker) Top(window Window, n int) []Ranked {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	start := now.Add(-window.Length)
+	var ranked []Ranked
+	for key, evs := range t.events {
+		i, _ := slices.BinarySearchFunc(evs, start, func(e Event, at time.Time) int { return e.At.Compare(at) })
+		if i == len(evs) {
+			continue
+		}
+		ranked = append(ranked, Ranked{Key: key, Score: window.Scorer.Score(evs[i:], now)})
+	}
+	slices.SortFunc(ranked, func(a, b Ranked) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		if a.Key < b.Key {
+			return -1
+		}
+		if a.Key > b.Key {
+			return 1
+		}
+		return 0
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// Cursor tracks how far events stored in a database were read. Rows are
+// timestamped when their transaction starts but only become visible once it
+// commits, so a row can show up behind rows already read: a Cursor rereads
+// an overlap before its position and skips the events it already returned.
+// It isn't safe for concurrent use.
+type Cursor struct {
+	pos     time.Time
+	overlap time.Duration
+	seen    map[string]time.Time
+}
+
+// NewCursor returns a Cursor reading the events from since, rereading the
+// events of the last overlap on every read.
+func NewCursor(since time.Time, overlap time.Duration) *Cursor {
+	return &Cursor{pos: since, overlap: overlap, seen: map[string]time.Time{}}
+}
+
+// Since returns the time to read the next events from, and forgets the
+// events before it.
+func (c *Cursor) Since() time.Time {
+	since := c.pos.Add(-c.overlap)
+	for id, at := range c.seen {
+		if at.Before(since) {
+			delete(c.seen, id)
+		}
+	}
+	return since
+}
+
+// Next records the event id that happened at, and reports whether it is new.
+func (c *Cursor) Next(id string, at time.Time) bool {
+	if _, ok := c.seen[id]; ok {
+		return false
+	}
+	c.seen[id] = at
+	if at.After(c.pos) {
+		c.pos = at
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package trending
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func TestExponentialDecay(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	scorer := ExponentialDecay{HalfLife: time.Hour}
+	cases := []struct {
+		name   string
+		events []Event
+		want   float64
+	}{
+		{name: "no events", events: nil, want: 0},
+		{name: "fresh event", events: []Event{{At: now, Weight: 1}}, want: 1},
+		{name: "one half life", events: []Event{{At: now.Add(-time.Hour), Weight: 1}}, want: 0.5},
+		{name: "two half lives", events: []Event{{At: now.Add(-2 * time.Hour), Weight: 4}}, want: 1},
+		{name: "future event", events: []Event{{At: now.Add(time.Minute), Weight: 1}}, want: 1},
+		{
+			name:   "sum",
+			events: []Event{{At: now.Add(-time.Hour), Weight: 1}, {At: now, Weight: 2}},
+			want:   2.5,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := scorer.Score(c.events, now)
+			if math.Abs(got-c.want) > 1e-9 {
+				t.Fatalf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestTrackerTop(t *testing.T) {
+	clock := newFakeClock()
+	hour := Window{Name: "1h", Length: time.Hour, Scorer: ExponentialDecay{HalfLife: 15 * time.Minute}}
+	day := Window{Name: "24h", Length: 24 * time.Hour, Scorer: ExponentialDecay{HalfLife: 6 * time.Hour}}
+	tracker := NewTracker(clock, []Window{hour, day})
+
+	at := func(ago time.Duration) time.Time { return clock.Now().Add(-ago) }
+	tracker.Add(
+		// old is popular over the day, but quiet for the last hour
+		Event{Key: "old", At: at(5 * time.Hour), Weight: 1},
+		Event{Key: "old", At: at(4 * time.Hour), Weight: 1},
+		Event{Key: "old", At: at(3 * time.Hour), Weight: 1},
+		Event{Key: "new", At: at(10 * time.Minute), Weight: 1},
+		Event{Key: "stale", At: at(48 * time.Hour), Weight: 100},
+	)
+
+	if got := keys(tracker.Top(hour, 10)); !slices.Equal(got, []string{"new"}) {
+		t.Fatalf("1h: want [new], got %v", got)
+	}
+	if got := keys(tracker.Top(day, 10)); !slices.Equal(got, []string{"old", "new"}) {
+		t.Fatalf("24h: want [old new], got %v", got)
+	}
+	if got := keys(tracker.Top(day, 1)); !slices.Equal(got, []string{"old"}) {
+		t.Fatalf("24h top 1: want [old], got %v", got)
+	}
+
+	// An hour later, new has left the 1h window too
+	clock.Advance(time.Hour)
+	if got := tracker.Top(hour, 10); len(got) != 0 {
+		t.Fatalf("1h after an hour: want nothing, got %v", got)
+	}
+
+	// Two more days later, everything has left every window
+	clock.Advance(48 * time.Hour)
+	tracker.Evict()
+	if got := tracker.Top(day, 10); len(got) != 0 {
+		t.Fatalf("24h after two days: want nothing, got %v", got)
+	}
+	if len(tracker.events) != 0 {
+		t.Fatalf("want every event evicted, got %v", tracker.events)
+	}
+}
+
+func TestTrackerTies(t *testing.T) {
+	clock := newFakeClock()
+	hour := Window{Name: "1h", Length: time.Hour, Scorer: ExponentialDecay{HalfLife: time.Hour}}
+	tracker := NewTracker(clock, []Window{hour})
+	tracker.Add(Event{Key: "b", At: clock.Now(), Weight: 1}, Event{Key: "a", At: clock.Now(), Weight: 1})
+	if got := keys(tracker.Top(hour, 10)); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("want [a b], got %v", got)
+	}
+}
+
+func TestTrackerRetain(t *testing.T) {
+	clock := newFakeClock()
+	hour := Window{Name: "1h", Length: time.Hour, Scorer: ExponentialDecay{HalfLife: time.Hour}}
+	tracker := NewTracker(clock, []Window{hour})
+	hidden, visible := uuid.New(), uuid.New()
+	tracker.Add(
+		Event{Key: "tag", ChirpID: hidden, At: clock.Now(), Weight: 1},
+		Event{Key: "tag", ChirpID: visible, At: clock.Now(), Weight: 1},
+		Event{Key: "other", ChirpID: hidden, At: clock.Now(), Weight: 1},
+	)
+	if got := len(tracker.ChirpIDs()); got != 2 {
+		t.Fatalf("want 2 chirps, got %d", got)
+	}
+	tracker.Retain(func(id uuid.UUID) bool { return id != hidden })
+	got := tracker.Top(hour, 10)
+	if len(got) != 1 || got[0].Key != "tag" || got[0].Score != 1 {
+		t.Fatalf("want [tag 1], got %v", got)
+	}
+}
+
+func keys(ranked []Ranked) []string {
+	var out []string
+	for _, r := range ranked {
+		out = append(out, r.Key)
+	}
+	return out
+}
+
+func TestCursor(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := NewCursor(start, time.Minute)
+	if got := c.Since(); !got.Equal(start.Add(-time.Minute)) {
+		t.Fatalf("want %v, got %v", start.Add(-time.Minute), got)
+	}
+
+	// first read
+	for _, id := range []string{"a", "b"} {
+		if !c.Next(id, start.Add(10*time.Second)) {
+			t.Errorf("%s should be new", id)
+		}
+	}
+	if got := c.Since(); !got.Equal(start.Add(-50 * time.Second)) {
+		t.Fatalf("want %v, got %v", start.Add(-50*time.Second), got)
+	}
+
+	// the next read returns b again, and c committed late
+	if c.Next("b", start.Add(10*time.Second)) {
+		t.Error("b was already read")
+	}
+	if !c.Next("c", start.Add(5*time.Second)) {
+		t.Error("c should be new")
+	}
+
+	// events out of the overlap are forgotten
+	c.Next("d", start.Add(2*time.Minute))
+	c.Since()
+	if len(c.seen) != 1 {
+		t.Errorf("want only d remembered, got %v", c.seen)
+	}
+}
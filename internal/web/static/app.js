@@ -0,0 +1,61 @@
+// The pages work without this script, which shows times relative to now and
+// loads older chirps in place.
+"use strict";
+
+const units = [
+  ["year", 365 * 24 * 3600],
+  ["month", 30 * 24 * 3600],
+  ["day", 24 * 3600],
+  ["hour", 3600],
+  ["minute", 60],
+];
+
+const relative = new Intl.RelativeTimeFormat(undefined, { numeric: "auto" });
+
+function relativeTimes(root) {
+  for (const time of root.querySelectorAll("time[datetime]")) {
+    const seconds = (new Date(time.dateTime) - Date.now()) / 1000;
+    if (Number.isNaN(seconds)) {
+      continue;
+    }
+    time.title = time.textContent;
+    const unit = units.find(([, size]) => Math.abs(seconds) >= size);
+    time.textContent = unit
+      ? relative.format(Math.round(seconds / unit[1]), unit[0])
+      : relative.format(0, "second");
+  }
+}
+
+async function loadOlder(event) {
+  const link = event.target.closest("a[data-more]");
+  if (!link) {
+    return;
+  }
+  event.preventDefault();
+  link.setAttribute("aria-busy", "true");
+  try {
+    const resp = await fetch(link.href);
+    if (!resp.ok) {
+      throw new Error(resp.statusText);
+    }
+    const page = new DOMParser().parseFromString(await resp.text(), "text/html");
+    const list = document.querySelector("ol.chirps");
+    for (const item of page.querySelectorAll("ol.chirps > li:not(.empty)")) {
+      relativeTimes(item);
+      list.append(document.adoptNode(item));
+    }
+    const older = page.querySelector("a[data-more]");
+    if (older) {
+      link.href = older.getAttribute("href");
+      link.removeAttribute("aria-busy");
+    } else {
+      link.remove();
+    }
+  } catch {
+    // Following the link still works.
+    window.location.href = link.href;
+  }
+}
+
+relativeTimes(document);
+document.addEventListener("click", loadOlder);
@@ -0,0 +1,226 @@
+// Package web renders the public pages of Chirpy, for browsers and for the
+// link previews of other sites.
+//
+// Templates and assets are embedded in the binary. Every page is parsed once,
+// in New, with the layout and the partials it shares with the other pages.
+// Pages work without JavaScript, which only enhances them.
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fonspa/go-http-server/internal/chirptext"
+)
+
+const (
+	PageTimeline = "timeline"
+	PageChirp    = "chirp"
+	PageProfile  = "profile"
+	PageLogin    = "login"
+	PageError    = "error"
+)
+
+var pages = []string{PageTimeline, PageChirp, PageProfile, PageLogin, PageError}
+
+// maxDescriptionLen is the length in runes of the descriptions of link
+// previews.
+const maxDescriptionLen = 200
+
+//go:embed templates
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// Meta describes a page: its title, and the Open Graph tags of its link
+// previews.
+type Meta struct {
+	Title       string
+	Description string
+	// URL is the absolute, canonical URL of the page.
+	URL string
+	// Type is the Open Graph type, like "website" or "article".
+	Type string
+	// Image is the absolute URL of the image of link previews.
+	Image string
+	// Feeds are the alternate feeds of the page.
+	Feeds []Feed
+	// Viewer is the name of the logged in user, empty for anonymous visitors.
+	Viewer  string
+	NoIndex bool
+}
+
+type Feed struct {
+	Title string
+	Type  string
+	URL   string
+}
+
+type Author struct {
+	Handle      string
+	DisplayName string
+	AvatarURL   string
+}
+
+type Media struct {
+	URL          string
+	ThumbnailURL string
+	ContentType  string
+	Width        int32
+	Height       int32
+}
+
+type Chirp struct {
+	ID        string
+	Body      string
+	Author    Author
+	CreatedAt time.Time
+	Media     []Media
+}
+
+type Profile struct {
+	Author
+	Bio         string
+	IsChirpyRed bool
+	Followers   int64
+	Following   int64
+	Chirps      int64
+	JoinedAt    time.Time
+}
+
+type TimelinePage struct {
+	Meta
+	Chirps []Chirp
+	// OlderURL links to the next page of chirps, if there is one.
+	OlderURL string
+}
+
+type ChirpPage struct {
+	Meta
+	Chirp Chirp
+}
+
+type ProfilePage struct {
+	Meta
+	Profile  Profile
+	Chirps   []Chirp
+	OlderURL string
+}
+
+type LoginPage struct {
+	Meta
+	Email string
+	Error string
+}
+
+type ErrorPage struct {
+	Meta
+	Status  int
+	Message string
+}
+
+// Renderer renders the pages.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+// New parses the templates of every page.
+func New() (*Renderer, error) {
+	funcs := template.FuncMap{
+		"body":     bodyHTML,
+		"date":     func(t time.Time) string { return t.UTC().Format("Jan 2, 2006 15:04 UTC") },
+		"iso":      func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+		"isImage":  func(m Media) bool { return strings.HasPrefix(m.ContentType, "image/") },
+		"truncate": Truncate,
+	}
+	base, err := template.New("layout.html").Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{pages: make(map[string]*template.Template, len(pages))}
+	for _, page := range pages {
+		t, err := base.Clone()
+		if err == nil {
+			t, err = t.ParseFS(templateFS, "templates/"+page+".html")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse page '%s': %w", page, err)
+		}
+		r.pages[page] = t
+	}
+	return r, nil
+}
+
+// Render writes a page, with the data of its type, like a ChirpPage for
+// PageChirp.
+func (r *Renderer) Render(w io.Writer, page string, data any) error {
+	t, ok := r.pages[page]
+	if !ok {
+		return fmt.Errorf("unknown page '%s'", page)
+	}
+	return t.Execute(w, data)
+}
+
+// Static serves the assets of the pages, which are mounted under /static/.
+func Static() http.Handler {
+	assets, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/static", http.FileServerFS(assets))
+}
+
+// Name returns the name an author is shown with: their display name, or their
+// handle if they have none.
+func (a Author) Name() string {
+	if a.DisplayName != "" {
+		return a.DisplayName
+	}
+	if a.Handle != "" {
+		return "@" + a.Handle
+	}
+	return "Someone"
+}
+
+// Truncate shortens a text to the descriptions of link previews, on a single
+// line.
+func Truncate(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxDescriptionLen {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxDescriptionLen-1])) + "…"
+}
+
+// bodyHTML returns the HTML of the body of a chirp: the text is escaped, line
+// breaks are kept and the mentions of local users link to their profile.
+func bodyHTML(body string) template.HTML {
+	var b strings.Builder
+	prev := 0
+	for _, e := range chirptext.Extract(body) {
+		b.WriteString(html.EscapeString(body[prev:e.Start]))
+		text := html.EscapeString(body[e.Start:e.End])
+		switch {
+		case e.Type == chirptext.EntityMention && !strings.Contains(e.Text, "@"):
+			fmt.Fprintf(&b, `<a class="mention" href="/users/%s">%s</a>`, html.EscapeString(url.PathEscape(e.Text)), text)
+		case e.Type == chirptext.EntityHashtag:
+			fmt.Fprintf(&b, `<span class="hashtag">%s</span>`, text)
+		default:
+			b.WriteString(text)
+		}
+		prev = e.End
+	}
+	b.WriteString(html.EscapeString(body[prev:]))
+	return template.HTML(strings.ReplaceAll(b.String(), "\n", "<br>"))
+}
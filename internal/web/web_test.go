@@ -0,0 +1,175 @@
+package web
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestPages(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	alice := Author{Handle: "alice", DisplayName: "Alice <3", AvatarURL: "https://cdn.example/avatar.png"}
+	chirps := []Chirp{
+		{
+			ID:        "22222222-2222-2222-2222-222222222222",
+			Body:      "<script>alert(\"pwned\")</script> hi @bob and @carol@remote.example #golang\nsecond line",
+			Author:    alice,
+			CreatedAt: created,
+			Media: []Media{
+				{URL: "https://cdn.example/cat.jpg", ThumbnailURL: "https://cdn.example/cat_thumb.jpg", ContentType: "image/jpeg", Width: 640, Height: 480},
+				{URL: "https://cdn.example/clip.mp4", ContentType: "video/mp4"},
+			},
+		},
+		{
+			ID:        "11111111-1111-1111-1111-111111111111",
+			Body:      "hello",
+			Author:    Author{},
+			CreatedAt: created.Add(-time.Hour),
+		},
+	}
+	profileFeeds := []Feed{
+		{Title: "Atom", Type: "application/atom+xml", URL: "https://chirpy.example/users/1/feed.atom"},
+		{Title: "RSS", Type: "application/rss+xml", URL: "https://chirpy.example/users/1/feed.rss"},
+	}
+
+	cases := []struct {
+		name string
+		page string
+		data any
+	}{
+		{
+			name: "timeline",
+			page: PageTimeline,
+			data: TimelinePage{
+				Meta:     Meta{Title: "Chirpy", Description: "The latest chirps", URL: "https://chirpy.example/", Type: "website"},
+				Chirps:   chirps,
+				OlderURL: "/?offset=50",
+			},
+		},
+		{
+			name: "timeline_empty",
+			page: PageTimeline,
+			data: TimelinePage{
+				Meta: Meta{Title: "Chirpy", URL: "https://chirpy.example/", Type: "website", Viewer: "Bob"},
+			},
+		},
+		{
+			name: "chirp",
+			page: PageChirp,
+			data: ChirpPage{
+				Meta: Meta{
+					Title:       "Alice <3 on Chirpy",
+					Description: Truncate(chirps[0].Body),
+					URL:         "https://chirpy.example/chirps/22222222-2222-2222-2222-222222222222",
+					Type:        "article",
+					Image:       "https://cdn.example/cat.jpg",
+				},
+				Chirp: chirps[0],
+			},
+		},
+		{
+			name: "profile",
+			page: PageProfile,
+			data: ProfilePage{
+				Meta: Meta{
+					Title:       "Alice <3 (@alice)",
+					Description: "Gopher & \"friend\"",
+					URL:         "https://chirpy.example/users/alice",
+					Type:        "profile",
+					Image:       alice.AvatarURL,
+					Feeds:       profileFeeds,
+				},
+				Profile: Profile{
+					Author:      alice,
+					Bio:         "Gopher & \"friend\"",
+					IsChirpyRed: true,
+					Followers:   12,
+					Following:   3,
+					Chirps:      2,
+					JoinedAt:    created.Add(-24 * time.Hour),
+				},
+				Chirps: chirps[:1],
+			},
+		},
+		{
+			name: "login",
+			page: PageLogin,
+			data: LoginPage{
+				Meta:  Meta{Title: "Log in to Chirpy", URL: "https://chirpy.example/login", Type: "website", NoIndex: true},
+				Email: "alice@example.com\"><script>",
+				Error: "Invalid email or password.",
+			},
+		},
+		{
+			name: "error",
+			page: PageError,
+			data: ErrorPage{
+				Meta:    Meta{Title: "Not found", URL: "https://chirpy.example/chirps/unknown", Type: "website", NoIndex: true},
+				Status:  http.StatusNotFound,
+				Message: "This chirp doesn't exist.",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := r.Render(&buf, c.page, c.data); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", c.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v, run the tests with -update to create it", err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("the page doesn't match %s, run the tests with -update if the change is expected:\n%s", golden, got)
+			}
+			if strings.Contains(buf.String(), "<script>") {
+				t.Error("user content should be escaped")
+			}
+		})
+	}
+
+	if err := r.Render(&bytes.Buffer{}, "unknown", nil); err == nil {
+		t.Error("want an error for an unknown page")
+	}
+}
+
+func TestStatic(t *testing.T) {
+	cases := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{path: "/static/style.css", status: http.StatusOK, contentType: "text/css; charset=utf-8"},
+		{path: "/static/app.js", status: http.StatusOK, contentType: "text/javascript; charset=utf-8"},
+		{path: "/static/missing.css", status: http.StatusNotFound},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		Static().ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%s: want status %d, got %d", c.path, c.status, w.Code)
+		}
+		if c.contentType != "" && w.Header().Get("Content-Type") != c.contentType {
+			t.Errorf("%s: want content type %s, got %s", c.path, c.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Package webhooks sends signed event notifications to the HTTP endpoints
+// registered by third-party integrations.
+//
+// Every request carries the time it was signed in the Chirpy-Timestamp
+// header, as Unix seconds, and in Chirpy-Signature the hex-encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret of the endpoint,
+// prefixed with "sha256=". Receivers should recompute it, compare it in
+// constant time, and reject timestamps too far in the past to prevent
+// replays.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderEvent     = "Chirpy-Event"
+	HeaderDelivery  = "Chirpy-Delivery"
+	HeaderTimestamp = "Chirpy-Timestamp"
+	HeaderSignature = "Chirpy-Signature"
+
+	signaturePrefix = "sha256="
+
+	// MaxAttempts is how many times a delivery is tried before it is moved
+	// to the dead letters.
+	MaxAttempts = 10
+	// Timeout bounds each attempt, from connection to response.
+	Timeout = 10 * time.Second
+	// maxResponseBody is how much of the responses is kept in the delivery
+	// history.
+	maxResponseBody = 1024
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 12 * time.Hour
+)
+
+var (
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredTimestamp = errors.New("webhook timestamp outside of tolerance")
+	errPrivateAddress   = errors.New("webhook URL resolves to a private address")
+)
+
+// GenerateSecret returns a new random signing secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// ValidateURL checks that rawURL can be registered as an endpoint.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Sign returns the signature of body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a delivery received
+// at now, as receivers are expected to.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	return VerifySignature(secret, header.Get(HeaderTimestamp), header.Get(HeaderSignature), body, tolerance, now)
+}
+
+// VerifySignature checks that signature is the signature of body sent at
+// timestamp, given in Unix seconds, and that timestamp is within tolerance
+// of now. Signatures are compared in constant time.
+func VerifySignature(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signedAt := time.Unix(unix, 0)
+	if d := now.Sub(signedAt); d > tolerance || d < -tolerance {
+		return ErrExpiredTimestamp
+	}
+	want := Sign(secret, signedAt, body)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before trying a delivery again after its
+// attempt-th attempt failed.
+func Backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Request is a delivery to send.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	Event      string
+	Body       []byte
+}
+
+// Result is the outcome of an attempt. Err is set when no response was
+// received or its status code wasn't 2xx.
+type Result struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+	Err        error
+}
+
+// OK reports whether the delivery succeeded.
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// Sender sends deliveries. Unless AllowPrivate is set, endpoints resolving
+// to loopback, private or link-local addresses are refused, so that
+// webhooks can't be used to reach the internal network of the server.
+type Sender struct {
+	AllowPrivate bool
+	client       *http.Client
+}
+
+func NewSender(allowPrivate bool) *Sender {
+	s := &Sender{AllowPrivate: allowPrivate}
+	dialer := &net.Dialer{Timeout: Timeout, Control: s.checkAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// Proxies would hide the address actually dialed
+	transport.Proxy = nil
+	s.client = &http.Client{
+		Transport: transport,
+		Timeout:   Timeout,
+		// Redirects are reported as failures rather than followed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// HTTPClient returns the client deliveries are sent with, for other requests
+// to URLs given by users.
+func (s *Sender) HTTPClient() *http.Client {
+	return s.client
+}
+
+// checkAddress is called with the resolved address of every connection.
+func (s *Sender) checkAddress(network, address string, _ syscall.RawConn) error {
+	if s.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(net.ParseIP(host)) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// reservedNets are the ranges that aren't routable on the internet, besides
+// the loopback, private, link-local and multicast ones.
+var reservedNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	// Carrier-grade NAT, shared by the hosts behind a provider's NAT
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	// Benchmarking, sometimes used for internal networks
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isPublic reports whether ip is reachable on the internet, so that
+// webhooks can't reach the services of the network of the server.
+func isPublic(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Send signs and posts req at now.
+func (s *Sender) Send(ctx context.Context, req Request, now time.Time) Result {
+	start := time.Now()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	httpReq.Header.Set(HeaderEvent, req.Event)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID)
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, now, req.Body))
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	res := Result{
+		StatusCode: resp.StatusCode,
+		Body:       strings.ToValidUTF8(string(body), ""),
+		Duration:   time.Since(start),
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		res.Err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return res
+}
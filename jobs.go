@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fonspa/go-http-server/internal/blob"
+)
+
+const (
+	schedulerInterval  = 10 * time.Second
+	purgeChirpInterval = time.Hour
+	// schedulerBatchSize bounds how many chirps a single scheduler run
+	// publishes, so that a backlog is drained over several runs.
+	schedulerBatchSize = 100
+)
+
+// runPeriodically calls job every interval until ctx is canceled. Errors are
+// logged and the job is retried at the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Printf("job '%s' failed: %v", name, err)
+			}
+		}
+	}
+}
+
+// publishDueChirps publishes the scheduled chirps whose time has come. Each
+// chirp is claimed with SKIP LOCKED and published in the transaction of its
+// events and timeline entries, so several server instances can run it
+// concurrently, and a chirp whose publication fails stays scheduled until
+// the next run.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) error {
+	n := 0
+	defer func() {
+		if n > 0 {
+			log.Printf("published %d scheduled chirps", n)
+		}
+	}()
+	for ; n < schedulerBatchSize; n++ {
+		published, err := cfg.publishDueChirp(ctx)
+		if err != nil || !published {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishDueChirp publishes the scheduled chirp due the longest ago, and
+// reports whether there was one.
+func (cfg *apiConfig) publishDueChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+	chirp, err := qtx.PublishDueChirp(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := cfg.chirpPublished(ctx, qtx, chirp); err != nil {
+		return false, fmt.Errorf("unable to publish chirp '%s': %w", chirp.ID, err)
+	}
+	return true, tx.Commit()
+}
+
+// purgeDeletedChirps permanently deletes the chirps soft-deleted more than the
+// retention period ago, along with the files of their media.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) error {
+	files, err := cfg.db.PurgeDeletedChirps(ctx, time.Now().UTC().Add(-cfg.chirpRetention))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		keys := []string{f.StorageKey}
+		if f.ThumbnailKey.Valid {
+			keys = append(keys, f.ThumbnailKey.String)
+		}
+		for _, key := range keys {
+			if err := cfg.blobStore.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+				log.Printf("unable to delete blob '%s' of purged chirp: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -1,20 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/fonspa/go-http-server/internal/activitypub"
+	"github.com/fonspa/go-http-server/internal/blob"
 	"github.com/fonspa/go-http-server/internal/database"
+	"github.com/fonspa/go-http-server/internal/entitlements"
+	"github.com/fonspa/go-http-server/internal/events"
+	"github.com/fonspa/go-http-server/internal/realtime"
+	"github.com/fonspa/go-http-server/internal/stream"
+	"github.com/fonspa/go-http-server/internal/timeline"
+	"github.com/fonspa/go-http-server/internal/trending"
+	"github.com/fonspa/go-http-server/internal/web"
+	"github.com/fonspa/go-http-server/internal/webhooks"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 const (
-	rootPath = "."
-	port     = "8080"
+	rootPath        = "."
+	port            = "8080"
+	shutdownTimeout = 10 * time.Second
+
+	defaultChirpRetention = 30 * 24 * time.Hour
+
+	defaultReportHideThreshold = 3
 )
 
 func main() {
@@ -36,6 +59,50 @@ func main() {
 		log.Fatalf("you must provide a POLKA_KEY")
 	}
 
+	tiers, err := loadEntitlements(os.Getenv("ENTITLEMENTS_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// CHIRP_UNDO_WINDOW predates tiers, and still sets the free undo window
+	if os.Getenv("CHIRP_UNDO_WINDOW") != "" {
+		undoWindow, err := durationFromEnv("CHIRP_UNDO_WINDOW", 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := tiers.SetUndoWindow(entitlements.TierFree, undoWindow); err != nil {
+			log.Fatal(err)
+		}
+	}
+	chirpRetention, err := durationFromEnv("CHIRP_RETENTION", defaultChirpRetention)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if chirpRetention < tiers.MaxUndoWindow() {
+		log.Fatalf("CHIRP_RETENTION must be at least the longest undo window, %v", tiers.MaxUndoWindow())
+	}
+
+	reportHideThreshold := defaultReportHideThreshold
+	if v := os.Getenv("REPORT_HIDE_THRESHOLD"); v != "" {
+		reportHideThreshold, err = strconv.Atoi(v)
+		if err != nil || reportHideThreshold < 1 {
+			log.Fatalf("invalid REPORT_HIDE_THRESHOLD '%s'", v)
+		}
+	}
+
+	timelineStrategy := os.Getenv("TIMELINE_STRATEGY")
+	if timelineStrategy == "" {
+		timelineStrategy = timeline.FanOutOnRead
+	}
+	homeTimeline, err := timeline.New(timelineStrategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blobStore, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("unable to set up media storage: %v", err)
+	}
+
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("unable to open the database: %v", err)
@@ -45,40 +112,271 @@ func main() {
 	}
 	dbQueries := database.New(db)
 
+	webhookSender := webhooks.NewSender(platform == "dev")
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	var federation *activitypub.Server
+	if publicURL != "" {
+		client := activitypub.NewClient(webhookSender.HTTPClient())
+		federation, err = activitypub.NewServer(publicURL, federationStore{db: dbQueries}, client)
+		if err != nil {
+			log.Fatalf("invalid PUBLIC_URL: %v", err)
+		}
+	}
+
+	pages, err := web.New()
+	if err != nil {
+		log.Fatalf("unable to parse the web pages: %v", err)
+	}
+
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		jwtSecret:      jwtSecret,
-		polkaKey:       polkaKey,
+		fileserverHits:      atomic.Int32{},
+		db:                  dbQueries,
+		dbConn:              db,
+		platform:            platform,
+		jwtSecret:           jwtSecret,
+		polkaKey:            polkaKey,
+		polkaLimiter:        webhooks.NewLimiter(polkaRatePerMinute, polkaRateBurst),
+		blobStore:           blobStore,
+		entitlements:        tiers,
+		chirpRetention:      chirpRetention,
+		reportHideThreshold: reportHideThreshold,
+		timeline:            homeTimeline,
+		trending:            newTrendingState(trending.SystemClock),
+		chirpStream:         stream.NewHub(streamReplaySize, streamBacklog),
+		realtime:            realtime.NewHub(wsMaxConnsPerUser, wsBacklog),
+		events:              events.NewBus(),
+		webhookSender:       webhookSender,
+		publicURL:           publicURL,
+		federation:          federation,
+		pages:               pages,
+	}
+	apiCfg.subscribeEvents()
+
+	// ctx is canceled when the server is asked to stop, which stops the
+	// background jobs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := apiCfg.loadFilter(ctx); err != nil {
+		log.Fatalf("unable to load the chirp filter: %v", err)
 	}
+	go runPeriodically(ctx, "reload filter", filterReloadInterval, apiCfg.loadFilter)
+	go runPeriodically(ctx, "publish scheduled chirps", schedulerInterval, apiCfg.publishDueChirps)
+	go runPeriodically(ctx, "purge deleted chirps", purgeChirpInterval, apiCfg.purgeDeletedChirps)
+	go runPeriodically(ctx, "lift expired user states", userStateJobInterval, apiCfg.liftExpiredUserStates)
+	go runPeriodically(ctx, "compute trends", trendingInterval, apiCfg.computeTrending)
+	go apiCfg.listenEvents(ctx, dbURL)
+	go runPeriodically(ctx, "dispatch events", eventsDispatchInterval, apiCfg.dispatchEvents)
+	go runPeriodically(ctx, "prune outbox", outboxPruneInterval, apiCfg.pruneOutbox)
+	go runPeriodically(ctx, "deliver webhooks", webhookDeliverInterval, apiCfg.deliverWebhooks)
+	go runPeriodically(ctx, "expire subscriptions", subscriptionExpiryInterval, apiCfg.expireSubscriptions)
 
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: apiCfg.routes(),
+	}
+	// Streams never go idle, so they are ended for Shutdown to return.
+	// WebSocket connections aren't tracked by Shutdown at all once hijacked.
+	srv.RegisterOnShutdown(apiCfg.chirpStream.Close)
+	srv.RegisterOnShutdown(apiCfg.realtime.Close)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+	// main func blocks until the server is asked to shut down
+	<-ctx.Done()
+	log.Print("shutting down the server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("unable to shut down the server gracefully: %v", err)
+	}
+}
+
+// routes returns the handler of every endpoint of the server.
+func (cfg *apiConfig) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	// FileServer
-	mux.Handle("/app/", http.StripPrefix("/app", apiCfg.middlewareMetricsInc(http.FileServer(http.Dir(rootPath)))))
+	mux.Handle("/app/", http.StripPrefix("/app", cfg.middlewareMetricsInc(http.FileServer(http.Dir(rootPath)))))
+	if local, ok := cfg.blobStore.(*blob.LocalStore); ok {
+		mux.Handle("GET /media/", http.StripPrefix("/media", noDirectoryListing(http.FileServer(http.Dir(local.Dir)))))
+	}
+	// Web pages
+	mux.Handle("GET /static/", web.Static())
+	mux.HandleFunc("GET /{$}", cfg.handlerWebTimeline)
+	mux.HandleFunc("GET /chirps/{chirpID}", cfg.handlerWebChirp)
+	mux.HandleFunc("GET /users/{handle}", cfg.handlerWebProfile)
+	mux.HandleFunc("GET /login", cfg.handlerWebLogin)
+	mux.HandleFunc("POST /login", cfg.handlerWebLoginSubmit)
+	mux.HandleFunc("POST /logout", cfg.handlerWebLogout)
 	// API GET
 	mux.HandleFunc("GET /api/healthz", handlerHealthz)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
+	mux.HandleFunc("GET /api/limits", cfg.handlerGetLimits)
+	mux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetChirp)
+	mux.HandleFunc("GET /api/chirps/scheduled", cfg.handlerGetScheduledChirps)
+	mux.HandleFunc("GET /api/chirps/stream", cfg.handlerStreamChirps)
+	mux.HandleFunc("GET /api/ws", cfg.handlerWebSocket)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", cfg.handlerGetChirpsByTag)
+	mux.HandleFunc("GET /api/users/{handle}", cfg.handlerGetProfile)
+	mux.HandleFunc("GET /api/users/{id}/mentions", cfg.handlerGetUserMentions)
+	mux.HandleFunc("GET /api/users/{id}/followers", cfg.handlerGetFollowers)
+	mux.HandleFunc("GET /api/users/{id}/following", cfg.handlerGetFollowing)
+	mux.HandleFunc("GET /api/timeline", cfg.handlerGetTimeline)
+	mux.HandleFunc("GET /api/trending", cfg.handlerGetTrending)
+	mux.HandleFunc("GET /api/bookmarks", cfg.handlerGetBookmarks)
+	mux.HandleFunc("GET /api/notifications", cfg.handlerGetNotifications)
+	mux.HandleFunc("GET /api/notifications/preferences", cfg.handlerGetNotificationPreferences)
+	mux.HandleFunc("GET /api/conversations", cfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/{id}/messages", cfg.handlerGetMessages)
+	mux.HandleFunc("GET /api/webhooks", cfg.handlerGetWebhooks)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.handlerGetWebhookDeliveries)
+	// Feeds
+	mux.HandleFunc("GET /users/{id}/feed.atom", cfg.handlerGetUserFeed)
+	mux.HandleFunc("GET /users/{id}/feed.rss", cfg.handlerGetUserFeed)
+	mux.HandleFunc("GET /users/{id}/feed.json", cfg.handlerGetUserFeed)
+	mux.HandleFunc("GET /tags/{tag}/feed.atom", cfg.handlerGetTagFeed)
+	mux.HandleFunc("GET /tags/{tag}/feed.rss", cfg.handlerGetTagFeed)
+	mux.HandleFunc("GET /tags/{tag}/feed.json", cfg.handlerGetTagFeed)
 	// API POST
-	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeRefreshToken)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
+	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
+	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
+	mux.HandleFunc("POST /api/media", cfg.handlerUploadMedia)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", cfg.handlerRestoreChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reports", cfg.handlerReportChirp)
+	mux.HandleFunc("POST /api/users/{id}/follow", cfg.handlerFollowUser)
+	mux.HandleFunc("POST /api/users/{id}/block", cfg.handlerBlockUser)
+	mux.HandleFunc("POST /api/users/{id}/mute", cfg.handlerMuteUser)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.handlerLikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", cfg.handlerBookmarkChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", cfg.handlerPinChirp)
+	mux.HandleFunc("POST /api/notifications/read", cfg.handlerMarkNotificationsRead)
+	mux.HandleFunc("POST /api/conversations", cfg.handlerCreateConversation)
+	mux.HandleFunc("POST /api/conversations/{id}/messages", cfg.handlerCreateMessage)
+	mux.HandleFunc("POST /api/conversations/{id}/read", cfg.handlerMarkConversationRead)
+	mux.HandleFunc("POST /api/webhooks", cfg.handlerCreateWebhook)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/test", cfg.handlerTestWebhook)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/retry", cfg.handlerRetryWebhookDelivery)
+	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
+	mux.HandleFunc("POST /api/refresh", cfg.handlerRefreshToken)
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevokeRefreshToken)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerPolkaWebhook)
 	// API PUT
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	mux.HandleFunc("PUT /api/chirps/scheduled/{chirpID}", cfg.handlerUpdateScheduledChirp)
+	mux.HandleFunc("PUT /api/notifications/preferences", cfg.handlerUpdateNotificationPreferences)
+	// ADMIN PUT
+	mux.HandleFunc("PUT /admin/users/{userID}/state", cfg.handlerSetUserState)
+	// API PATCH
+	mux.HandleFunc("PATCH /api/users/me/profile", cfg.handlerUpdateProfile)
 	// API DELETE
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", cfg.handlerUnfollowUser)
+	mux.HandleFunc("DELETE /api/users/{id}/block", cfg.handlerUnblockUser)
+	mux.HandleFunc("DELETE /api/users/{id}/mute", cfg.handlerUnmuteUser)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.handlerUnlikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", cfg.handlerRemoveBookmark)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", cfg.handlerUnpinChirp)
+	mux.HandleFunc("DELETE /api/conversations/{id}/messages/{messageID}", cfg.handlerDeleteMessage)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.handlerDeleteWebhook)
 	// ADMIN GET
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerDisplayMetrics)
+	mux.HandleFunc("GET /admin/metrics", cfg.handlerDisplayMetrics)
+	mux.HandleFunc("GET /admin/filters", cfg.handlerGetFilterTerms)
+	mux.HandleFunc("GET /admin/moderation", cfg.handlerGetModerationQueue)
+	mux.HandleFunc("GET /admin/moderation/actions", cfg.handlerGetModerationActions)
+	mux.HandleFunc("GET /admin/users/{userID}/state", cfg.handlerGetUserStateChanges)
 	// ADMIN POST
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerDeleteAllUsers)
-	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+	mux.HandleFunc("POST /admin/reset", cfg.handlerDeleteAllUsers)
+	mux.HandleFunc("POST /admin/filters", cfg.handlerUpsertFilterTerm)
+	mux.HandleFunc("POST /admin/moderation/{chirpID}", cfg.handlerModerateChirp)
+	// ADMIN DELETE
+	mux.HandleFunc("DELETE /admin/filters/{termID}", cfg.handlerDeleteFilterTerm)
+	mux.HandleFunc("DELETE /admin/users/{userID}/state", cfg.handlerClearUserState)
+	if cfg.federation != nil {
+		// ActivityPub
+		mux.HandleFunc("GET /.well-known/webfinger", cfg.federation.HandleWebFinger)
+		mux.HandleFunc("GET /ap/users/{username}", cfg.federation.HandleActor)
+		mux.HandleFunc("GET /ap/users/{username}/outbox", cfg.federation.HandleOutbox)
+		mux.HandleFunc("GET /ap/users/{username}/followers", cfg.federation.HandleFollowers)
+		mux.HandleFunc("GET /ap/notes/{id}", cfg.federation.HandleNote)
+		mux.HandleFunc("POST /ap/users/{username}/inbox", cfg.federation.HandleInbox)
+		mux.HandleFunc("POST /ap/inbox", cfg.federation.HandleInbox)
+		mux.HandleFunc("GET /api/remote-chirps", cfg.handlerGetRemoteChirps)
+		mux.HandleFunc("POST /api/remote-follows", cfg.handlerFollowRemote)
+		mux.HandleFunc("POST /api/remote-chirps/{chirpID}/like", cfg.handlerLikeRemoteChirp)
+	}
+	return mux
+}
+
+// noDirectoryListing serves the files of h but not the listings of its
+// directories, so that uploads can't be enumerated.
+func noDirectoryListing(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newBlobStore returns the storage backend for uploaded media, selected with
+// MEDIA_STORE: "local" (the default) or "s3".
+func newBlobStore() (blob.Store, error) {
+	switch store := os.Getenv("MEDIA_STORE"); store {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		return blob.NewLocalStore(dir, "/media")
+	case "s3":
+		s3 := &blob.S3Store{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		}
+		if s3.Endpoint == "" || s3.Bucket == "" || s3.Region == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET and S3_REGION are required with MEDIA_STORE=s3")
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORE '%s'", store)
+	}
+}
+
+// loadEntitlements returns the tier definitions overridden by the JSON file
+// at path, or the defaults if path is empty.
+func loadEntitlements(path string) (*entitlements.Config, error) {
+	if path == "" {
+		return entitlements.Default(), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open ENTITLEMENTS_FILE: %w", err)
+	}
+	defer f.Close()
+	tiers, err := entitlements.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENTITLEMENTS_FILE: %w", err)
+	}
+	return tiers, nil
+}
+
+// durationFromEnv parses the duration in the environment variable name, like
+// "90s" or "720h", or returns def if it isn't set.
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s' for %s", v, name)
 	}
-	// main func blocks until the server is shut down
-	log.Fatal(srv.ListenAndServe())
+	return d, nil
 }
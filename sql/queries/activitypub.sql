@@ -0,0 +1,130 @@
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = $1;
+--
+
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key, private_key)
+VALUES ($1, NOW() AT TIME ZONE 'utc', $2, $3)
+ON CONFLICT (user_id) DO NOTHING;
+--
+
+-- name: GetFederatedChirpsByUserID :many
+-- GetFederatedChirpsByUserID returns the latest public chirps of a user,
+-- newest first.
+SELECT * FROM chirps
+WHERE user_id = @user_id AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+ORDER BY created_at DESC
+LIMIT @max_chirps;
+--
+
+-- name: GetRemoteActorByURI :one
+SELECT * FROM remote_actors
+WHERE uri = $1;
+--
+
+-- name: UpsertRemoteActor :exec
+INSERT INTO remote_actors (id, uri, username, inbox, shared_inbox, public_key, fetched_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW() AT TIME ZONE 'utc')
+ON CONFLICT (uri) DO UPDATE SET
+    username = EXCLUDED.username,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key = EXCLUDED.public_key,
+    fetched_at = EXCLUDED.fetched_at;
+--
+
+-- name: GetRemoteFollowers :many
+SELECT remote_actors.* FROM remote_actors
+JOIN remote_followers ON remote_followers.remote_actor_id = remote_actors.id
+WHERE remote_followers.user_id = $1;
+--
+
+-- name: AddRemoteFollower :exec
+INSERT INTO remote_followers (user_id, remote_actor_id, follow_uri, created_at)
+SELECT @user_id, remote_actors.id, @follow_uri, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = @actor_uri
+ON CONFLICT (user_id, remote_actor_id) DO UPDATE SET follow_uri = EXCLUDED.follow_uri;
+--
+
+-- name: RemoveRemoteFollower :exec
+DELETE FROM remote_followers
+USING remote_actors
+WHERE remote_followers.remote_actor_id = remote_actors.id
+AND remote_followers.user_id = @user_id AND remote_actors.uri = @actor_uri;
+--
+
+-- name: AddRemoteFollow :exec
+-- AddRemoteFollow records a new follow request, which replaces any previous
+-- one.
+INSERT INTO remote_follows (user_id, remote_actor_id, follow_uri, created_at)
+SELECT @user_id, remote_actors.id, @follow_uri, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = @actor_uri
+ON CONFLICT (user_id, remote_actor_id) DO UPDATE SET
+    follow_uri = EXCLUDED.follow_uri,
+    created_at = EXCLUDED.created_at,
+    accepted_at = NULL;
+--
+
+-- name: AcceptRemoteFollow :execrows
+UPDATE remote_follows
+SET accepted_at = NOW() AT TIME ZONE 'utc'
+FROM remote_actors
+WHERE remote_follows.remote_actor_id = remote_actors.id
+AND remote_follows.follow_uri = @follow_uri AND remote_actors.uri = @actor_uri;
+--
+
+-- name: IsRemoteActorFollowed :one
+SELECT EXISTS (
+    SELECT 1 FROM remote_follows
+    JOIN remote_actors ON remote_actors.id = remote_follows.remote_actor_id
+    WHERE remote_actors.uri = @actor_uri AND remote_follows.accepted_at IS NOT NULL
+);
+--
+
+-- name: CreateRemoteChirp :exec
+INSERT INTO remote_chirps (id, uri, remote_actor_id, body, url, published_at, created_at)
+SELECT gen_random_uuid(), @uri, remote_actors.id, @body, @url, @published_at, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = @actor_uri
+ON CONFLICT (uri) DO NOTHING;
+--
+
+-- name: DeleteRemoteChirp :exec
+DELETE FROM remote_chirps
+USING remote_actors
+WHERE remote_chirps.remote_actor_id = remote_actors.id
+AND remote_chirps.uri = @uri AND remote_actors.uri = @actor_uri;
+--
+
+-- name: GetRemoteChirpByID :one
+SELECT remote_chirps.*, remote_actors.uri AS actor_uri
+FROM remote_chirps
+JOIN remote_actors ON remote_actors.id = remote_chirps.remote_actor_id
+WHERE remote_chirps.id = $1;
+--
+
+-- name: GetRemoteTimeline :many
+-- GetRemoteTimeline returns the remote chirps of the actors a user follows,
+-- newest first.
+SELECT remote_chirps.*, remote_actors.uri AS actor_uri, remote_actors.username AS actor_username
+FROM remote_chirps
+JOIN remote_actors ON remote_actors.id = remote_chirps.remote_actor_id
+JOIN remote_follows ON remote_follows.remote_actor_id = remote_chirps.remote_actor_id
+WHERE remote_follows.user_id = $1 AND remote_follows.accepted_at IS NOT NULL
+ORDER BY remote_chirps.published_at DESC
+LIMIT $2 OFFSET $3;
+--
+
+-- name: AddRemoteLike :exec
+INSERT INTO remote_likes (chirp_id, remote_actor_id, like_uri, created_at)
+SELECT @chirp_id, remote_actors.id, @like_uri, NOW() AT TIME ZONE 'utc'
+FROM remote_actors WHERE remote_actors.uri = @actor_uri
+ON CONFLICT (chirp_id, remote_actor_id) DO NOTHING;
+--
+
+-- name: RemoveRemoteLike :exec
+DELETE FROM remote_likes
+USING remote_actors
+WHERE remote_likes.remote_actor_id = remote_actors.id
+AND remote_likes.chirp_id = @chirp_id AND remote_actors.uri = @actor_uri;
+--
@@ -0,0 +1,52 @@
+-- name: BookmarkChirp :exec
+INSERT INTO chirp_bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING;
+--
+
+-- name: RemoveBookmark :exec
+DELETE FROM chirp_bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+--
+
+-- name: GetBookmarkedChirps :many
+SELECT chirps.* FROM chirp_bookmarks
+JOIN chirps ON chirps.id = chirp_bookmarks.chirp_id
+WHERE chirp_bookmarks.user_id = @user_id
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = @user_id OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @user_id AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @user_id)
+)
+ORDER BY chirp_bookmarks.created_at DESC
+LIMIT @page_size OFFSET @page_offset;
+--
+
+-- name: PinChirp :execrows
+-- The chirp is only pinned if its author has less than max_pins pinned
+-- chirps. The count is only reliable with the author locked, see LockUser.
+INSERT INTO chirp_pins (chirp_id, user_id, created_at)
+SELECT @chirp_id::uuid, @user_id::uuid, NOW() AT TIME ZONE 'utc'
+WHERE (SELECT COUNT(*) FROM chirp_pins WHERE chirp_pins.user_id = @user_id::uuid) < @max_pins::int
+ON CONFLICT DO NOTHING;
+--
+
+-- name: UnpinChirp :exec
+DELETE FROM chirp_pins
+WHERE chirp_id = $1 AND user_id = $2;
+--
+
+-- name: IsChirpPinned :one
+SELECT EXISTS (SELECT 1 FROM chirp_pins WHERE chirp_id = $1)::boolean AS pinned;
+--
+
+-- name: GetPinnedChirpIDs :many
+SELECT chirp_id FROM chirp_pins
+WHERE user_id = $1
+ORDER BY created_at DESC;
+--
@@ -0,0 +1,75 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+--
+
+-- name: AddChirpMention :exec
+-- Mentions between users who blocked each other aren't recorded.
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT chirps.id, @user_id::uuid FROM chirps
+WHERE chirps.id = @chirp_id::uuid
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @user_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @user_id::uuid)
+)
+ON CONFLICT DO NOTHING;
+--
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = @tag AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at ASC;
+--
+
+-- name: GetChirpsMentioningUser :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = @user_id AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY chirps.created_at ASC;
+--
+
+-- name: DeleteChirpEntities :exec
+WITH deleted_hashtags AS (
+    DELETE FROM chirp_hashtags WHERE chirp_hashtags.chirp_id = $1
+)
+DELETE FROM chirp_mentions
+WHERE chirp_mentions.chirp_id = $1;
+--
+
+-- name: ClaimChirpMentions :many
+-- ClaimChirpMentions marks the mentions of a chirp as notified, and returns
+-- the users who weren't notified yet.
+UPDATE chirp_mentions
+SET notified = TRUE
+WHERE chirp_id = $1 AND NOT notified
+RETURNING user_id;
+--
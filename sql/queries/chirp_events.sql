@@ -0,0 +1,25 @@
+-- name: NotifyChirpEvent :exec
+-- The notification is sent when the surrounding transaction commits, if any.
+SELECT pg_notify('chirp_events', json_build_object(
+    'id', nextval('chirp_event_ids'),
+    'type', @type::text,
+    'chirp_id', @chirp_id::uuid,
+    'author_id', @author_id::uuid
+)::text);
+--
+
+-- name: GetHiddenAuthorIDs :many
+-- GetHiddenAuthorIDs returns the users whose chirps viewer doesn't see
+-- because of a block, either way, or a mute.
+SELECT blocks.blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = @viewer_id::uuid
+UNION
+SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @viewer_id::uuid
+UNION
+SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @viewer_id::uuid;
+--
+
+-- name: NotifyNotificationEvent :exec
+-- NotifyNotificationEvent tells live connections that user has a new
+-- notification, when the surrounding transaction commits.
+SELECT pg_notify('notification_events', CAST(@user_id::uuid AS text));
+--
@@ -1,32 +1,203 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW() AT TIME ZONE 'utc',
     NOW() AT TIME ZONE 'utc',
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 --
 
 -- name: GetAllChirps :many
 SELECT * from chirps
+WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC;
 --
 
 -- name: GetChirpsByUserID :many
 SELECT * from chirps
-WHERE user_id = $1
+WHERE user_id = @user_id AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
 ORDER BY created_at ASC;
 --
 
+-- name: GetLatestChirps :many
+-- A page of the chirps of GetAllChirps, newest first.
+SELECT * from chirps
+WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at DESC
+LIMIT @page_size OFFSET @page_offset;
+--
+
+-- name: GetLatestChirpsByUserID :many
+-- A page of the chirps of GetChirpsByUserID, newest first.
+SELECT * from chirps
+WHERE user_id = @user_id AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+AND (chirps.user_id = @viewer_id::uuid OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at DESC
+LIMIT @page_size OFFSET @page_offset;
+--
+
 -- name: GetChirpByID :one
 SELECT * from chirps
 WHERE id = $1;
 --
 
--- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1;
+-- name: LockChirp :one
+-- LockChirp returns a chirp like GetChirpByID, locking it until the end of
+-- the transaction.
+SELECT * from chirps
+WHERE id = $1
+FOR UPDATE;
+--
+
+-- name: SoftDeleteChirp :exec
+-- Deleting a chirp unpins it, and restoring it doesn't pin it again.
+WITH unpinned AS (
+    DELETE FROM chirp_pins WHERE chirp_pins.chirp_id = $1
+)
+UPDATE chirps
+SET deleted_at = NOW() AT TIME ZONE 'utc', updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND deleted_at IS NULL;
+--
+
+-- name: ModeratorDeleteChirp :execrows
+-- Chirps already deleted by their author are marked too, so that they can't
+-- be restored.
+WITH unpinned AS (
+    DELETE FROM chirp_pins WHERE chirp_pins.chirp_id = $1
+)
+UPDATE chirps
+SET
+    deleted_at = COALESCE(deleted_at, NOW() AT TIME ZONE 'utc'),
+    deleted_by_moderator = TRUE,
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND NOT deleted_by_moderator;
+--
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND deleted_at IS NOT NULL AND NOT deleted_by_moderator
+RETURNING *;
+--
+
+-- name: PurgeDeletedChirps :many
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE deleted_at < @deleted_before::timestamp
+    RETURNING id
+)
+SELECT media_files.storage_key, media_files.thumbnail_key
+FROM media_files
+JOIN purged ON media_files.chirp_id = purged.id;
+--
+
+-- name: GetUnpublishedChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL
+ORDER BY publish_at ASC NULLS LAST, created_at ASC;
+--
+
+-- name: UpdateUnpublishedChirp :one
+UPDATE chirps
+SET
+    body = $1,
+    status = $2,
+    publish_at = $3,
+    created_at = CASE WHEN $2 = 'published' THEN NOW() AT TIME ZONE 'utc' ELSE created_at END,
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $4 AND user_id = $5 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL
+RETURNING *;
+--
+
+-- name: DeleteUnpublishedChirp :many
+-- DeleteUnpublishedChirp returns the keys of the files of the media of the
+-- deleted chirp, like PurgeDeletedChirps.
+WITH deleted AS (
+    DELETE FROM chirps
+    WHERE chirps.id = $1 AND chirps.user_id = $2 AND chirps.status IN ('draft', 'scheduled')
+    RETURNING chirps.id
+)
+SELECT media_files.storage_key, media_files.thumbnail_key
+FROM media_files
+JOIN deleted ON media_files.chirp_id = deleted.id;
+--
+
+-- name: PublishDueChirp :one
+-- PublishDueChirp claims the scheduled chirp due the longest ago, skipping
+-- those claimed by other transactions, and publishes it. It returns no row
+-- when no chirp is due.
+UPDATE chirps
+SET
+    status = 'published',
+    created_at = NOW() AT TIME ZONE 'utc',
+    updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW() AT TIME ZONE 'utc' AND deleted_at IS NULL
+    ORDER BY publish_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+--
+
+-- name: CountChirpsByUserIDSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = @user_id AND created_at >= @since;
 --
@@ -0,0 +1,96 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc')
+RETURNING *;
+--
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc');
+--
+
+-- name: GetConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = $1
+ORDER BY joined_at ASC, user_id ASC;
+--
+
+-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+)::boolean AS participant;
+--
+
+-- name: GetConversationsByUserID :many
+SELECT
+    conversations.*,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id != @user_id
+        AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+        AND NOT EXISTS (
+            SELECT 1 FROM message_deletions
+            WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = @user_id
+        )
+    )::bigint AS unread_count
+FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversation_participants.user_id = @user_id
+ORDER BY conversations.updated_at DESC
+LIMIT @page_size OFFSET @page_offset;
+--
+
+-- name: CreateMessage :one
+-- The message is only created if no other participant and the sender
+-- blocked each other.
+WITH message AS (
+    INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+    SELECT gen_random_uuid(), @conversation_id::uuid, @sender_id::uuid, @body::text, NOW() AT TIME ZONE 'utc'
+    WHERE NOT EXISTS (
+        SELECT 1 FROM conversation_participants
+        JOIN blocks ON (blocks.blocker_id = conversation_participants.user_id AND blocks.blocked_id = @sender_id::uuid)
+            OR (blocks.blocker_id = @sender_id::uuid AND blocks.blocked_id = conversation_participants.user_id)
+        WHERE conversation_participants.conversation_id = @conversation_id::uuid
+    )
+    RETURNING *
+), touched AS (
+    UPDATE conversations
+    SET updated_at = message.created_at
+    FROM message
+    WHERE conversations.id = message.conversation_id
+)
+SELECT * FROM message;
+--
+
+-- name: GetMessages :many
+SELECT messages.* FROM messages
+WHERE messages.conversation_id = @conversation_id
+AND NOT EXISTS (
+    SELECT 1 FROM message_deletions
+    WHERE message_deletions.message_id = messages.id AND message_deletions.user_id = @user_id
+)
+AND (
+    sqlc.narg(before_id)::uuid IS NULL
+    OR (messages.created_at, messages.id) < (
+        SELECT before.created_at, before.id FROM messages AS before
+        WHERE before.id = sqlc.narg(before_id)::uuid
+    )
+)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT @page_size;
+--
+
+-- name: DeleteMessageForUser :execrows
+INSERT INTO message_deletions (message_id, user_id, created_at)
+SELECT messages.id, @user_id::uuid, NOW() AT TIME ZONE 'utc' FROM messages
+WHERE messages.id = @message_id::uuid AND messages.conversation_id = @conversation_id::uuid
+ON CONFLICT DO NOTHING;
+--
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = NOW() AT TIME ZONE 'utc'
+WHERE conversation_id = $1 AND user_id = $2;
+--
@@ -0,0 +1,42 @@
+-- name: GetUserFeedUpdatedAt :one
+-- The last change to the published chirps of a user, deleting and hiding
+-- them included, or to the user.
+SELECT GREATEST(users.updated_at, COALESCE(MAX(chirps.updated_at), users.updated_at))::timestamp AS updated_at
+FROM users
+LEFT JOIN chirps ON chirps.user_id = users.id AND chirps.status = 'published'
+WHERE users.id = $1
+GROUP BY users.id;
+--
+
+-- name: GetTagFeedUpdatedAt :one
+-- The last change to the published chirps with a hashtag or to their
+-- authors, or the epoch if there is none.
+SELECT COALESCE(MAX(GREATEST(chirps.updated_at, users.updated_at)), 'epoch')::timestamp AS updated_at
+FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.tag = $1 AND chirps.status = 'published';
+--
+
+-- name: GetUserFeedChirps :many
+-- The latest public chirps of a user, newest first.
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = @user_id AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND users.state <> 'shadow_banned'
+ORDER BY chirps.created_at DESC
+LIMIT @max_chirps;
+--
+
+-- name: GetTagFeedChirps :many
+-- The latest public chirps with a hashtag, newest first.
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.tag = @tag AND chirps.status = 'published'
+AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND users.state <> 'shadow_banned'
+ORDER BY chirps.created_at DESC
+LIMIT @max_chirps;
+--
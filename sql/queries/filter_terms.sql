@@ -0,0 +1,29 @@
+-- name: GetFilterTerms :many
+SELECT * FROM filter_terms
+ORDER BY term ASC;
+--
+
+-- name: UpsertFilterTerm :one
+INSERT INTO filter_terms (id, created_at, updated_at, term, action)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2
+)
+ON CONFLICT (term) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW() AT TIME ZONE 'utc'
+RETURNING *;
+--
+
+-- name: DeleteFilterTerm :execrows
+DELETE FROM filter_terms
+WHERE id = $1;
+--
+
+-- name: AddChirpFilterFlag :exec
+INSERT INTO chirp_filter_flags (chirp_id, term, created_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING;
+--
@@ -0,0 +1,35 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING;
+--
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+--
+
+-- name: GetFollowers :many
+SELECT follower_id AS user_id, created_at FROM follows
+WHERE followee_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+--
+
+-- name: GetFollowerIDs :many
+SELECT follower_id FROM follows
+WHERE followee_id = $1;
+--
+
+-- name: GetFollowing :many
+SELECT followee_id AS user_id, created_at FROM follows
+WHERE follower_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+--
+
+-- name: GetFollowCounts :one
+SELECT
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = $1)::bigint AS followers,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = $1)::bigint AS following;
+--
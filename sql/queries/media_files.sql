@@ -0,0 +1,39 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, created_at, user_id, content_type, size_bytes, storage_key, thumbnail_key, width, height)
+VALUES (
+    $1,
+    NOW() AT TIME ZONE 'utc',
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+--
+
+-- name: AttachMediaFileToChirp :one
+UPDATE media_files
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL
+RETURNING *;
+--
+
+-- name: DetachMediaFilesFromChirp :exec
+UPDATE media_files
+SET chirp_id = NULL, position = 0
+WHERE chirp_id = $1;
+--
+
+-- name: GetMediaFilesForChirps :many
+SELECT * FROM media_files
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, position ASC;
+--
+
+-- name: GetMediaFileByID :one
+SELECT * FROM media_files
+WHERE id = $1;
+--
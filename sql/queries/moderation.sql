@@ -0,0 +1,93 @@
+-- name: CreateChirpReport :execrows
+INSERT INTO chirp_reports (id, created_at, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING;
+--
+
+-- name: CountOpenChirpReports :one
+SELECT COUNT(*) FROM chirp_reports
+WHERE chirp_id = $1 AND resolved_at IS NULL;
+--
+
+-- name: ResolveChirpReports :exec
+WITH resolved_flags AS (
+    UPDATE chirp_filter_flags
+    SET resolved_at = NOW() AT TIME ZONE 'utc'
+    WHERE chirp_filter_flags.chirp_id = $1 AND chirp_filter_flags.resolved_at IS NULL
+)
+UPDATE chirp_reports
+SET resolved_at = NOW() AT TIME ZONE 'utc'
+WHERE chirp_reports.chirp_id = $1 AND chirp_reports.resolved_at IS NULL;
+--
+
+-- name: GetModerationQueue :many
+SELECT
+    chirps.id,
+    chirps.created_at,
+    chirps.body,
+    chirps.user_id,
+    chirps.hidden_at,
+    (
+        SELECT COUNT(*) FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    )::bigint AS report_count,
+    COALESCE((
+        SELECT array_agg(DISTINCT chirp_reports.reason) FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    ), '{}')::text[] AS reasons,
+    COALESCE((
+        SELECT array_agg(chirp_filter_flags.term) FROM chirp_filter_flags
+        WHERE chirp_filter_flags.chirp_id = chirps.id AND chirp_filter_flags.resolved_at IS NULL
+    ), '{}')::text[] AS flagged_terms
+FROM chirps
+WHERE chirps.deleted_at IS NULL AND (
+    EXISTS (
+        SELECT 1 FROM chirp_reports
+        WHERE chirp_reports.chirp_id = chirps.id AND chirp_reports.resolved_at IS NULL
+    ) OR EXISTS (
+        SELECT 1 FROM chirp_filter_flags
+        WHERE chirp_filter_flags.chirp_id = chirps.id AND chirp_filter_flags.resolved_at IS NULL
+    )
+)
+ORDER BY report_count DESC, chirps.created_at ASC
+LIMIT $1 OFFSET $2;
+--
+
+-- name: HideChirp :execrows
+UPDATE chirps
+SET hidden_at = NOW() AT TIME ZONE 'utc', updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND hidden_at IS NULL;
+--
+
+-- name: UnhideChirp :exec
+UPDATE chirps
+SET hidden_at = NULL, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1;
+--
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, chirp_id, target_user_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+--
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+--
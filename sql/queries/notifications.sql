@@ -0,0 +1,110 @@
+-- name: Notify :execrows
+-- Notify adds actor to the unread notification of recipient with the same
+-- group key, creating it if needed. Nothing is recorded for users who blocked
+-- each other, actors muted by the recipient or shadow banned, or disabled
+-- notification types. No row is affected when the actor was already in the
+-- notification.
+WITH allowed AS (
+    SELECT 1 WHERE @recipient_id::uuid != @actor_id::uuid
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = @recipient_id::uuid AND blocks.blocked_id = @actor_id::uuid)
+        OR (blocks.blocker_id = @actor_id::uuid AND blocks.blocked_id = @recipient_id::uuid)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = @recipient_id::uuid AND mutes.muted_id = @actor_id::uuid
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM notification_preferences
+        WHERE notification_preferences.user_id = @recipient_id::uuid
+        AND notification_preferences.type = @type::text AND NOT notification_preferences.enabled
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = @actor_id::uuid AND users.state = 'shadow_banned'
+    )
+), notification AS (
+    INSERT INTO notifications (id, user_id, type, chirp_id, group_key, created_at, updated_at)
+    SELECT gen_random_uuid(), @recipient_id::uuid, @type::text, sqlc.narg(chirp_id)::uuid, @group_key::text,
+        NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc'
+    FROM allowed
+    ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+    DO UPDATE SET updated_at = EXCLUDED.updated_at
+    RETURNING id
+)
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+SELECT notification.id, @actor_id::uuid, NOW() AT TIME ZONE 'utc' FROM notification
+ON CONFLICT DO NOTHING;
+--
+
+-- name: GetNotifications :many
+-- Actors blocked or muted since the event are left out, along with the
+-- notifications that have no other actor.
+WITH visible_actors AS (
+    SELECT notification_actors.* FROM notification_actors
+    JOIN notifications ON notifications.id = notification_actors.notification_id
+    WHERE notifications.user_id = @user_id
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = @user_id AND blocks.blocked_id = notification_actors.actor_id)
+        OR (blocks.blocker_id = notification_actors.actor_id AND blocks.blocked_id = @user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = @user_id AND mutes.muted_id = notification_actors.actor_id
+    )
+)
+SELECT
+    notifications.id, notifications.type, notifications.chirp_id,
+    notifications.created_at, notifications.updated_at, notifications.read_at,
+    (SELECT COUNT(*) FROM visible_actors WHERE visible_actors.notification_id = notifications.id)::bigint AS actor_count,
+    ARRAY(
+        SELECT visible_actors.actor_id FROM visible_actors
+        WHERE visible_actors.notification_id = notifications.id
+        ORDER BY visible_actors.created_at DESC
+        LIMIT 3
+    )::uuid[] AS recent_actor_ids
+FROM notifications
+WHERE notifications.user_id = @user_id
+AND (NOT @unread_only::boolean OR notifications.read_at IS NULL)
+AND EXISTS (SELECT 1 FROM visible_actors WHERE visible_actors.notification_id = notifications.id)
+ORDER BY notifications.updated_at DESC
+LIMIT @page_size OFFSET @page_offset;
+--
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE notifications.user_id = @user_id AND notifications.read_at IS NULL
+AND EXISTS (
+    SELECT 1 FROM notification_actors
+    WHERE notification_actors.notification_id = notifications.id
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = @user_id AND blocks.blocked_id = notification_actors.actor_id)
+        OR (blocks.blocker_id = notification_actors.actor_id AND blocks.blocked_id = @user_id)
+    )
+    AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE mutes.muter_id = @user_id AND mutes.muted_id = notification_actors.actor_id
+    )
+);
+--
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW() AT TIME ZONE 'utc'
+WHERE user_id = @user_id AND read_at IS NULL
+AND (cardinality(@ids::uuid[]) = 0 OR id = ANY(@ids::uuid[]));
+--
+
+-- name: GetNotificationPreferences :many
+SELECT type, enabled FROM notification_preferences
+WHERE user_id = $1;
+--
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
+--
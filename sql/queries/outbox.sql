@@ -0,0 +1,75 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (id, created_at, type, payload)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2);
+--
+
+-- name: ExpandOutboxEvents :many
+-- ExpandOutboxEvents creates the deliveries of the events not expanded yet,
+-- one per subscriber of their type. Subscribers are passed as a JSON array
+-- of {"type": ..., "name": ...} objects.
+WITH pending AS (
+    SELECT id, type FROM outbox_events
+    WHERE expanded_at IS NULL
+    ORDER BY created_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+), deliveries AS (
+    INSERT INTO outbox_deliveries (event_id, subscriber, next_attempt_at)
+    SELECT pending.id, subscribers.name, NOW() AT TIME ZONE 'utc'
+    FROM pending
+    JOIN jsonb_to_recordset(@subscribers::jsonb) AS subscribers(type TEXT, name TEXT) ON subscribers.type = pending.type
+    ON CONFLICT DO NOTHING
+)
+UPDATE outbox_events SET expanded_at = NOW() AT TIME ZONE 'utc'
+FROM pending
+WHERE outbox_events.id = pending.id
+RETURNING outbox_events.id;
+--
+
+-- name: ClaimOutboxDeliveries :many
+-- ClaimOutboxDeliveries leases the due deliveries until lease_until, so
+-- that other server instances skip them while they are handled.
+WITH due AS (
+    SELECT outbox_deliveries.event_id, outbox_deliveries.subscriber FROM outbox_deliveries
+    WHERE outbox_deliveries.delivered_at IS NULL AND outbox_deliveries.failed_at IS NULL
+    AND outbox_deliveries.next_attempt_at <= NOW() AT TIME ZONE 'utc'
+    ORDER BY outbox_deliveries.next_attempt_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+), claimed AS (
+    UPDATE outbox_deliveries SET attempts = outbox_deliveries.attempts + 1, next_attempt_at = @lease_until
+    FROM due
+    WHERE outbox_deliveries.event_id = due.event_id AND outbox_deliveries.subscriber = due.subscriber
+    RETURNING outbox_deliveries.event_id, outbox_deliveries.subscriber, outbox_deliveries.attempts
+)
+SELECT claimed.event_id, claimed.subscriber, claimed.attempts,
+    outbox_events.created_at, outbox_events.type, outbox_events.payload
+FROM claimed
+JOIN outbox_events ON outbox_events.id = claimed.event_id;
+--
+
+-- name: MarkOutboxDelivered :exec
+UPDATE outbox_deliveries SET delivered_at = NOW() AT TIME ZONE 'utc', last_error = NULL
+WHERE event_id = $1 AND subscriber = $2;
+--
+
+-- name: RetryOutboxDelivery :exec
+UPDATE outbox_deliveries SET next_attempt_at = $3, last_error = $4
+WHERE event_id = $1 AND subscriber = $2;
+--
+
+-- name: FailOutboxDelivery :exec
+UPDATE outbox_deliveries SET failed_at = NOW() AT TIME ZONE 'utc', last_error = $3
+WHERE event_id = $1 AND subscriber = $2;
+--
+
+-- name: PruneOutboxEvents :execrows
+-- PruneOutboxEvents deletes the events created before cutoff that every
+-- subscriber handled. Failed deliveries are kept for inspection.
+DELETE FROM outbox_events
+WHERE outbox_events.created_at < @cutoff AND outbox_events.expanded_at IS NOT NULL
+AND NOT EXISTS (
+    SELECT 1 FROM outbox_deliveries
+    WHERE outbox_deliveries.event_id = outbox_events.id AND outbox_deliveries.delivered_at IS NULL
+);
+--
@@ -0,0 +1,11 @@
+-- name: CreatePolkaPayload :exec
+INSERT INTO polka_payloads (id, received_at, event_id, payload, outcome)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3);
+--
+
+-- name: MarkPolkaEventProcessed :execrows
+-- MarkPolkaEventProcessed affects no row if the event was already processed.
+INSERT INTO polka_processed_events (event_id, processed_at)
+VALUES ($1, NOW() AT TIME ZONE 'utc')
+ON CONFLICT DO NOTHING;
+--
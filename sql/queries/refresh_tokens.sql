@@ -23,3 +23,18 @@ SET
     revoked_at = NOW() AT TIME ZONE 'utc'
 WHERE token = $1;
 --
+
+-- name: RevokeExcessRefreshTokens :execrows
+-- RevokeExcessRefreshTokens revokes the refresh tokens of a user but the
+-- keep most recent active ones.
+UPDATE refresh_tokens
+SET
+    updated_at = NOW() AT TIME ZONE 'utc',
+    revoked_at = NOW() AT TIME ZONE 'utc'
+WHERE refresh_tokens.user_id = @user_id AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.token NOT IN (
+    SELECT recent.token FROM refresh_tokens AS recent
+    WHERE recent.user_id = @user_id AND recent.revoked_at IS NULL AND recent.expires_at > NOW() AT TIME ZONE 'utc'
+    ORDER BY recent.created_at DESC
+    LIMIT @keep
+);
+--
@@ -0,0 +1,41 @@
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+--
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', $1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE SET
+    updated_at = EXCLUDED.updated_at,
+    plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = EXCLUDED.canceled_at
+RETURNING *;
+--
+
+-- name: GetLapsedSubscriptions :many
+-- GetLapsedSubscriptions returns the subscriptions still granting Chirpy Red
+-- whose period ended before now.
+SELECT * FROM subscriptions
+WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end <= @now
+ORDER BY current_period_end
+LIMIT @batch_size
+FOR UPDATE SKIP LOCKED;
+--
+
+-- name: SyncUserChirpyRed :one
+-- SyncUserChirpyRed derives is_chirpy_red from the subscription of the user.
+UPDATE users
+SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due', 'canceled')
+    AND subscriptions.current_period_end > NOW() AT TIME ZONE 'utc'
+), updated_at = NOW() AT TIME ZONE 'utc'
+WHERE users.id = @user_id
+RETURNING *;
+--
@@ -0,0 +1,83 @@
+-- name: GetTimelineFanOutOnRead :many
+SELECT chirps.* FROM chirps
+WHERE (
+    chirps.user_id = @user_id
+    OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = @user_id)
+)
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = @user_id OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @user_id AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @user_id AND mutes.muted_id = chirps.user_id
+)
+AND (
+    sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_size;
+--
+
+-- name: GetTimelineFanOutOnWrite :many
+SELECT chirps.* FROM timeline_entries
+JOIN chirps ON chirps.id = timeline_entries.chirp_id
+WHERE timeline_entries.user_id = @user_id
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND (chirps.user_id = @user_id OR NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+))
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @user_id AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @user_id AND mutes.muted_id = chirps.user_id
+)
+AND (
+    sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (timeline_entries.created_at, timeline_entries.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+LIMIT @page_size;
+--
+
+-- name: FanOutChirp :exec
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT recipients.user_id, @chirp_id::uuid AS chirp_id, @created_at::timestamp AS created_at
+FROM (
+    SELECT follows.follower_id AS user_id FROM follows
+    WHERE follows.followee_id = @author_id::uuid
+    UNION
+    SELECT @author_id::uuid AS user_id
+) AS recipients
+ON CONFLICT DO NOTHING;
+--
+
+-- name: BackfillTimeline :exec
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT @follower_id::uuid AS user_id, recent.id, recent.created_at
+FROM (
+    SELECT chirps.id, chirps.created_at FROM chirps
+    WHERE chirps.user_id = @followee_id::uuid AND chirps.status = 'published' AND chirps.deleted_at IS NULL
+    ORDER BY chirps.created_at DESC
+    LIMIT @max_chirps::int
+) AS recent
+ON CONFLICT DO NOTHING;
+--
+
+-- name: RemoveFromTimeline :exec
+DELETE FROM timeline_entries
+WHERE timeline_entries.user_id = @follower_id
+AND timeline_entries.chirp_id IN (SELECT chirps.id FROM chirps WHERE chirps.user_id = @followee_id);
+--
@@ -0,0 +1,82 @@
+-- name: GetLikeEventsSince :many
+-- Likes of shadow-banned users don't count.
+SELECT chirp_likes.chirp_id, chirp_likes.user_id, chirp_likes.created_at FROM chirp_likes
+WHERE chirp_likes.created_at >= @since::timestamp
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirp_likes.user_id AND users.state = 'shadow_banned'
+)
+ORDER BY chirp_likes.created_at ASC;
+--
+
+-- name: GetHashtagEventsSince :many
+SELECT chirp_hashtags.tag, chirps.id AS chirp_id, chirps.created_at FROM chirp_hashtags
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at >= @since::timestamp AND chirps.status = 'published'
+ORDER BY chirps.created_at ASC;
+--
+
+-- name: GetTrendableChirpIDs :many
+-- GetTrendableChirpIDs returns the chirps among ids that can appear in
+-- trends: published, neither deleted nor hidden, by an author who isn't
+-- shadow-banned.
+SELECT chirps.id FROM chirps
+WHERE chirps.id = ANY(@ids::uuid[])
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+);
+--
+
+-- name: CreateTrendingSnapshot :one
+INSERT INTO trending_snapshots (id, time_window, computed_at)
+VALUES (gen_random_uuid(), $1, $2)
+RETURNING *;
+--
+
+-- name: AddTrendingItem :exec
+INSERT INTO trending_items (snapshot_id, kind, rank, tag, chirp_id, score)
+VALUES ($1, $2, $3, $4, $5, $6);
+--
+
+-- name: GetLatestTrendingSnapshot :one
+SELECT * FROM trending_snapshots
+WHERE time_window = $1
+ORDER BY computed_at DESC
+LIMIT 1;
+--
+
+-- name: GetTrendingHashtags :many
+SELECT tag::text AS tag, score FROM trending_items
+WHERE snapshot_id = $1 AND kind = 'hashtag'
+ORDER BY rank ASC;
+--
+
+-- name: GetTrendingChirps :many
+-- Chirps are filtered again, as they may have been hidden since the
+-- snapshot, and for the blocks and mutes of the viewer.
+SELECT chirps.* FROM trending_items
+JOIN chirps ON chirps.id = trending_items.chirp_id
+WHERE trending_items.snapshot_id = @snapshot_id AND trending_items.kind = 'chirp'
+AND chirps.status = 'published' AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.state = 'shadow_banned'
+)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = @viewer_id::uuid AND blocks.blocked_id = chirps.user_id)
+    OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = @viewer_id::uuid)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = @viewer_id::uuid AND mutes.muted_id = chirps.user_id
+)
+ORDER BY trending_items.rank ASC;
+--
+
+-- name: PruneTrendingSnapshots :exec
+DELETE FROM trending_snapshots
+WHERE computed_at < @computed_before::timestamp;
+--
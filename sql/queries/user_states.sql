@@ -0,0 +1,33 @@
+-- name: SetUserState :one
+UPDATE users
+SET state = $1, state_until = $2, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $3
+RETURNING *;
+--
+
+-- name: CreateUserStateChange :one
+INSERT INTO user_state_changes (id, created_at, user_id, changed_by, old_state, new_state, state_until, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW() AT TIME ZONE 'utc',
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+--
+
+-- name: GetUserStateChanges :many
+SELECT * FROM user_state_changes
+WHERE user_id = $1
+ORDER BY created_at DESC;
+--
+
+-- name: GetUsersWithExpiredState :many
+SELECT * FROM users
+WHERE state != 'active' AND state_until <= NOW() AT TIME ZONE 'utc'
+FOR UPDATE SKIP LOCKED;
+--
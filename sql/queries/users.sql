@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW() AT TIME ZONE 'utc',
     NOW() AT TIME ZONE 'utc',
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 --
@@ -26,9 +27,40 @@ WHERE id = $3
 RETURNING *;
 --
 
--- name: UpgradeUserToRed :one
-UPDATE users
-SET is_chirpy_red = TRUE, updated_at = NOW() AT TIME ZONE 'utc'
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+--
+
+-- name: LockUser :one
+-- LockUser returns a user like GetUserByID, locking them until the end of
+-- the transaction, to serialize the changes of a user checked against their
+-- limits.
+SELECT * FROM users
 WHERE id = $1
+FOR UPDATE;
+--
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE LOWER(handle) = LOWER(@handle::text);
+--
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $1, display_name = $2, bio = $3, avatar_media_id = $4, updated_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $5
 RETURNING *;
 --
+
+-- name: GetUserChirpCount :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL;
+--
+
+-- name: GetUserProfiles :many
+SELECT users.id, users.handle, users.display_name, media_files.storage_key AS avatar_key, media_files.thumbnail_key AS avatar_thumbnail_key
+FROM users
+LEFT JOIN media_files ON media_files.id = users.avatar_media_id
+WHERE users.id = ANY(@ids::uuid[]);
+--
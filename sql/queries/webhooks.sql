@@ -0,0 +1,98 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, user_id, url, secret, event_types, global)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, @event_types::text[], $4)
+RETURNING *;
+--
+
+-- name: GetWebhooksByUserID :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at;
+--
+
+-- name: CountWebhooksByUserID :one
+SELECT COUNT(*) FROM webhooks
+WHERE user_id = $1;
+--
+
+-- name: GetWebhookByID :one
+SELECT * FROM webhooks
+WHERE id = $1;
+--
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1;
+--
+
+-- name: CreateEventWebhookDeliveries :exec
+-- CreateEventWebhookDeliveries creates a delivery of an outbox event for
+-- every endpoint that subscribed to it. Global endpoints only get the events
+-- of every user while their owner is still an admin. Running it again for
+-- the same event is a no-op.
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), NOW() AT TIME ZONE 'utc', webhooks.id, @event_id::uuid, @event_type::text, @payload::jsonb,
+    NOW() AT TIME ZONE 'utc'
+FROM webhooks
+JOIN users ON users.id = webhooks.user_id
+WHERE ((webhooks.global AND users.is_admin) OR webhooks.user_id = @subject_id::uuid)
+AND (cardinality(webhooks.event_types) = 0 OR @event_type::text = ANY(webhooks.event_types))
+ON CONFLICT (webhook_id, event_id) DO NOTHING;
+--
+
+-- name: CreateWebhookDelivery :one
+-- CreateWebhookDelivery creates a delivery claimed for its first attempt,
+-- to send it right away.
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_type, payload, attempts, next_attempt_at)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, 1, @lease_until)
+RETURNING *;
+--
+
+-- name: ClaimWebhookDeliveries :many
+-- ClaimWebhookDeliveries leases the due deliveries until lease_until, so
+-- that other server instances skip them while they are sent.
+WITH due AS (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    WHERE webhook_deliveries.status = 'pending'
+    AND webhook_deliveries.next_attempt_at <= NOW() AT TIME ZONE 'utc'
+    ORDER BY webhook_deliveries.next_attempt_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = @lease_until
+FROM due
+WHERE webhook_deliveries.id = due.id
+RETURNING webhook_deliveries.*;
+--
+
+-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_attempts (id, created_at, delivery_id, status_code, error, response_body, duration_ms)
+VALUES (gen_random_uuid(), NOW() AT TIME ZONE 'utc', $1, $2, $3, $4, $5);
+--
+
+-- name: SetWebhookDeliveryStatus :one
+UPDATE webhook_deliveries SET status = $2, next_attempt_at = $3
+WHERE id = $1
+RETURNING *;
+--
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+--
+
+-- name: GetWebhookAttempts :many
+SELECT * FROM webhook_attempts
+WHERE delivery_id = ANY(@delivery_ids::uuid[])
+ORDER BY created_at;
+--
+
+-- name: RetryWebhookDelivery :one
+-- RetryWebhookDelivery moves a dead letter back to the pending deliveries,
+-- with a fresh set of attempts.
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW() AT TIME ZONE 'utc'
+WHERE id = $1 AND webhook_id = $2 AND status = 'failed'
+RETURNING *;
+--
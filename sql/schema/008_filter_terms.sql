@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS filter_terms (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    term TEXT UNIQUE NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag'))
+);
+
+INSERT INTO filter_terms (id, created_at, updated_at, term, action)
+VALUES
+    (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', 'kerfuffle', 'mask'),
+    (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', 'sharbert', 'mask'),
+    (gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', 'fornax', 'mask')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS chirp_filter_flags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    term TEXT NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (chirp_id, term)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_filter_flags;
+DROP TABLE IF EXISTS filter_terms;
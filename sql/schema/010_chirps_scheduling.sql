@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITHOUT TIME ZONE;
+CREATE INDEX IF NOT EXISTS chirps_scheduled_publish_at_idx ON chirps(publish_at)
+WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_scheduled_publish_at_idx;
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS status;
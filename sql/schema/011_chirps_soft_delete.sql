@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITHOUT TIME ZONE;
+CREATE INDEX IF NOT EXISTS chirps_deleted_at_idx ON chirps(deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_deleted_at_idx;
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS deleted_at;
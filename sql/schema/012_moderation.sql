@@ -0,0 +1,50 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP WITHOUT TIME ZONE;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'active'
+    CHECK (state IN ('active', 'suspended'));
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS state_until TIMESTAMP WITHOUT TIME ZONE;
+
+ALTER TABLE chirp_filter_flags
+ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITHOUT TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS chirp_reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP WITHOUT TIME ZONE,
+    UNIQUE (chirp_id, reporter_id)
+);
+CREATE INDEX IF NOT EXISTS chirp_reports_open_idx ON chirp_reports(chirp_id)
+WHERE resolved_at IS NULL;
+
+-- Moderation actions are an audit log: they outlive the chirps and users they
+-- refer to, hence no foreign keys. A NULL moderator is an automatic action.
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    moderator_id UUID,
+    chirp_id UUID,
+    target_user_id UUID,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS chirp_reports;
+ALTER TABLE chirp_filter_flags
+DROP COLUMN IF EXISTS resolved_at;
+ALTER TABLE users
+DROP COLUMN IF EXISTS state_until;
+ALTER TABLE users
+DROP COLUMN IF EXISTS state;
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS hidden_at;
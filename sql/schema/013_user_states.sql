@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS users_state_check;
+ALTER TABLE users
+ADD CONSTRAINT users_state_check CHECK (state IN ('active', 'suspended', 'shadow_banned'));
+
+-- Audit log of user state changes. changed_by is the admin who made the
+-- change, or NULL when it was made by the server itself.
+CREATE TABLE IF NOT EXISTS user_state_changes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    changed_by UUID,
+    old_state TEXT NOT NULL,
+    new_state TEXT NOT NULL,
+    state_until TIMESTAMP WITHOUT TIME ZONE,
+    reason TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS user_state_changes_user_id_idx ON user_state_changes(user_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_state_changes;
+UPDATE users SET state = 'active', state_until = NULL WHERE state = 'shadow_banned';
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS users_state_check;
+ALTER TABLE users
+ADD CONSTRAINT users_state_check CHECK (state IN ('active', 'suspended'));
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id != followee_id)
+);
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows(followee_id);
+
+-- Materialized home timelines, filled when chirps are published if the server
+-- runs with the fan-out-on-write strategy.
+CREATE TABLE IF NOT EXISTS timeline_entries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX IF NOT EXISTS timeline_entries_page_idx ON timeline_entries(user_id, created_at DESC, chirp_id DESC);
+
+CREATE INDEX IF NOT EXISTS chirps_user_id_created_at_idx ON chirps(user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_user_id_created_at_idx;
+DROP TABLE IF EXISTS timeline_entries;
+DROP TABLE IF EXISTS follows;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Handles are unique regardless of case, but kept as the user typed them.
+ALTER TABLE users
+ADD COLUMN handle TEXT,
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_media_id UUID REFERENCES media_files(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_handle_idx ON users(LOWER(handle));
+
+-- +goose Down
+DROP INDEX IF EXISTS users_handle_idx;
+ALTER TABLE users
+DROP COLUMN avatar_media_id,
+DROP COLUMN bio,
+DROP COLUMN display_name,
+DROP COLUMN handle;
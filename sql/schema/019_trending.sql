@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS trending_snapshots (
+    id UUID PRIMARY KEY,
+    time_window TEXT NOT NULL,
+    computed_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS trending_snapshots_window_idx ON trending_snapshots(time_window, computed_at DESC);
+
+CREATE TABLE IF NOT EXISTS trending_items (
+    snapshot_id UUID NOT NULL REFERENCES trending_snapshots(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('hashtag', 'chirp')),
+    rank INTEGER NOT NULL,
+    tag TEXT,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (snapshot_id, kind, rank)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS trending_items;
+DROP TABLE IF EXISTS trending_snapshots;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS chirp_bookmarks (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+-- A chirp can only be pinned by its author, so it is pinned at most once.
+CREATE TABLE IF NOT EXISTS chirp_pins (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS chirp_pins_user_id_idx ON chirp_pins(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS chirp_pins;
+DROP TABLE IF EXISTS chirp_bookmarks;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- IDs of the chirp events sent with NOTIFY, shared by every server instance
+-- so that clients can resume a stream on any of them.
+CREATE SEQUENCE IF NOT EXISTS chirp_event_ids;
+
+-- +goose Down
+DROP SEQUENCE IF EXISTS chirp_event_ids;
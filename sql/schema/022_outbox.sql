@@ -0,0 +1,29 @@
+-- +goose Up
+-- Domain events are written to the outbox in the transaction of the change
+-- they describe, then dispatched to every subscriber by a background job.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    -- Set once a delivery was created for every subscriber of the event.
+    expanded_at TIMESTAMP WITHOUT TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events(created_at) WHERE expanded_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    subscriber TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    last_error TEXT,
+    delivered_at TIMESTAMP WITHOUT TIME ZONE,
+    failed_at TIMESTAMP WITHOUT TIME ZONE,
+    PRIMARY KEY (event_id, subscriber)
+);
+CREATE INDEX IF NOT EXISTS outbox_deliveries_due_idx ON outbox_deliveries(next_attempt_at)
+WHERE delivered_at IS NULL AND failed_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_deliveries;
+DROP TABLE IF EXISTS outbox_events;
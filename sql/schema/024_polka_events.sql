@@ -0,0 +1,22 @@
+-- +goose Up
+-- Every request received on the Polka webhook, kept for auditing, along
+-- with what was made of it.
+CREATE TABLE IF NOT EXISTS polka_payloads (
+    id UUID PRIMARY KEY,
+    received_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    event_id TEXT,
+    payload BYTEA NOT NULL,
+    outcome TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS polka_payloads_event_id_idx ON polka_payloads(event_id);
+
+-- The Polka events already handled, so that redeliveries are acknowledged
+-- without running again.
+CREATE TABLE IF NOT EXISTS polka_processed_events (
+    event_id TEXT PRIMARY KEY,
+    processed_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS polka_processed_events;
+DROP TABLE IF EXISTS polka_payloads;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- The Chirpy Red subscription of each user. users.is_chirpy_red is kept in
+-- sync with it, and is true while the subscription is active.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired', 'refunded')),
+    current_period_start TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    current_period_end TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    canceled_at TIMESTAMP WITHOUT TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS subscriptions_period_end_idx ON subscriptions(current_period_end)
+WHERE status IN ('active', 'past_due', 'canceled');
+
+-- Users upgraded before subscriptions existed get a first period from now
+-- on, and Polka renewals extend it.
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+SELECT gen_random_uuid(), NOW() AT TIME ZONE 'utc', NOW() AT TIME ZONE 'utc', id, 'red', 'active',
+    NOW() AT TIME ZONE 'utc', (NOW() AT TIME ZONE 'utc') + INTERVAL '30 days'
+FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,69 @@
+-- +goose Up
+-- The key pairs local users sign their ActivityPub requests with, created
+-- the first time they are needed.
+CREATE TABLE IF NOT EXISTS actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL
+);
+
+-- Actors of other servers, identified by their URI.
+CREATE TABLE IF NOT EXISTS remote_actors (
+    id UUID PRIMARY KEY,
+    uri TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT NOT NULL DEFAULT '',
+    public_key TEXT NOT NULL,
+    fetched_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Remote actors following local users.
+CREATE TABLE IF NOT EXISTS remote_followers (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    remote_actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    follow_uri TEXT NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, remote_actor_id)
+);
+
+-- Local users following remote actors, accepted once the remote server
+-- sends an Accept.
+CREATE TABLE IF NOT EXISTS remote_follows (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    remote_actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    follow_uri TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITHOUT TIME ZONE,
+    PRIMARY KEY (user_id, remote_actor_id)
+);
+
+-- Notes received from the remote actors followed by local users.
+CREATE TABLE IF NOT EXISTS remote_chirps (
+    id UUID PRIMARY KEY,
+    uri TEXT NOT NULL UNIQUE,
+    remote_actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS remote_chirps_actor_published_idx ON remote_chirps(remote_actor_id, published_at DESC);
+
+-- Likes of local chirps by remote actors.
+CREATE TABLE IF NOT EXISTS remote_likes (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    remote_actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    like_uri TEXT NOT NULL,
+    created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    PRIMARY KEY (chirp_id, remote_actor_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS remote_likes;
+DROP TABLE IF EXISTS remote_chirps;
+DROP TABLE IF EXISTS remote_follows;
+DROP TABLE IF EXISTS remote_followers;
+DROP TABLE IF EXISTS remote_actors;
+DROP TABLE IF EXISTS actor_keys;
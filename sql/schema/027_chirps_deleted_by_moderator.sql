@@ -0,0 +1,14 @@
+-- +goose Up
+-- Chirps deleted by moderators can't be restored by their authors.
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS deleted_by_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE chirps
+SET deleted_by_moderator = TRUE
+WHERE deleted_at IS NOT NULL AND id IN (
+    SELECT chirp_id FROM moderation_actions WHERE action = 'delete'
+);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS deleted_by_moderator;
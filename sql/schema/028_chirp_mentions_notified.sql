@@ -0,0 +1,14 @@
+-- +goose Up
+-- Mentions are notified once, even when the ChirpCreated event is handled
+-- again after the notification was read.
+ALTER TABLE chirp_mentions
+ADD COLUMN IF NOT EXISTS notified BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE chirp_mentions
+SET notified = TRUE
+FROM chirps
+WHERE chirps.id = chirp_mentions.chirp_id AND chirps.status = 'published';
+
+-- +goose Down
+ALTER TABLE chirp_mentions
+DROP COLUMN IF EXISTS notified;